@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+)
+
+func runBurn(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("burn", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file")
+	byID := fs.Bool("id", false, "argument is secret ID, requires token with secrets:burn scope")
+	fs.Usage = func() {
+		_, _ = io.WriteString(fs.Output(), "Usage: oss burn [flags] <manage link | -id secret ID>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("manage link is required")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	c, err := cfg.client()
+	if err != nil {
+		return err
+	}
+	if *byID {
+		return c.BurnByID(ctx, fs.Arg(0))
+	}
+	return c.Burn(ctx, fs.Arg(0))
+}
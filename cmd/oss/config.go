@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/client"
+)
+
+// cliConfig holds server location and credentials, file values are overridden by environment
+type cliConfig struct {
+	Server   string `json:"server"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+const defaultServer = "http://localhost:8080"
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/oss/config.json or its platform equivalent
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "oss", "config.json")
+}
+
+// loadConfig reads optional config file at path (OSS_CONFIG or default location when empty)
+// and applies OSS_URL, OSS_USERNAME, OSS_PASSWORD and OSS_TOKEN on top of it
+func loadConfig(path string) (*cliConfig, error) {
+	cfg := &cliConfig{Server: defaultServer}
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("OSS_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return nil, err
+		default:
+			if err = json.Unmarshal(b, cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if v := os.Getenv("OSS_URL"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("OSS_USERNAME"); v != "" {
+		cfg.Username = v
+	}
+	if v := os.Getenv("OSS_PASSWORD"); v != "" {
+		cfg.Password = v
+	}
+	if v := os.Getenv("OSS_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	cfg.Server = strings.TrimRight(cfg.Server, "/")
+	return cfg, nil
+}
+
+func (c *cliConfig) client() (*client.Client, error) {
+	var opts []client.OptsFn
+	if c.Token != "" {
+		opts = append(opts, client.WithToken(c.Token))
+	} else if c.Username != "" || c.Password != "" {
+		opts = append(opts, client.WithBasicAuth(c.Username, c.Password))
+	}
+	return client.New(c.Server, opts...)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/client"
+)
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func runCreate(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var attachments, recipients stringsFlag
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file")
+	inputPath := fs.String("in", "-", "file with secret value, - reads stdin")
+	ttl := fs.Duration("ttl", 0, "time to live, e.g. 30m or 24h (server default when empty)")
+	maxViews := fs.Uint64("views", 0, "max views (server default when empty)")
+	passphrase := fs.String("passphrase", "", "passphrase required to reveal the secret, also read from OSS_PASSPHRASE")
+	fs.Var(&attachments, "file", "attach file, repeatable")
+	fs.Var(&recipients, "recipient", "seal to age recipient or OpenPGP public key file, repeatable")
+	fs.Usage = func() {
+		_, _ = io.WriteString(fs.Output(), "Usage: oss create [flags] < secret\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	value, err := readInput(*inputPath, stdin)
+	if err != nil {
+		return err
+	}
+	if len(value) == 0 && len(attachments) == 0 {
+		return errors.New("secret value is empty")
+	}
+
+	req := &client.CreateRequest{Value: string(value), TTL: *ttl, MaxViews: *maxViews, Passphrase: *passphrase}
+	if req.Passphrase == "" {
+		req.Passphrase = os.Getenv("OSS_PASSPHRASE")
+	}
+	for _, path := range attachments {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		req.Attachments = append(req.Attachments, client.Attachment{Name: filepath.Base(path), Content: b})
+	}
+	for _, r := range recipients {
+		if strings.HasPrefix(r, "age1") {
+			req.Recipients = append(req.Recipients, r)
+			continue
+		}
+		b, err := os.ReadFile(r)
+		if err != nil {
+			return err
+		}
+		req.Recipients = append(req.Recipients, string(b))
+	}
+
+	c, err := cfg.client()
+	if err != nil {
+		return err
+	}
+	secret, err := c.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(stdout, secret.URL)
+	_, _ = fmt.Fprintf(stderr, "manage: %s\nexpires: %s\n", secret.ManageURL, secret.ExpiresAt.Local().Format(time.RFC3339))
+	return nil
+}
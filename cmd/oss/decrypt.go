@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+)
+
+func runDecrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	identityPath := fs.String("i", "", "age identity file or armored OpenPGP private key")
+	inputPath := fs.String("in", "-", "sealed secret file, - reads stdin")
+	fs.Usage = func() {
+		_, _ = io.WriteString(fs.Output(), "Usage: oss decrypt -i <identity> [-in file]\n\nOpenPGP key passphrase is read from OSS_PGP_PASSPHRASE.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *identityPath == "" {
+		fs.Usage()
+		return errors.New("identity is required")
+	}
+
+	identity, err := os.ReadFile(*identityPath)
+	if err != nil {
+		return err
+	}
+	sealed, err := readInput(*inputPath, stdin)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := encryption.OpenSealed(sealed, identity, []byte(os.Getenv("OSS_PGP_PASSPHRASE")))
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(plaintext)
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const usage = `Usage: oss <command> [flags]
+
+Commands:
+  create    create secret from stdin or file and print its link
+  reveal    print secret behind link, consumes a view
+  burn      burn secret using its manage link or ID
+  token     manage API tokens (create, list, revoke)
+  decrypt   decrypt secret sealed to your age or OpenPGP key
+
+Server and credentials are read from config file (-config, OSS_CONFIG or
+~/.config/oss/config.json) and OSS_URL, OSS_USERNAME, OSS_PASSWORD, OSS_TOKEN.
+
+Run "oss <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = runCreate(ctx, os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+	case "reveal":
+		err = runReveal(ctx, os.Args[2:], os.Stdout, os.Stderr)
+	case "burn":
+		err = runBurn(ctx, os.Args[2:])
+	case "token":
+		err = runToken(ctx, os.Args[2:], os.Stdout)
+	case "decrypt":
+		err = runDecrypt(os.Args[2:], os.Stdin, os.Stdout)
+	case "-h", "--help", "help":
+		_, _ = fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "oss: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case err != nil:
+		_, _ = fmt.Fprintf(os.Stderr, "oss: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func runReveal(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("reveal", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file")
+	outDir := fs.String("o", "", "directory to write attachments to")
+	passphrase := fs.String("passphrase", "", "passphrase of protected secret, also read from OSS_PASSPHRASE")
+	fs.Usage = func() {
+		_, _ = io.WriteString(fs.Output(), "Usage: oss reveal [flags] <link>\n\nRevealing consumes a view.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("link is required")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	c, err := cfg.client()
+	if err != nil {
+		return err
+	}
+	if *passphrase == "" {
+		*passphrase = os.Getenv("OSS_PASSPHRASE")
+	}
+	res, err := c.RevealWithPassphrase(ctx, fs.Arg(0), *passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(stdout, res.Value); err != nil {
+		return err
+	}
+	if res.Sealed != "" {
+		_, _ = fmt.Fprintf(stderr, "secret is sealed (%s), pipe it to oss decrypt\n", res.Sealed)
+	}
+
+	for _, a := range res.Attachments {
+		if *outDir == "" {
+			_, _ = fmt.Fprintf(stderr, "skipped attachment %s, use -o to save attachments\n", a.Name)
+			continue
+		}
+		path := filepath.Join(*outDir, filepath.Base(a.Name))
+		if err = os.WriteFile(path, a.Content, 0o600); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stderr, "saved %s\n", path)
+	}
+	return nil
+}
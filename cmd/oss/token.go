@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/client"
+)
+
+const tokenUsage = `Usage: oss token <create|list|revoke> [flags]
+
+  create -name ci -scope secrets:create [-scope secrets:burn] [-ttl 720h] [-rate 60]
+  list
+  revoke <token ID>
+
+Scopes: secrets:create, secrets:burn, admin. Requires admin token or server credentials.
+`
+
+func runToken(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		_, _ = io.WriteString(stdout, tokenUsage)
+		return errors.New("token command is required")
+	}
+
+	var scopes stringsFlag
+	fs := flag.NewFlagSet("token "+args[0], flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file")
+	name := fs.String("name", "", "token name")
+	ttl := fs.Duration("ttl", 0, "token lifetime, never expires when empty")
+	rate := fs.Int("rate", 0, "max requests per minute, unlimited when empty")
+	fs.Var(&scopes, "scope", "granted scope, repeatable")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	c, err := cfg.client()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		t, err := c.CreateToken(ctx, &client.CreateTokenRequest{Name: *name, Scopes: scopes, TTL: *ttl, RateLimit: *rate})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, t.Value)
+		return err
+	case "list":
+		list, err := c.ListTokens(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tRATE\tEXPIRES")
+		for _, t := range list {
+			expires := "never"
+			if !t.ExpiresAt.IsZero() {
+				expires = t.ExpiresAt.Local().Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), t.RateLimit, expires)
+		}
+		return tw.Flush()
+	case "revoke":
+		if fs.NArg() != 1 {
+			return errors.New("token ID is required")
+		}
+		return c.RevokeToken(ctx, fs.Arg(0))
+	default:
+		_, _ = io.WriteString(stdout, tokenUsage)
+		return fmt.Errorf("unknown token command %q", args[0])
+	}
+}
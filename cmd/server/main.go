@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -11,6 +13,9 @@ import (
 
 	"github.com/pudottapommin/onetime-secrets-service/config"
 	"github.com/pudottapommin/onetime-secrets-service/internal/app"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tracing"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/version"
 	"github.com/valkey-io/valkey-go"
 )
 
@@ -18,8 +23,18 @@ var pCfg = new(atomic.Pointer[config.Config])
 
 func main() {
 	cfg := new(config.Config)
-	if err := cfg.Load(); err != nil {
-		slog.Error("failed to load config", "error", err)
+	if err := cfg.Load(config.WithArgs(os.Args[1:])); err != nil {
+		var verr *config.ValidationError
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			os.Exit(0)
+		case errors.As(err, &verr):
+			for _, f := range verr.Fields {
+				slog.Error("invalid config", "setting", f.Name, "error", f.Err)
+			}
+		default:
+			slog.Error("failed to load config", "error", err)
+		}
 		os.Exit(1)
 	}
 	if hk, bk, ok := cfg.InitCSRF(); ok {
@@ -27,27 +42,67 @@ func main() {
 			slog.String("hash", base64.StdEncoding.EncodeToString(hk)),
 			slog.String("block", base64.StdEncoding.EncodeToString(bk)))
 	}
-	
+	if nk, ok := cfg.InitNotify(); ok {
+		slog.Warn("Generated new notification signing key",
+			slog.String("key", base64.StdEncoding.EncodeToString(nk)))
+	}
+	if err := cfg.CheckKeys(); err != nil {
+		slog.Error("invalid key material", "error", err)
+		os.Exit(1)
+	}
+
 	pCfg.Store(cfg)
 
-	logLvl := slog.LevelWarn
-	if !cfg.IsProd {
-		logLvl = slog.LevelDebug
-	}
+	logLvl := new(slog.LevelVar)
+	logLvl.Set(cfg.Level())
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLvl}))
 	slog.SetDefault(logger)
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	go func() {
+		// second signal kills process instead of waiting for graceful shutdown
+		<-ctx.Done()
+		stop()
+	}()
+
+	if cfg.Tracing.IsEnabled {
+		shutdown, err := tracing.Setup(ctx, tracing.Config{
+			Exporter:    cfg.Tracing.Exporter,
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			ServiceName: cfg.Tracing.ServiceName,
+			Version:     version.Version,
+		})
+		if err != nil {
+			slog.Error("failed to setup tracing", "error", err)
+			os.Exit(1)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("failed to flush traces", "error", err)
+			}
+		}()
+	}
 
-	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{cfg.Server.DB}})
+	m := metrics.New()
+	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{cfg.Server.DB}, DialCtxFn: m.DialValkey})
 	if err != nil {
 		slog.Error("failed to create valkey client", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	webApp := app.New(ctx, db, pCfg, logger)
+	webApp := app.New(ctx, db, m, pCfg, logger)
+	webApp.OnReload(func(c *config.Config) { logLvl.Set(c.Level()) })
+	webApp.WatchConfig(ctx, func() (*config.Config, error) {
+		next := new(config.Config)
+		// Reload validates it once settings requiring restart are restored
+		return next, next.Load(config.WithArgs(os.Args[1:]), config.WithoutValidation())
+	})
 	if err = webApp.Run(); err != nil {
 		slog.Error("failed to run server", "error", err)
 		os.Exit(1)
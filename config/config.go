@@ -1,16 +1,25 @@
 package config
 
 import (
-	"encoding/base64"
-	"reflect"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
-	"github.com/caarlos0/env/v11"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
 )
 
 type Config struct {
 	IsProd    bool   `env:"OSS_PROD" envDefault:"false"`
 	SecretKey []byte `env:"OSS_SECRET_KEY"`
+	// LogLevel is debug, info, warn or error, empty is debug or warn in production
+	LogLevel string `env:"OSS_LOG_LEVEL"`
+	// ConfigWatch checks config file for changes every interval and reloads it like SIGHUP, 0 disables it
+	ConfigWatch time.Duration `env:"OSS_CONFIG_WATCH" envDefault:"0s"`
+
+	// file is config file the settings were loaded from, if any
+	file string
 
 	Server struct {
 		Addr        string `env:"ADDR,required" envDefault:"127.0.0.1:8080"`
@@ -18,38 +27,208 @@ type Config struct {
 		DB          string `env:"DB,required" envDefault:"127.0.0.1:8081"`
 		UI          bool   `env:"UI" envDefault:"true"`
 		UIHotReload bool   `env:"UI_HOT_RELOAD" envDefault:"false"`
+		// ExpiryEvents subscribes to Valkey keyspace notifications to report secrets expiring before their last view
+		ExpiryEvents bool `env:"EXPIRY_EVENTS" envDefault:"true"`
+		// TrustedProxies are CIDRs or addresses whose ClientIPHeader is trusted to carry client IP
+		TrustedProxies []string `env:"TRUSTED_PROXIES"`
+		ClientIPHeader string   `env:"CLIENT_IP_HEADER" envDefault:"X-Forwarded-For"`
+		// DrainDelay keeps serving with failing readiness after SIGTERM, so load balancers stop routing first
+		DrainDelay time.Duration `env:"DRAIN_DELAY" envDefault:"5s"`
+		// ShutdownTimeout bounds waiting for in-flight requests and reveal streams, connections are closed after it
+		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+		// StreamWriteTimeout bounds every write of reveal streams, so stalled client can't hold one forever
+		StreamWriteTimeout time.Duration `env:"STREAM_WRITE_TIMEOUT" envDefault:"30s"`
+		// Limits bound time and size of requests, ReadTimeout has to fit the largest upload
+		ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"10s"`
+		ReadTimeout       time.Duration `env:"READ_TIMEOUT" envDefault:"60s"`
+		WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" envDefault:"60s"`
+		IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" envDefault:"120s"`
+		MaxHeaderBytes    int           `env:"MAX_HEADER_BYTES" envDefault:"65536"`
+		// HSTSMaxAge of Strict-Transport-Security sent when TLS is enabled or domain is https, 0 disables it
+		HSTSMaxAge time.Duration `env:"HSTS_MAX_AGE" envDefault:"17520h"`
 	} `envPrefix:"OSS_SERVER_"`
 
+	// TLS serves HTTPS with CertFile and KeyFile, reloaded once rotated, or with certificates issued by ACME CA.
+	// RedirectAddr listens on plain HTTP, redirects to HTTPS and answers ACME HTTP challenges
+	TLS struct {
+		IsEnabled    bool   `env:"ENABLED" envDefault:"false"`
+		CertFile     string `env:"CERT_FILE"`
+		KeyFile      string `env:"KEY_FILE"`
+		RedirectAddr string `env:"REDIRECT_ADDR"`
+
+		// ACME issues certificates of Domains (host of server domain when empty), Cache is "valkey" (shared
+		// by replicas) or "dir" keeping them in CacheDir
+		ACME struct {
+			IsEnabled    bool     `env:"ENABLED" envDefault:"false"`
+			Domains      []string `env:"DOMAINS"`
+			Email        string   `env:"EMAIL"`
+			DirectoryURL string   `env:"DIRECTORY_URL"`
+			Cache        string   `env:"CACHE" envDefault:"valkey"`
+			CacheDir     string   `env:"CACHE_DIR" envDefault:"certs"`
+		} `envPrefix:"ACME_"`
+	} `envPrefix:"OSS_TLS_"`
+
 	Auth struct {
 		IsEnabled bool   `env:"ENABLED" envDefault:"false"`
 		Username  string `env:"USERNAME"`
 		Password  string `env:"PASSWORD"`
+
+		// Session configures UI login sessions, Store is "valkey" (shared by replicas) or "memory"
+		Session struct {
+			Store           string        `env:"STORE" envDefault:"valkey"`
+			IdleTimeout     time.Duration `env:"IDLE_TIMEOUT" envDefault:"1h"`
+			AbsoluteTimeout time.Duration `env:"ABSOLUTE_TIMEOUT" envDefault:"12h"`
+		} `envPrefix:"SESSION_"`
+
+		// OIDC enables single sign-on for the UI, RedirectURL defaults to {domain}/auth/oidc/callback
+		OIDC struct {
+			IsEnabled      bool     `env:"ENABLED" envDefault:"false"`
+			Issuer         string   `env:"ISSUER"`
+			ClientID       string   `env:"CLIENT_ID"`
+			ClientSecret   string   `env:"CLIENT_SECRET"`
+			RedirectURL    string   `env:"REDIRECT_URL"`
+			Scopes         []string `env:"SCOPES" envDefault:"openid,email,profile"`
+			AllowedGroups  []string `env:"ALLOWED_GROUPS"`
+			AllowedDomains []string `env:"ALLOWED_DOMAINS"`
+			GroupsClaim    string   `env:"GROUPS_CLAIM" envDefault:"groups"`
+		} `envPrefix:"OIDC_"`
 	} `envPrefix:"OSS_AUTH_"`
 
+	// RateLimit limits requests per client IP or API token with token buckets, Store is "valkey" or "memory"
+	RateLimit struct {
+		IsEnabled bool   `env:"ENABLED" envDefault:"true"`
+		Store     string `env:"STORE" envDefault:"valkey"`
+
+		Create struct {
+			PerMinute int `env:"PER_MINUTE" envDefault:"10"`
+			Burst     int `env:"BURST" envDefault:"20"`
+		} `envPrefix:"CREATE_"`
+		Reveal struct {
+			PerMinute int `env:"PER_MINUTE" envDefault:"60"`
+			Burst     int `env:"BURST" envDefault:"60"`
+		} `envPrefix:"REVEAL_"`
+		Static struct {
+			PerMinute int `env:"PER_MINUTE" envDefault:"600"`
+			Burst     int `env:"BURST" envDefault:"300"`
+		} `envPrefix:"STATIC_"`
+	} `envPrefix:"OSS_RATE_LIMIT_"`
+
+	// Lockout limits wrong passphrases per secret and client IP and wrong logins per username and client IP,
+	// Store is "valkey" (falls back to memory while Valkey is unreachable) or "memory"
+	Lockout struct {
+		IsEnabled bool   `env:"ENABLED" envDefault:"true"`
+		Store     string `env:"STORE" envDefault:"valkey"`
+		// BurnAfter burns secret after that many wrong passphrases within secret window, 0 disables it
+		BurnAfter int `env:"BURN_AFTER" envDefault:"0"`
+
+		Secret struct {
+			MaxFailures int           `env:"MAX_FAILURES" envDefault:"5"`
+			Window      time.Duration `env:"WINDOW" envDefault:"24h"`
+			Lockout     time.Duration `env:"LOCKOUT" envDefault:"15m"`
+		} `envPrefix:"SECRET_"`
+		IP struct {
+			MaxFailures int           `env:"MAX_FAILURES" envDefault:"20"`
+			Window      time.Duration `env:"WINDOW" envDefault:"15m"`
+			Lockout     time.Duration `env:"LOCKOUT" envDefault:"15m"`
+		} `envPrefix:"IP_"`
+		Username struct {
+			MaxFailures int           `env:"MAX_FAILURES" envDefault:"5"`
+			Window      time.Duration `env:"WINDOW" envDefault:"15m"`
+			Lockout     time.Duration `env:"LOCKOUT" envDefault:"15m"`
+		} `envPrefix:"USERNAME_"`
+	} `envPrefix:"OSS_LOCKOUT_"`
+
+	// Audit records who created, viewed, failed to unlock and burned which secret, every configured sink
+	// gets every event: File appends JSON lines ("-" is stdout), Syslog sends to local or remote syslog,
+	// Stream appends to Valkey stream trimmed to about MaxLen entries
+	Audit struct {
+		IsEnabled bool   `env:"ENABLED" envDefault:"false"`
+		File      string `env:"FILE"`
+
+		Syslog struct {
+			IsEnabled bool   `env:"ENABLED" envDefault:"false"`
+			Network   string `env:"NETWORK"`
+			Addr      string `env:"ADDR"`
+			Tag       string `env:"TAG" envDefault:"oss"`
+		} `envPrefix:"SYSLOG_"`
+		Stream struct {
+			Key    string `env:"KEY"`
+			MaxLen int64  `env:"MAX_LEN" envDefault:"1000000"`
+		} `envPrefix:"STREAM_"`
+	} `envPrefix:"OSS_AUDIT_"`
+
 	Csrf struct {
 		IsEnabled bool   `env:"ENABLED" envDefault:"true"`
 		HashKey   []byte `env:"HASH_KEY"`
 		BlockKey  []byte `env:"BLOCK_KEY"`
 	} `envPrefix:"OSS_CSRF_"`
 
+	Notify struct {
+		IsEnabled  bool   `env:"ENABLED" envDefault:"false"`
+		SigningKey []byte `env:"SIGNING_KEY"`
+		MaxRetries int    `env:"MAX_RETRIES" envDefault:"5"`
+
+		SMTP struct {
+			Addr     string `env:"ADDR"`
+			From     string `env:"FROM"`
+			Username string `env:"USERNAME"`
+			Password string `env:"PASSWORD"`
+		} `envPrefix:"SMTP_"`
+	} `envPrefix:"OSS_NOTIFY_"`
+
+	// Metrics serves Prometheus metrics on Path, on separate Addr listener when set so they are not public
+	Metrics struct {
+		IsEnabled bool   `env:"ENABLED" envDefault:"false"`
+		Addr      string `env:"ADDR"`
+		Path      string `env:"PATH" envDefault:"/metrics"`
+	} `envPrefix:"OSS_METRICS_"`
+
+	// Tracing exports OpenTelemetry spans with "otlp" (OTLP over HTTP to Endpoint) or "stdout" Exporter
+	Tracing struct {
+		IsEnabled   bool    `env:"ENABLED" envDefault:"false"`
+		Exporter    string  `env:"EXPORTER" envDefault:"otlp"`
+		Endpoint    string  `env:"ENDPOINT"`
+		Insecure    bool    `env:"INSECURE" envDefault:"false"`
+		SampleRatio float64 `env:"SAMPLE_RATIO" envDefault:"1"`
+		ServiceName string  `env:"SERVICE_NAME" envDefault:"onetime-secrets-service"`
+	} `envPrefix:"OSS_TRACING_"`
+
 	Pprof struct {
 		IsEnabled bool `env:"ENABLED" envDefault:"false"`
 	} `envPrefix:"OSS_PPROF_"`
 }
 
-func (c *Config) Load() error {
-	return env.ParseWithOptions(c, env.Options{
-		FuncMap: map[reflect.Type]env.ParserFunc{
-			reflect.TypeOf([]byte{}): func(val string) (any, error) {
-				if val == "" {
-					return nil, nil
-				}
-				return base64.StdEncoding.DecodeString(val)
-			},
-		},
-	})
+// Level of logs, from LogLevel or by IsProd when it is empty
+func (c *Config) Level() slog.Level {
+	var lvl slog.Level
+	switch {
+	case c.LogLevel != "":
+		_ = lvl.UnmarshalText([]byte(c.LogLevel))
+	case c.IsProd:
+		lvl = slog.LevelWarn
+	default:
+		lvl = slog.LevelDebug
+	}
+	return lvl
+}
+
+// InheritKeys takes keys generated by InitCSRF and InitNotify for from when c has none of its own, so
+// reloaded config keeps them
+func (c *Config) InheritKeys(from *Config) {
+	if c.Csrf.HashKey == nil {
+		c.Csrf.HashKey = from.Csrf.HashKey
+	}
+	if c.Csrf.BlockKey == nil {
+		c.Csrf.BlockKey = from.Csrf.BlockKey
+	}
+	if c.Notify.SigningKey == nil {
+		c.Notify.SigningKey = from.Notify.SigningKey
+	}
 }
 
+// File returns config file the settings were loaded from, empty when there was none
+func (c *Config) File() string { return c.file }
+
 func (c *Config) InitCSRF() ([]byte, []byte, bool) {
 	if !c.Csrf.IsEnabled {
 		return nil, nil, false
@@ -68,3 +247,33 @@ func (c *Config) InitCSRF() ([]byte, []byte, bool) {
 	}
 	return hk, bk, hk != nil || bk != nil
 }
+
+func (c *Config) InitNotify() ([]byte, bool) {
+	if !c.Notify.IsEnabled || c.Notify.SigningKey != nil {
+		return nil, false
+	}
+	c.Notify.SigningKey = encryption.GenerateNewKey(32)
+	return c.Notify.SigningKey, true
+}
+
+// CheckKeys reports key material which is missing or unusable, it has to be called after InitCSRF and InitNotify
+func (c *Config) CheckKeys() error {
+	var errs []error
+	if c.SecretKey != nil {
+		if _, err := aes.NewCipher(c.SecretKey); err != nil {
+			errs = append(errs, fmt.Errorf("secret key: %w", err))
+		}
+	}
+	if c.Csrf.IsEnabled {
+		if len(c.Csrf.HashKey) == 0 {
+			errs = append(errs, errors.New("csrf hash key is missing"))
+		}
+		if _, err := aes.NewCipher(c.Csrf.BlockKey); err != nil {
+			errs = append(errs, fmt.Errorf("csrf block key: %w", err))
+		}
+	}
+	if c.Notify.IsEnabled && len(c.Notify.SigningKey) == 0 {
+		errs = append(errs, errors.New("notification signing key is missing"))
+	}
+	return errors.Join(errs...)
+}
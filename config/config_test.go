@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func fieldNames(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	names := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func TestLoadLayers(t *testing.T) {
+	yamlFile := writeFile(t, "oss.yaml", `
+# comments are what files are for
+server:
+  addr: 0.0.0.0:9000
+  domain: https://file.example
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+rate_limit:
+  create:
+    burst: 5
+`)
+	tomlFile := writeFile(t, "oss.toml", `
+# comments are what files are for
+[server]
+addr = "0.0.0.0:9000"
+domain = "https://file.example"
+trusted_proxies = ["10.0.0.0/8", "192.168.1.1"]
+
+[rate_limit.create]
+burst = 5
+`)
+	for _, file := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(file), func(t *testing.T) {
+			cfg := new(Config)
+			require.NoError(t, cfg.Load(
+				WithFile(file),
+				WithEnvironment(map[string]string{"OSS_SERVER_DOMAIN": "https://env.example", "OSS_SERVER_ADDR": "0.0.0.0:9001"}),
+				WithArgs([]string{"--server-addr", "0.0.0.0:9002", "--prod"}),
+			))
+			assert.Equal(t, "0.0.0.0:9002", cfg.Server.Addr, "flags override environment")
+			assert.Equal(t, "https://env.example", cfg.Server.Domain, "environment overrides file")
+			assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.Server.TrustedProxies)
+			assert.Equal(t, 5, cfg.RateLimit.Create.Burst, "file overrides defaults")
+			assert.Equal(t, 10, cfg.RateLimit.Create.PerMinute, "defaults are kept")
+			assert.True(t, cfg.IsProd)
+		})
+	}
+
+	cfg := new(Config)
+	require.NoError(t, cfg.Load(WithEnvironment(map[string]string{}), WithArgs([]string{"--config", yamlFile})))
+	assert.Equal(t, "0.0.0.0:9000", cfg.Server.Addr, "--config flag reads file")
+
+	cfg = new(Config)
+	require.NoError(t, cfg.Load(WithEnvironment(map[string]string{"OSS_CONFIG": tomlFile})))
+	assert.Equal(t, "https://file.example", cfg.Server.Domain, "OSS_CONFIG reads file")
+
+	err := new(Config).Load(WithEnvironment(map[string]string{}), WithArgs([]string{"-h"}))
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestLoadFileVariants(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	keyFile := writeFile(t, "secret_key", key+"\n")
+	passwordFile := writeFile(t, "password", "s3cr3t\n")
+	configFile := writeFile(t, "oss.yaml", "auth:\n  password_file: "+passwordFile+"\n")
+
+	cfg := new(Config)
+	require.NoError(t, cfg.Load(WithFile(configFile), WithEnvironment(map[string]string{
+		"OSS_SECRET_KEY_FILE":    keyFile,
+		"OSS_CSRF_HASH_KEY_FILE": keyFile,
+		"OSS_TLS_CERT_FILE":      "/etc/tls/tls.crt",
+	})))
+	assert.Equal(t, []byte("0123456789abcdef0123456789abcdef"), cfg.SecretKey)
+	assert.Equal(t, cfg.SecretKey, cfg.Csrf.HashKey)
+	assert.Equal(t, "s3cr3t", cfg.Auth.Password)
+	assert.Equal(t, "/etc/tls/tls.crt", cfg.TLS.CertFile, "settings ending with _FILE are not read")
+
+	cfg = new(Config)
+	require.NoError(t, cfg.Load(WithFile(configFile), WithEnvironment(map[string]string{"OSS_AUTH_PASSWORD": "env"})))
+	assert.Equal(t, "env", cfg.Auth.Password, "environment overrides _FILE variant of file")
+
+	err := new(Config).Load(WithEnvironment(map[string]string{
+		"OSS_SECRET_KEY":         key,
+		"OSS_SECRET_KEY_FILE":    keyFile,
+		"OSS_AUTH_PASSWORD_FILE": filepath.Join(t.TempDir(), "missing"),
+	}))
+	assert.ElementsMatch(t, []string{"OSS_SECRET_KEY_FILE", "OSS_AUTH_PASSWORD_FILE"}, fieldNames(t, err))
+}
+
+func TestLoadValidation(t *testing.T) {
+	configFile := writeFile(t, "oss.yaml", `
+server:
+  adr: typo
+rate_limit:
+  store: redis
+`)
+	cfg := new(Config)
+	err := cfg.Load(WithFile(configFile), WithEnvironment(map[string]string{
+		"OSS_SERVER_DOMAIN":                 "localhost",
+		"OSS_SERVER_SHUTDOWN_TIMEOUT":       "soon",
+		"OSS_RATE_LIMIT_CREATE_BURST":       "many",
+		"OSS_TRACING_ENABLED":               "true",
+		"OSS_TRACING_EXPORTER":              "jaeger",
+		"OSS_LOCKOUT_USERNAME_MAX_FAILURES": "0",
+	}))
+	assert.ElementsMatch(t, []string{
+		"server.adr",
+		"OSS_SERVER_SHUTDOWN_TIMEOUT",
+		"OSS_RATE_LIMIT_CREATE_BURST",
+		"OSS_SERVER_DOMAIN",
+		"OSS_RATE_LIMIT_STORE",
+		"OSS_LOCKOUT_USERNAME_MAX_FAILURES",
+		"OSS_TRACING_EXPORTER",
+	}, fieldNames(t, err))
+	assert.Equal(t, 15*time.Second, cfg.Server.ShutdownTimeout, "invalid value is left to default")
+
+	cfg = new(Config)
+	assert.NoError(t, cfg.Load(WithoutValidation(), WithEnvironment(map[string]string{"OSS_SERVER_DOMAIN": "localhost"})))
+	assert.Equal(t, []string{"OSS_SERVER_SHUTDOWN_TIMEOUT"}, fieldNames(t, new(Config).Load(WithoutValidation(),
+		WithEnvironment(map[string]string{"OSS_SERVER_DOMAIN": "localhost", "OSS_SERVER_SHUTDOWN_TIMEOUT": "soon"}))),
+		"values which don't parse are still reported")
+
+	cfg = new(Config)
+	require.NoError(t, cfg.Load(WithEnvironment(map[string]string{})))
+	assert.NoError(t, cfg.Validate(), "defaults must be valid")
+	cfg.TLS.IsEnabled = true
+	var verr *ValidationError
+	require.True(t, errors.As(cfg.Validate(), &verr))
+	assert.Len(t, verr.Fields, 2)
+	assert.Contains(t, verr.Error(), "OSS_TLS_CERT_FILE: must not be empty unless ACME is enabled")
+
+	cfg = new(Config)
+	require.NoError(t, cfg.Load(WithEnvironment(map[string]string{})))
+	cfg.SecretKey = make([]byte, 20)
+	cfg.Csrf.HashKey = make([]byte, 16)
+	cfg.Csrf.BlockKey = make([]byte, 31)
+	cfg.Auth.IsEnabled = true
+	assert.ElementsMatch(t, []string{"OSS_SECRET_KEY", "OSS_CSRF_HASH_KEY", "OSS_CSRF_BLOCK_KEY", "OSS_AUTH_ENABLED"}, fieldNames(t, cfg.Validate()))
+	cfg.SecretKey, cfg.Csrf.HashKey, cfg.Csrf.BlockKey = make([]byte, 32), make([]byte, 64), make([]byte, 16)
+	cfg.Auth.Username = "admin"
+	assert.Equal(t, []string{"OSS_AUTH_ENABLED"}, fieldNames(t, cfg.Validate()), "password is required too")
+	cfg.Auth.OIDC.IsEnabled, cfg.Auth.OIDC.Issuer, cfg.Auth.OIDC.ClientID = true, "https://idp.example", "oss"
+	assert.NoError(t, cfg.Validate(), "OIDC alone lets users sign in")
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Changed lists variables of settings whose values differ in other
+func (c *Config) Changed(other *Config) []string {
+	cv, ov := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	var changed []string
+	for _, s := range settings() {
+		if !reflect.DeepEqual(cv.FieldByIndex(s.Index).Interface(), ov.FieldByIndex(s.Index).Interface()) {
+			changed = append(changed, s.Key)
+		}
+	}
+	return changed
+}
+
+// Restore sets settings of variables keys back to their values in from
+func (c *Config) Restore(from *Config, keys ...string) {
+	cv, fv := reflect.ValueOf(c).Elem(), reflect.ValueOf(from).Elem()
+	for _, s := range settings() {
+		if slices.Contains(keys, s.Key) {
+			cv.FieldByIndex(s.Index).Set(fv.FieldByIndex(s.Index))
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// fileSuffix of variables naming file the value of setting is read from, e.g. OSS_SECRET_KEY_FILE
+const fileSuffix = "_FILE"
+
+type (
+	// LoadOptsFn configures layers of Load
+	LoadOptsFn func(*loadOptions)
+
+	loadOptions struct {
+		file       string
+		args       []string
+		environ    map[string]string
+		noValidate bool
+	}
+
+	// setting is leaf field of Config, Key is its environment variable and the name used by every layer
+	setting struct {
+		Key   string
+		Type  reflect.Type
+		Index []int
+	}
+
+	// flagValue collects flag of setting into flag layer, bool settings can be set without value
+	flagValue struct {
+		key    string
+		isBool bool
+		values map[string]string
+	}
+)
+
+var funcMap = map[reflect.Type]env.ParserFunc{
+	reflect.TypeOf([]byte{}): func(val string) (any, error) {
+		if val == "" {
+			return nil, nil
+		}
+		return base64.StdEncoding.DecodeString(val)
+	},
+}
+
+// settings lists every leaf field of Config in declaration order
+var settings = sync.OnceValue(func() []setting {
+	var out []setting
+	collectSettings(reflect.TypeFor[Config](), "", nil, &out)
+	return out
+})
+
+func collectSettings(t reflect.Type, prefix string, index []int, out *[]setting) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		idx := append(slices.Clone(index), i)
+		if p, ok := f.Tag.Lookup("envPrefix"); ok {
+			collectSettings(f.Type, prefix+p, idx, out)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+		if name != "" {
+			*out = append(*out, setting{Key: prefix + name, Type: f.Type, Index: idx})
+		}
+	}
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings() {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// WithFile reads YAML or TOML file between defaults and environment, --config flag and OSS_CONFIG override it
+func WithFile(path string) LoadOptsFn {
+	return func(o *loadOptions) {
+		o.file = path
+	}
+}
+
+// WithArgs parses command line flags as the last layer, every setting has flag named after its variable
+// without OSS_ prefix, e.g. --server-addr for OSS_SERVER_ADDR
+func WithArgs(args []string) LoadOptsFn {
+	return func(o *loadOptions) {
+		o.args = args
+	}
+}
+
+// WithEnvironment replaces process environment, e.g. in tests
+func WithEnvironment(environ map[string]string) LoadOptsFn {
+	return func(o *loadOptions) {
+		o.environ = environ
+	}
+}
+
+// WithoutValidation skips checks of Validate, values which don't parse are still reported. Reload uses it
+// to validate config only once settings requiring restart are restored.
+func WithoutValidation() LoadOptsFn {
+	return func(o *loadOptions) {
+		o.noValidate = true
+	}
+}
+
+// Load reads configuration layered as defaults, file, environment and flags, later layers win. Every layer
+// can read setting from file named by its _FILE variant, e.g. OSS_SECRET_KEY_FILE or secret_key_file, so
+// secrets can be mounted instead of passed around. Invalid settings are reported all at once as
+// *ValidationError, flag.ErrHelp is returned when usage was requested.
+func (c *Config) Load(opts ...LoadOptsFn) error {
+	o := loadOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.environ == nil {
+		o.environ = environ()
+	}
+
+	verr := new(ValidationError)
+	flags, file, err := parseFlags(o.args)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		file = o.environ["OSS_CONFIG"]
+	}
+	if file == "" {
+		file = o.file
+	}
+
+	values := make(map[string]string)
+	if file != "" {
+		fromFile, err := readFile(file, verr)
+		if err != nil {
+			return err
+		}
+		resolveFiles(fromFile, verr)
+		maps.Copy(values, fromFile)
+	}
+	fromEnv := maps.Clone(o.environ)
+	resolveFiles(fromEnv, verr)
+	maps.Copy(values, fromEnv)
+	maps.Copy(values, flags)
+
+	// invalid values are reported and left to defaults, so the rest is still parsed and validated
+	for _, s := range settings() {
+		if raw, ok := values[s.Key]; ok {
+			if err = checkValue(s, raw); err != nil {
+				verr.add(s.Key, err)
+				delete(values, s.Key)
+			}
+		}
+	}
+	if err = env.ParseWithOptions(c, env.Options{Environment: values, FuncMap: funcMap}); err != nil {
+		return err
+	}
+	c.file = file
+
+	if !o.noValidate {
+		verr.Fields = append(verr.Fields, c.fieldErrors()...)
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func environ() map[string]string {
+	m := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// checkValue parses raw value of setting alone, so parse errors name variable instead of Go field
+func checkValue(s setting, raw string) error {
+	v := reflect.New(reflect.StructOf([]reflect.StructField{{Name: "V", Type: s.Type, Tag: `env:"V"`}}))
+	err := env.ParseWithOptions(v.Interface(), env.Options{Environment: map[string]string{"V": raw}, FuncMap: funcMap})
+	var pe env.ParseError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+	return err
+}
+
+// resolveFiles replaces _FILE variants of settings with content of files they name, trailing newlines
+// are dropped. Settings which themselves end with _FILE, e.g. OSS_TLS_CERT_FILE, are kept as they are.
+func resolveFiles(values map[string]string, verr *ValidationError) {
+	for key, path := range values {
+		base, ok := strings.CutSuffix(key, fileSuffix)
+		if !ok || !strings.HasPrefix(key, "OSS_") {
+			continue
+		}
+		if _, known := lookupSetting(key); known {
+			continue
+		}
+		if _, known := lookupSetting(base); !known {
+			continue
+		}
+		delete(values, key)
+		if _, ok = values[base]; ok {
+			verr.add(key, fmt.Errorf("%s is set too", base))
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			verr.add(key, err)
+			continue
+		}
+		values[base] = strings.TrimRight(string(b), "\r\n")
+	}
+}
+
+// readFile flattens YAML or TOML file into variables, nested keys are joined with underscores and
+// prefixed with OSS_, e.g. rate_limit.create.burst is OSS_RATE_LIMIT_CREATE_BURST
+func readFile(path string, verr *ValidationError) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: error reading file: %w", err)
+	}
+	doc := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("config: unsupported file format %q, use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: error parsing %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten(doc, "", "OSS", values, verr)
+	return values, nil
+}
+
+func flatten(doc map[string]any, path, key string, values map[string]string, verr *ValidationError) {
+	for k, v := range doc {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		vk := key + "_" + strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		switch v := v.(type) {
+		case nil:
+		case map[string]any:
+			flatten(v, p, vk, values, verr)
+		default:
+			_, known := lookupSetting(vk)
+			if base, ok := strings.CutSuffix(vk, fileSuffix); ok && !known {
+				_, known = lookupSetting(base)
+			}
+			if !known {
+				verr.add(p, errors.New("unknown setting"))
+				continue
+			}
+			values[vk] = fileValue(v)
+		}
+	}
+}
+
+func fileValue(v any) string {
+	if list, ok := v.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// FlagName of setting with variable key, e.g. server-addr for OSS_SERVER_ADDR
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, "OSS_"), "_", "-"))
+}
+
+func parseFlags(args []string) (map[string]string, string, error) {
+	values := make(map[string]string)
+	if args == nil {
+		return values, "", nil
+	}
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := fs.String("config", "", "YAML or TOML config file, overrides OSS_CONFIG")
+	for _, s := range settings() {
+		fs.Var(&flagValue{key: s.Key, isBool: s.Type.Kind() == reflect.Bool, values: values}, FlagName(s.Key), "sets "+s.Key)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if fs.NArg() > 0 {
+		return nil, "", fmt.Errorf("config: unexpected arguments %q", fs.Args())
+	}
+	return values, *file, nil
+}
+
+func (f *flagValue) String() string {
+	if f == nil || f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f *flagValue) Set(v string) error {
+	f.values[f.key] = v
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
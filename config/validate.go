@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	// ValidationError lists every invalid setting, not just the first one
+	ValidationError struct {
+		Fields []FieldError
+	}
+
+	// FieldError is invalid setting, Name is its variable or, for unknown keys of file, path of the key
+	FieldError struct {
+		Name string
+		Err  error
+	}
+)
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Fields)+1)
+	lines = append(lines, fmt.Sprintf("config: %d invalid settings", len(e.Fields)))
+	for _, f := range e.Fields {
+		lines = append(lines, f.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ValidationError) add(name string, err error) {
+	e.Fields = append(e.Fields, FieldError{Name: name, Err: err})
+}
+
+func (e FieldError) Error() string { return e.Name + ": " + e.Err.Error() }
+
+func (e FieldError) Unwrap() error { return e.Err }
+
+// Validate reports every setting which is inconsistent or out of range as *ValidationError
+func (c *Config) Validate() error {
+	if errs := c.fieldErrors(); len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+func (c *Config) fieldErrors() []FieldError {
+	v := new(ValidationError)
+	check := func(key string, invalid bool, format string, args ...any) {
+		if invalid {
+			v.add(key, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		check(key, !slices.Contains(allowed, value), "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+	positive := func(key string, n int) {
+		check(key, n <= 0, "must be positive, got %d", n)
+	}
+	nonNegative := func(key string, d time.Duration) {
+		check(key, d < 0, "must not be negative, got %s", d)
+	}
+	// aesKey checks length of key given, generated keys are checked by CheckKeys
+	aesKey := func(key string, b []byte) {
+		check(key, b != nil && !slices.Contains([]int{16, 24, 32}, len(b)), "must be 16, 24 or 32 bytes, got %d", len(b))
+	}
+
+	if c.LogLevel != "" {
+		oneOf("OSS_LOG_LEVEL", strings.ToLower(c.LogLevel), "debug", "info", "warn", "error")
+	}
+	nonNegative("OSS_CONFIG_WATCH", c.ConfigWatch)
+	aesKey("OSS_SECRET_KEY", c.SecretKey)
+	if c.Csrf.IsEnabled {
+		check("OSS_CSRF_HASH_KEY", c.Csrf.HashKey != nil && len(c.Csrf.HashKey) < 32, "must be at least 32 bytes, got %d", len(c.Csrf.HashKey))
+		aesKey("OSS_CSRF_BLOCK_KEY", c.Csrf.BlockKey)
+	}
+
+	s := c.Server
+	if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+		v.add("OSS_SERVER_ADDR", err)
+	}
+	if u, err := url.Parse(s.Domain); err != nil {
+		v.add("OSS_SERVER_DOMAIN", err)
+	} else {
+		check("OSS_SERVER_DOMAIN", (u.Scheme != "http" && u.Scheme != "https") || u.Host == "",
+			"must be absolute http or https URL, got %q", s.Domain)
+	}
+	check("OSS_SERVER_DB", s.DB == "", "must not be empty")
+	for _, p := range s.TrustedProxies {
+		_, perr := netip.ParsePrefix(p)
+		_, aerr := netip.ParseAddr(p)
+		check("OSS_SERVER_TRUSTED_PROXIES", perr != nil && aerr != nil, "%q is neither CIDR nor address", p)
+	}
+	nonNegative("OSS_SERVER_DRAIN_DELAY", s.DrainDelay)
+	check("OSS_SERVER_SHUTDOWN_TIMEOUT", s.ShutdownTimeout <= 0, "must be positive, got %s", s.ShutdownTimeout)
+	nonNegative("OSS_SERVER_STREAM_WRITE_TIMEOUT", s.StreamWriteTimeout)
+	nonNegative("OSS_SERVER_READ_HEADER_TIMEOUT", s.ReadHeaderTimeout)
+	nonNegative("OSS_SERVER_READ_TIMEOUT", s.ReadTimeout)
+	nonNegative("OSS_SERVER_WRITE_TIMEOUT", s.WriteTimeout)
+	nonNegative("OSS_SERVER_IDLE_TIMEOUT", s.IdleTimeout)
+	check("OSS_SERVER_MAX_HEADER_BYTES", s.MaxHeaderBytes < 0, "must not be negative, got %d", s.MaxHeaderBytes)
+	nonNegative("OSS_SERVER_HSTS_MAX_AGE", s.HSTSMaxAge)
+
+	if t := c.TLS; t.IsEnabled {
+		if t.ACME.IsEnabled {
+			oneOf("OSS_TLS_ACME_CACHE", t.ACME.Cache, "valkey", "dir")
+			check("OSS_TLS_ACME_CACHE_DIR", t.ACME.Cache == "dir" && t.ACME.CacheDir == "", "must not be empty with dir cache")
+		} else {
+			check("OSS_TLS_CERT_FILE", t.CertFile == "", "must not be empty unless ACME is enabled")
+			check("OSS_TLS_KEY_FILE", t.KeyFile == "", "must not be empty unless ACME is enabled")
+		}
+		if t.RedirectAddr != "" {
+			if _, _, err := net.SplitHostPort(t.RedirectAddr); err != nil {
+				v.add("OSS_TLS_REDIRECT_ADDR", err)
+			}
+		}
+	}
+
+	a := c.Auth
+	check("OSS_AUTH_ENABLED", a.IsEnabled && (a.Username == "" || a.Password == "") && !a.OIDC.IsEnabled,
+		"requires OSS_AUTH_USERNAME and OSS_AUTH_PASSWORD or OIDC, nobody could sign in")
+	oneOf("OSS_AUTH_SESSION_STORE", a.Session.Store, "valkey", "memory")
+	check("OSS_AUTH_SESSION_IDLE_TIMEOUT", a.Session.IdleTimeout <= 0, "must be positive, got %s", a.Session.IdleTimeout)
+	check("OSS_AUTH_SESSION_ABSOLUTE_TIMEOUT", a.Session.AbsoluteTimeout <= 0, "must be positive, got %s", a.Session.AbsoluteTimeout)
+	if a.OIDC.IsEnabled {
+		check("OSS_AUTH_OIDC_ISSUER", a.OIDC.Issuer == "", "must not be empty when OIDC is enabled")
+		check("OSS_AUTH_OIDC_CLIENT_ID", a.OIDC.ClientID == "", "must not be empty when OIDC is enabled")
+	}
+
+	if r := c.RateLimit; r.IsEnabled {
+		oneOf("OSS_RATE_LIMIT_STORE", r.Store, "valkey", "memory")
+		positive("OSS_RATE_LIMIT_CREATE_PER_MINUTE", r.Create.PerMinute)
+		positive("OSS_RATE_LIMIT_CREATE_BURST", r.Create.Burst)
+		positive("OSS_RATE_LIMIT_REVEAL_PER_MINUTE", r.Reveal.PerMinute)
+		positive("OSS_RATE_LIMIT_REVEAL_BURST", r.Reveal.Burst)
+		positive("OSS_RATE_LIMIT_STATIC_PER_MINUTE", r.Static.PerMinute)
+		positive("OSS_RATE_LIMIT_STATIC_BURST", r.Static.Burst)
+	}
+
+	if l := c.Lockout; l.IsEnabled {
+		oneOf("OSS_LOCKOUT_STORE", l.Store, "valkey", "memory")
+		check("OSS_LOCKOUT_BURN_AFTER", l.BurnAfter < 0, "must not be negative, got %d", l.BurnAfter)
+		for _, w := range []struct {
+			prefix          string
+			maxFailures     int
+			window, lockout time.Duration
+		}{
+			{"OSS_LOCKOUT_SECRET_", l.Secret.MaxFailures, l.Secret.Window, l.Secret.Lockout},
+			{"OSS_LOCKOUT_IP_", l.IP.MaxFailures, l.IP.Window, l.IP.Lockout},
+			{"OSS_LOCKOUT_USERNAME_", l.Username.MaxFailures, l.Username.Window, l.Username.Lockout},
+		} {
+			positive(w.prefix+"MAX_FAILURES", w.maxFailures)
+			check(w.prefix+"WINDOW", w.window <= 0, "must be positive, got %s", w.window)
+			nonNegative(w.prefix+"LOCKOUT", w.lockout)
+		}
+	}
+
+	if c.Audit.IsEnabled && c.Audit.Syslog.IsEnabled {
+		check("OSS_AUDIT_SYSLOG_NETWORK", c.Audit.Syslog.Addr != "" && c.Audit.Syslog.Network == "",
+			"must not be empty with remote address")
+	}
+	check("OSS_AUDIT_STREAM_MAX_LEN", c.Audit.Stream.MaxLen < 0, "must not be negative, got %d", c.Audit.Stream.MaxLen)
+	check("OSS_NOTIFY_MAX_RETRIES", c.Notify.MaxRetries < 0, "must not be negative, got %d", c.Notify.MaxRetries)
+
+	if c.Metrics.IsEnabled {
+		check("OSS_METRICS_PATH", !strings.HasPrefix(c.Metrics.Path, "/"), "must start with /, got %q", c.Metrics.Path)
+		if c.Metrics.Addr != "" {
+			if _, _, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+				v.add("OSS_METRICS_ADDR", err)
+			}
+		}
+	}
+	if t := c.Tracing; t.IsEnabled {
+		oneOf("OSS_TRACING_EXPORTER", t.Exporter, "otlp", "stdout")
+		check("OSS_TRACING_SAMPLE_RATIO", t.SampleRatio < 0 || t.SampleRatio > 1, "must be between 0 and 1, got %g", t.SampleRatio)
+	}
+	return v.Fields
+}
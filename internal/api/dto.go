@@ -4,15 +4,81 @@ import (
 	"time"
 )
 
+const (
+	// SealedHeader carries format of secrets sealed to recipient public keys when revealed
+	SealedHeader = "X-OSS-Sealed"
+	// PassphraseHeader carries passphrase of protected secret when revealing it
+	PassphraseHeader = "X-OSS-Passphrase"
+)
+
 type (
 	SecretsRequestData struct {
-		Value      string  `json:"value"`
-		Password   *string `json:"password,omitempty"`
-		Expiration *int    `json:"expiration,omitempty"`
-		MaxViews   *uint64 `json:"max_views,omitempty"`
+		Value       string             `json:"value"`
+		Password    *string            `json:"password,omitempty"`
+		Expiration  *int               `json:"expiration,omitempty"`
+		MaxViews    *uint64            `json:"max_views,omitempty"`
+		Notify      *NotifyRequestData `json:"notify,omitempty"`
+		Recipients  []string           `json:"recipients,omitempty"`
+		Attachments []AttachmentData   `json:"attachments,omitempty"`
+	}
+	AttachmentData struct {
+		Name    string `json:"name"`
+		Content []byte `json:"content"`
+	}
+	NotifyRequestData struct {
+		Webhook string `json:"webhook,omitempty"`
+		Email   string `json:"email,omitempty"`
 	}
 	SecretResponseData struct {
 		Url       string    `json:"url"`
+		ManageUrl string    `json:"manage_url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	SecretRevealResponseData struct {
+		Value       string           `json:"value"`
+		Sealed      string           `json:"sealed,omitempty"`
+		Attachments []AttachmentData `json:"attachments,omitempty"`
+		ExpiresAt   time.Time        `json:"expires_at"`
+	}
+	SecretStatusResponseData struct {
+		State     string    `json:"state"`
+		Views     uint64    `json:"views"`
+		ViewsLeft uint64    `json:"views_left"`
+		MaxViews  uint64    `json:"max_views"`
+		CreatedAt time.Time `json:"created_at"`
 		ExpiresAt time.Time `json:"expires_at"`
 	}
+	SecretExtendRequestData struct {
+		Expiration int `json:"expiration"`
+	}
+	TokenCreateData struct {
+		Name       string   `json:"name"`
+		Scopes     []string `json:"scopes"`
+		Expiration *int     `json:"expiration,omitempty"`
+		RateLimit  *int     `json:"rate_limit,omitempty"`
+	}
+	TokenResponseData struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		RateLimit int        `json:"rate_limit"`
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		// Token is only returned once on creation
+		Token string `json:"token,omitempty"`
+	}
+	RequestCreateData struct {
+		Note       string  `json:"note,omitempty"`
+		Expiration *int    `json:"expiration,omitempty"`
+		MaxViews   *uint64 `json:"max_views,omitempty"`
+	}
+	RequestResponseData struct {
+		UploadUrl string    `json:"upload_url"`
+		RevealUrl string    `json:"reveal_url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	RequestStatusResponseData struct {
+		State string `json:"state"`
+		Url   string `json:"url,omitempty"`
+	}
 )
@@ -12,8 +12,12 @@ import (
 	"time"
 
 	"github.com/pudottapommin/golib/pkg/id"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/lockout"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/notify"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
 )
 
@@ -46,6 +50,40 @@ func (h *handlers) secretPUT(w http.ResponseWriter, r *http.Request) {
 		secret.SetPassphrase(*dto.Password)
 	}
 
+	for _, a := range dto.Attachments {
+		if a.Name == "" {
+			http.Error(w, "attachment name is required", http.StatusBadRequest)
+			return
+		}
+		secret.AddFile(a.Name, a.Content)
+	}
+
+	if len(dto.Recipients) > 0 {
+		recipients, err := encryption.ParseRecipients(dto.Recipients)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = secret.SealTo(recipients); err != nil {
+			h.l.Error("failed to seal secret to recipients", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var target *notify.Target
+	if dto.Notify != nil {
+		if h.svc.Notifier == nil {
+			http.Error(w, "notifications are disabled", http.StatusBadRequest)
+			return
+		}
+		target = &notify.Target{Webhook: dto.Notify.Webhook, Email: dto.Notify.Email}
+		if err := h.svc.Notifier.Validate(*target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	insert, err := h.db.Store(ctx, secret)
 	if err != nil {
 		slog.Error("failed to store secret", "error", err)
@@ -53,11 +91,22 @@ func (h *handlers) secretPUT(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if target != nil {
+		if err = h.svc.Notifier.Register(ctx, insert.ID, *target, insert.ExpiresAt); err != nil {
+			h.l.Error("failed to register notification", "error", err)
+			_ = h.db.Burn(ctx, insert.ID)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	encoder := jsontext.NewEncoder(w)
 	normalizedID := strings.ReplaceAll(string(sid), "-", "")
+	domain := h.cfg.Load().Server.Domain
 	if err = json.MarshalEncode(encoder, SecretResponseData{
-		Url:       fmt.Sprintf("%s/%x-%s", h.cfg.Load().Server.Domain, insert.Key, normalizedID),
+		Url:       fmt.Sprintf("%s/%x-%s", domain, insert.Key, normalizedID),
+		ManageUrl: secrets.ManageUrl(domain, insert.ID, insert.ManageToken),
 		ExpiresAt: insert.ExpiresAt,
 	}); err != nil {
 		slog.Error("failed to encode response", "error", err)
@@ -68,7 +117,7 @@ func (h *handlers) secretPUT(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) secretPOST(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	value := strings.TrimSpace(r.PathValue("value"))
 	if value == "" {
@@ -76,15 +125,18 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parts := strings.Split(value, "-")
-	encKey, err := hex.DecodeString(parts[0])
+	hexKey, sid, ok := secrets.ParseRevealValue(value)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	encKey, err := hex.DecodeString(hexKey)
 	if err != nil {
 		h.l.Error("failed to decode encryption key", slog.Any("err", err), slog.String("path", r.URL.Path))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	sid := storage.ID(parts[1])
 	secret, err := h.db.Get(ctx, sid, encKey)
 	switch {
 	case errors.Is(err, storage.ErrRecordNotFound) || (err == nil && secret == nil):
@@ -96,7 +148,150 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "text/plain")
-	_, _ = fmt.Fprintf(w, "%s", secret.Value())
+	err = h.svc.VerifyPassphrase(ctx, sid, secret.Passphrase(), r.Header.Get(PassphraseHeader), server.ClientIP(r))
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		server.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, storage.ErrRecordBurned):
+		http.Error(w, "too many wrong passphrases, secret was burned", http.StatusGone)
+		return
+	case errors.Is(err, services.ErrWrongPassphrase):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		h.l.Error("failed to verify passphrase", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err = h.db.Viewed(ctx, sid); err != nil {
+		slog.Error("failed to mark secret as viewed", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	format := encryption.DetectFormat([]byte(secret.Value()))
+	if format != encryption.FormatNone {
+		w.Header().Set(SealedHeader, string(format))
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "%s", secret.Value())
+		return
+	}
+
+	dto := SecretRevealResponseData{
+		Value:     secret.Value(),
+		Sealed:    string(format),
+		ExpiresAt: secret.ExpiresAt(),
+	}
+	for _, f := range secret.Files() {
+		dto.Attachments = append(dto.Attachments, AttachmentData{Name: f.Name, Content: f.Content})
+	}
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
+func (h *handlers) manageGET(w http.ResponseWriter, r *http.Request) {
+	sid, ok := h.authorizeManage(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.db.Status(r.Context(), sid)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	h.writeStatus(w, status)
+}
+
+func (h *handlers) managePATCH(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sid, ok := h.authorizeManage(w, r)
+	if !ok {
+		return
+	}
+
+	var dto SecretExtendRequestData
+	defer r.Body.Close()
+	if err := json.UnmarshalDecode(jsontext.NewDecoder(r.Body), &dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok = secrets.ExpirationRanges[dto.Expiration]; !ok {
+		http.Error(w, "invalid expiration", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Extend(ctx, sid, time.Second*time.Duration(dto.Expiration)); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	status, err := h.db.Status(ctx, sid)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	h.writeStatus(w, status)
+}
+
+func (h *handlers) manageDELETE(w http.ResponseWriter, r *http.Request) {
+	sid, ok := h.authorizeManage(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Burn(r.Context(), sid); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) authorizeManage(w http.ResponseWriter, r *http.Request) (storage.ID, bool) {
+	sid, token, ok := secrets.ParseManageValue(r.PathValue("value"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	}
+	if err := h.db.VerifyManageToken(r.Context(), sid, token); err != nil {
+		h.writeStorageError(w, err)
+		return "", false
+	}
+	return sid, true
+}
+
+func (h *handlers) writeStatus(w http.ResponseWriter, status *storage.RecordStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.MarshalEncode(jsontext.NewEncoder(w), SecretStatusResponseData{
+		State:     string(status.State),
+		Views:     status.Views,
+		ViewsLeft: status.ViewsLeft,
+		MaxViews:  status.MaxViews,
+		CreatedAt: status.CreatedAt,
+		ExpiresAt: status.ExpiresAt,
+	}); err != nil {
+		h.l.Error("failed to encode response", "error", err)
+		w.Header().Del("Content-Type")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrRecordNotFound), errors.Is(err, storage.ErrInvalidManageToken):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, storage.ErrRecordBurned):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, storage.ErrLifetimeExceeded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.l.Error("storage operation failed", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
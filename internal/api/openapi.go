@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"net/http"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/openapi"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+)
+
+const (
+	basicAuthScheme  = "basicAuth"
+	bearerAuthScheme = "bearerAuth"
+)
+
+// operations documents every route registered in AddHandlers, keyed by "METHOD path"
+var operations = map[string]openapi.Op{
+	"GET /api/openapi.json": {
+		Summary:   "OpenAPI document of this API",
+		Responses: map[int]any{http.StatusOK: map[string]any{}},
+	},
+	"PUT /api/create": {
+		Summary: "Create secret",
+		Request: SecretsRequestData{},
+		Responses: map[int]any{
+			http.StatusOK:           SecretResponseData{},
+			http.StatusBadRequest:   "",
+			http.StatusUnauthorized: nil,
+		},
+	},
+	"POST /api/requests": {
+		Summary: "Request secret from someone else",
+		Request: RequestCreateData{},
+		Responses: map[int]any{
+			http.StatusCreated:      RequestResponseData{},
+			http.StatusBadRequest:   "",
+			http.StatusUnauthorized: nil,
+		},
+	},
+	"GET /api/admin/tokens": {
+		Summary: "List API tokens",
+		Responses: map[int]any{
+			http.StatusOK:           []TokenResponseData{},
+			http.StatusUnauthorized: nil,
+			http.StatusForbidden:    "",
+		},
+	},
+	"POST /api/admin/tokens": {
+		Summary: "Create API token, its value is returned only once",
+		Request: TokenCreateData{},
+		Responses: map[int]any{
+			http.StatusCreated:      TokenResponseData{},
+			http.StatusBadRequest:   "",
+			http.StatusUnauthorized: nil,
+			http.StatusForbidden:    "",
+		},
+	},
+	"DELETE /api/admin/tokens/:value": {
+		Summary: "Revoke API token",
+		Responses: map[int]any{
+			http.StatusNoContent:    nil,
+			http.StatusUnauthorized: nil,
+			http.StatusForbidden:    "",
+			http.StatusNotFound:     nil,
+		},
+	},
+	"DELETE /api/secrets/:value": {
+		Summary: "Burn secret by ID",
+		Responses: map[int]any{
+			http.StatusNoContent:    nil,
+			http.StatusUnauthorized: nil,
+			http.StatusForbidden:    "",
+			http.StatusNotFound:     nil,
+		},
+	},
+	"PUT /api/requests/upload/:value": {
+		Summary: "Fulfill secret request",
+		Request: SecretsRequestData{},
+		Responses: map[int]any{
+			http.StatusNoContent:  nil,
+			http.StatusBadRequest: "",
+			http.StatusNotFound:   nil,
+			http.StatusGone:       "",
+		},
+	},
+	"POST /api/requests/reveal/:value": {
+		Summary: "Secret request state and secret link once fulfilled",
+		Responses: map[int]any{
+			http.StatusOK:       RequestStatusResponseData{},
+			http.StatusNotFound: nil,
+		},
+	},
+	"GET /api/manage/:value": {
+		Summary: "Secret status",
+		Responses: map[int]any{
+			http.StatusOK:       SecretStatusResponseData{},
+			http.StatusNotFound: nil,
+		},
+	},
+	"PATCH /api/manage/:value": {
+		Summary: "Extend secret expiration",
+		Request: SecretExtendRequestData{},
+		Responses: map[int]any{
+			http.StatusOK:         SecretStatusResponseData{},
+			http.StatusBadRequest: "",
+			http.StatusNotFound:   nil,
+			http.StatusGone:       "",
+		},
+	},
+	"DELETE /api/manage/:value": {
+		Summary: "Burn secret",
+		Responses: map[int]any{
+			http.StatusNoContent: nil,
+			http.StatusNotFound:  nil,
+		},
+	},
+	"POST /api/:value": {
+		Summary: "Reveal secret, text/plain unless JSON is accepted, passphrase is sent in X-OSS-Passphrase header",
+		Responses: map[int]any{
+			http.StatusOK:              SecretRevealResponseData{},
+			http.StatusForbidden:       "",
+			http.StatusNotFound:        nil,
+			http.StatusGone:            "",
+			http.StatusTooManyRequests: "",
+		},
+	},
+}
+
+// OpenAPI builds document for routes registered by AddHandlers, routes without operation are left out
+func (h *handlers) OpenAPI() *openapi.Document {
+	b := openapi.New("One-time secrets service", "1.0.0", h.cfg.Load().Server.Domain).
+		SecurityScheme(basicAuthScheme, "basic").
+		SecurityScheme(bearerAuthScheme, "bearer")
+	for _, route := range h.guard.Routes() {
+		op, ok := operations[route.Method+" "+route.Path]
+		if !ok {
+			continue
+		}
+		// security follows route policy so document can't drift from enforcement
+		op.Security = nil
+		if route.Policy.Accepts(server.CredentialBasic) {
+			op.Security = append(op.Security, basicAuthScheme)
+		}
+		if route.Policy.Accepts(server.CredentialToken) {
+			op.Security = append(op.Security, bearerAuthScheme)
+		}
+		b.Add(route.Method, route.Path, op)
+	}
+	return b.Document()
+}
+
+func (h *handlers) openapiGET(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.MarshalEncode(jsontext.NewEncoder(w), h.OpenAPI(), json.Deterministic(true)); err != nil {
+		h.l.Error("failed to encode openapi document", "error", err)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/requests"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+const defaultRequestExpiration = 604800
+
+func (h *handlers) requestPOST(w http.ResponseWriter, r *http.Request) {
+	var dto RequestCreateData
+	defer r.Body.Close()
+	if err := json.UnmarshalDecode(jsontext.NewDecoder(r.Body), &dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expiration := defaultRequestExpiration
+	if dto.Expiration != nil {
+		if _, ok := secrets.ExpirationRanges[*dto.Expiration]; !ok {
+			http.Error(w, "invalid expiration", http.StatusBadRequest)
+			return
+		}
+		expiration = *dto.Expiration
+	}
+	maxViews := uint64(1)
+	if dto.MaxViews != nil && *dto.MaxViews > 1 {
+		maxViews = *dto.MaxViews
+	}
+
+	req, identity, err := h.svc.CreateRequest(r.Context(), dto.Note, time.Second*time.Duration(expiration), maxViews)
+	if err != nil {
+		h.l.Error("failed to store request", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	domain := h.cfg.Load().Server.Domain
+	h.writeJSON(w, http.StatusCreated, RequestResponseData{
+		UploadUrl: secrets.RequestUploadUrl(domain, string(req.ID)),
+		RevealUrl: secrets.RequestRevealUrl(domain, string(req.ID), identity),
+		ExpiresAt: req.ExpiresAt,
+	})
+}
+
+func (h *handlers) requestUploadPUT(w http.ResponseWriter, r *http.Request) {
+	var dto SecretsRequestData
+	defer r.Body.Close()
+	if err := json.UnmarshalDecode(jsontext.NewDecoder(r.Body), &dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dto.Value == "" {
+		http.Error(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	upload := services.RequestUpload{Value: dto.Value}
+	if dto.Password != nil {
+		upload.Passphrase = *dto.Password
+	}
+	for _, a := range dto.Attachments {
+		if a.Name == "" {
+			http.Error(w, "attachment name is required", http.StatusBadRequest)
+			return
+		}
+		upload.Files = append(upload.Files, &storage.FileRecord{Name: a.Name, Content: a.Content})
+	}
+	err := h.svc.FulfillRequest(r.Context(), requests.ID(strings.TrimSpace(r.PathValue("value"))), upload)
+	switch {
+	case errors.Is(err, requests.ErrRequestNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, requests.ErrAlreadyFulfilled):
+		http.Error(w, err.Error(), http.StatusGone)
+	case err != nil:
+		h.l.Error("failed to fulfill request", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handlers) requestRevealPOST(w http.ResponseWriter, r *http.Request) {
+	rid, identity, ok := secrets.ParseRequestRevealValue(r.PathValue("value"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	req, key, err := h.svc.RevealRequest(r.Context(), requests.ID(rid), identity)
+	switch {
+	case errors.Is(err, requests.ErrRequestNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		h.l.Error("failed to reveal request", "error", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	dto := RequestStatusResponseData{State: string(req.State)}
+	if key != nil {
+		dto.Url = secrets.RevealUrl(h.cfg.Load().Server.Domain, key, req.SecretID)
+	}
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
+func (h *handlers) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.MarshalEncode(jsontext.NewEncoder(w), v); err != nil {
+		h.l.Error("failed to encode response", "error", err)
+	}
+}
@@ -7,56 +7,71 @@ import (
 
 	"github.com/alexedwards/flow"
 	"github.com/pudottapommin/onetime-secrets-service/config"
-	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
-	"github.com/valkey-io/valkey-go"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
 )
 
 type handlers struct {
-	l   *slog.Logger
-	cfg *atomic.Pointer[config.Config]
-	db  storage.Storage[storage.ID, storage.Key]
+	l     *slog.Logger
+	cfg   *atomic.Pointer[config.Config]
+	db    storage.Storage[storage.ID, storage.Key]
+	svc   *services.Services
+	guard *server.Guard
 }
 
-func NewHandlers(cfg *atomic.Pointer[config.Config], client valkey.Client, l *slog.Logger) *handlers {
-	var encryptor storage.Encryptor
-	if cfg.Load().SecretKey != nil {
-		encryptor, _ = storage.NewDefaultEncryptor(cfg.Load().SecretKey)
-	}
+func NewHandlers(cfg *atomic.Pointer[config.Config], svc *services.Services, l *slog.Logger) *handlers {
 	return &handlers{
 		cfg: cfg,
 		l:   l,
-		db: storage.NewValkey(client,
-			encryptor,
-			func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
-				return secrets.NewSecret(id, key)
+		db:  svc.Storage,
+		svc: svc,
+		guard: server.NewGuard(
+			server.WithGuardLogger(l),
+			server.WithAuthEnabled(func() bool { return cfg.Load().Auth.IsEnabled }),
+			server.WithBasicCredentials(func() (string, string) {
+				c := cfg.Load()
+				return c.Auth.Username, c.Auth.Password
 			}),
+			server.WithTokenAuth(svc.Tokens),
+		),
 	}
 }
 
 func (h *handlers) AddHandlers(e *flow.Mux) {
-	e.Group(func(g *flow.Mux) {
-		g.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				cfg := h.cfg.Load()
-				if !cfg.Auth.IsEnabled {
-					next.ServeHTTP(w, r)
-					return
-				}
-
-				switch r.Method {
-				case http.MethodPost:
-					if err := server.AuthValidateHeader(r, cfg.Auth.Username, cfg.Auth.Password); err != nil {
-						w.WriteHeader(http.StatusUnauthorized)
-						return
-					}
-				}
-				next.ServeHTTP(w, r)
-			})
-		})
+	create := server.BasicAuth().Or(server.TokenAuth(tokens.ScopeSecretsCreate))
+	admin := server.BasicAuth().Or(server.TokenAuth(tokens.ScopeAdmin)).Required()
+	burn := server.BasicAuth().Or(server.TokenAuth(tokens.ScopeSecretsBurn)).Required()
 
-		g.HandleFunc("/api/create", h.secretPUT, "PUT")
+	// create budget is taken after guard, so token clients are limited per verified token
+	limitCreate := h.svc.Limiter.Limit(server.BudgetCreate)
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute)
+		h.guard.Handle(g, http.MethodPut, "/api/create", create, h.secretPUT, limitCreate)
+		h.guard.Handle(g, http.MethodPost, "/api/requests", create, h.requestPOST, limitCreate)
+		h.guard.Handle(g, http.MethodPut, "/api/requests/upload/:value", server.Public, h.requestUploadPUT, limitCreate)
+	})
+	h.guard.Handle(e, http.MethodGet, "/api/admin/tokens", admin, h.tokensGET)
+	h.guard.Handle(e, http.MethodPost, "/api/admin/tokens", admin, h.tokensPOST)
+	h.guard.Handle(e, http.MethodDelete, "/api/admin/tokens/:value", admin, h.tokenDELETE)
+	h.guard.Handle(e, http.MethodDelete, "/api/secrets/:value", burn, h.secretDELETE)
+	h.guard.Handle(e, http.MethodGet, "/api/openapi.json", server.Public, h.openapiGET)
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute)
+		h.guard.Handle(g, http.MethodGet, "/api/manage/:value", server.Public, h.manageGET)
+		h.guard.Handle(g, http.MethodPatch, "/api/manage/:value", server.Public, h.managePATCH)
+		h.guard.Handle(g, http.MethodDelete, "/api/manage/:value", server.Public, h.manageDELETE)
+	})
+	// revealing takes POST, so link unfurlers and crawlers fetching links never consume views
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute, h.svc.Limiter.Limit(server.BudgetReveal))
+		h.guard.Handle(g, http.MethodPost, "/api/requests/reveal/:value", server.Public, h.requestRevealPOST)
+		h.guard.Handle(g, http.MethodPost, "/api/:value", server.Public, h.secretPOST)
 	})
-	e.HandleFunc("/api/:value", h.secretGET, "GET")
+}
+
+// Routes lists every route registered by AddHandlers with its auth policy
+func (h *handlers) Routes() []server.Route {
+	return h.guard.Routes()
 }
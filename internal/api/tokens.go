@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+)
+
+func (h *handlers) tokensGET(w http.ResponseWriter, r *http.Request) {
+	list, err := h.svc.Tokens.List(r.Context())
+	if err != nil {
+		h.l.Error("failed to list tokens", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dto := make([]TokenResponseData, 0, len(list))
+	for _, t := range list {
+		dto = append(dto, newTokenResponseData(t, ""))
+	}
+	h.writeJSON(w, http.StatusOK, dto)
+}
+
+func (h *handlers) tokensPOST(w http.ResponseWriter, r *http.Request) {
+	var dto TokenCreateData
+	defer r.Body.Close()
+	if err := json.UnmarshalDecode(jsontext.NewDecoder(r.Body), &dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scopes, err := tokens.ParseScopes(dto.Scopes...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t := &tokens.Token{Name: strings.TrimSpace(dto.Name), Scopes: scopes}
+	if t.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if dto.Expiration != nil {
+		if *dto.Expiration <= 0 {
+			http.Error(w, "invalid expiration", http.StatusBadRequest)
+			return
+		}
+		t.ExpiresAt = time.Now().Add(time.Second * time.Duration(*dto.Expiration)).UTC()
+	}
+	if dto.RateLimit != nil {
+		if *dto.RateLimit < 0 {
+			http.Error(w, "invalid rate limit", http.StatusBadRequest)
+			return
+		}
+		t.RateLimit = *dto.RateLimit
+	}
+
+	value, err := h.svc.Tokens.Create(r.Context(), t)
+	if err != nil {
+		h.l.Error("failed to create token", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.svc.Audit.Record(r.Context(), audit.Event{Action: audit.ActionTokenCreated, Target: string(t.ID)})
+	h.writeJSON(w, http.StatusCreated, newTokenResponseData(t, value))
+}
+
+func (h *handlers) tokenDELETE(w http.ResponseWriter, r *http.Request) {
+	tid := tokens.ID(r.PathValue("value"))
+	err := h.svc.Tokens.Revoke(r.Context(), tid)
+	switch {
+	case errors.Is(err, tokens.ErrTokenNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case err != nil:
+		h.l.Error("failed to revoke token", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		h.svc.Audit.Record(r.Context(), audit.Event{Action: audit.ActionTokenRevoked, Target: string(tid)})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// secretDELETE burns secret by its ID, it is meant for tokens with secrets:burn scope
+func (h *handlers) secretDELETE(w http.ResponseWriter, r *http.Request) {
+	sid := storage.ID(r.PathValue("value"))
+	if _, err := h.db.Status(r.Context(), sid); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	if err := h.db.Burn(r.Context(), sid); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTokenResponseData(t *tokens.Token, value string) TokenResponseData {
+	dto := TokenResponseData{
+		ID:        string(t.ID),
+		Name:      t.Name,
+		RateLimit: t.RateLimit,
+		CreatedAt: t.CreatedAt,
+		Token:     value,
+	}
+	for _, s := range t.Scopes {
+		dto.Scopes = append(dto.Scopes, string(s))
+	}
+	if !t.ExpiresAt.IsZero() {
+		dto.ExpiresAt = &t.ExpiresAt
+	}
+	return dto
+}
@@ -2,11 +2,15 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/alexedwards/flow"
@@ -20,26 +24,58 @@ import (
 	"github.com/pudottapommin/onetime-secrets-service/assets"
 	"github.com/pudottapommin/onetime-secrets-service/config"
 	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
 	"github.com/pudottapommin/onetime-secrets-service/internal/ui"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tracing"
 	pui "github.com/pudottapommin/onetime-secrets-service/pkg/ui"
 	"github.com/valkey-io/valkey-go"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type App struct {
 	*server.Server
 	db  valkey.Client
+	m   *metrics.Metrics
 	cfg *atomic.Pointer[config.Config]
 	l   *slog.Logger
+
+	// mu serializes reloads and guards onReload hooks
+	mu       sync.Mutex
+	onReload []func(*config.Config)
 }
 
-func New(ctx context.Context, db valkey.Client, cfg *atomic.Pointer[config.Config], l *slog.Logger) *App {
-	return &App{Server: server.New(ctx, flow.New()), db: db, cfg: cfg, l: l}
+func New(ctx context.Context, db valkey.Client, m *metrics.Metrics, cfg *atomic.Pointer[config.Config], l *slog.Logger) *App {
+	sc := cfg.Load().Server
+	s := server.New(ctx, flow.New(),
+		server.WithLogger(l),
+		server.WithHealth(server.NewHealth(server.WithHealthLogger(l))),
+		server.WithDrainDelay(sc.DrainDelay),
+		server.WithShutdownTimeout(sc.ShutdownTimeout),
+		server.WithStreamWriteTimeout(sc.StreamWriteTimeout),
+		server.WithLimits(server.Limits{
+			ReadHeaderTimeout: sc.ReadHeaderTimeout,
+			ReadTimeout:       sc.ReadTimeout,
+			WriteTimeout:      sc.WriteTimeout,
+			IdleTimeout:       sc.IdleTimeout,
+			MaxHeaderBytes:    sc.MaxHeaderBytes,
+		}))
+	return &App{Server: s, db: db, m: m, cfg: cfg, l: l}
 }
 
 func (a *App) Run() (err error) {
 	cfg := a.cfg.Load()
 
+	if cfg.Tracing.IsEnabled {
+		a.E().Use(tracing.Middleware)
+	}
+	if cfg.Metrics.IsEnabled {
+		a.E().Use(a.m.Middleware)
+	}
+
 	// if enabled, reload template from FS
 	if cfg.Server.UIHotReload {
 		a.E().Use(func(next http.Handler) http.Handler {
@@ -50,10 +86,18 @@ func (a *App) Run() (err error) {
 		})
 	}
 
+	trusted, err := server.ParsePrefixes(cfg.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 	a.E().Use(
+		securityHeaders(a.cfg),
+		server.TrustProxies(cfg.Server.ClientIPHeader, trusted),
 		requestid.New().Handler,
+		audit.Middleware,
 		logger.New(logger.WithLogger(a.l, "[HTTP]"), logger.WithNext(func(w http.ResponseWriter, r *http.Request) bool {
-			return strings.HasPrefix(r.URL.Path, "/static") || strings.HasPrefix(r.URL.Path, "/.well-known")
+			return strings.HasPrefix(r.URL.Path, "/static") || strings.HasPrefix(r.URL.Path, "/.well-known") ||
+				r.URL.Path == "/healthz" || r.URL.Path == "/readyz"
 		})).Handler,
 		compressor.MustNew(),
 	)
@@ -66,9 +110,35 @@ func (a *App) Run() (err error) {
 		a.E().Use(csrf.New(sc, csrf.WithCookieName("oss_csrf")).Handler)
 	}
 
-	api.NewHandlers(a.cfg, a.db, a.l).AddHandlers(a.E())
+	svc, err := services.New(a.cfg, a.db, a.m, a.l)
+	if err != nil {
+		return fmt.Errorf("failed to create services: %w", err)
+	}
+	a.OnReload(svc.Reload)
+	defer func() {
+		if err := svc.Audit.Close(); err != nil {
+			a.l.Error("failed to close audit sinks", "error", err)
+		}
+	}()
+	if svc.Notifier != nil {
+		go svc.Notifier.Run(a.Server.Ctx())
+	}
+	if w, ok := svc.Storage.(storage.ExpiryWatcher); ok && cfg.Server.ExpiryEvents {
+		go func() {
+			if err := w.WatchExpired(a.Server.Ctx(), a.l); err != nil {
+				a.l.Error("expiry watcher stopped", "error", err)
+			}
+		}()
+	}
+
+	a.addHealth(cfg)
+	api.NewHandlers(a.cfg, svc, a.l).AddHandlers(a.E())
 	if cfg.Server.UI {
-		ui.NewHandlers(a.cfg, a.db, a.l).AddHandlers(a.E())
+		ui.NewHandlers(a.cfg, svc, a.l).AddHandlers(a.E())
+	}
+
+	if cfg.Metrics.IsEnabled {
+		a.serveMetrics(cfg)
 	}
 
 	if cfg.Pprof.IsEnabled {
@@ -81,16 +151,128 @@ func (a *App) Run() (err error) {
 		})
 	}
 
-	if cfg.Auth.IsEnabled {
-		go server.AuthTokenCleanup(a.Server.Ctx())
+	if p, ok := svc.Sessions.Store().(server.SessionPruner); ok {
+		go p.Prune(a.Server.Ctx())
 	}
 
 	a.E().Group(func(r *flow.Mux) {
+		r.Use(svc.Limiter.Limit(server.BudgetStatic), server.CacheControl("no-cache"))
 		ls := assetsfs.NewLayered(assets.BuiltinAssets())
-		r.Handle("/static/...", http.StripPrefix("/static/",
-			static.New(ls, static.WithEtag(), static.WithSetProd(cfg.IsProd))))
+		r.Handle("/static/...", server.TagRoute("/static/...", http.StripPrefix("/static/",
+			static.New(ls, static.WithEtag(), static.WithSetProd(cfg.IsProd)))))
 	})
 
-	a.l.Debug("Server started", "address", cfg.Server.Addr)
-	return a.Server.Run(cfg.Server.Addr)
+	if !cfg.TLS.IsEnabled {
+		a.l.Debug("Server started", "address", cfg.Server.Addr)
+		return a.Server.Run(cfg.Server.Addr)
+	}
+
+	tc, redirect, err := a.tlsConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.TLS.RedirectAddr != "" {
+		a.serveRedirect(cfg, redirect)
+	}
+	a.l.Debug("Server started with TLS", "address", cfg.Server.Addr)
+	return a.Server.RunTLS(cfg.Server.Addr, tc)
+}
+
+// tlsConfig serves certificates from ACME CA or rotated files, returned handler redirects plain HTTP to HTTPS
+func (a *App) tlsConfig(cfg *config.Config) (*tls.Config, http.Handler, error) {
+	redirect := server.RedirectHTTPS(cfg.Server.Addr)
+	if cfg.TLS.ACME.IsEnabled {
+		ac := cfg.TLS.ACME
+		var cache autocert.Cache = server.NewValkeyCertCache(a.db)
+		if ac.Cache == "dir" {
+			cache = autocert.DirCache(ac.CacheDir)
+		}
+		acme := server.ACMEConfig{Domains: ac.Domains, Email: ac.Email, DirectoryURL: ac.DirectoryURL, Cache: cache}
+		if len(ac.Domains) == 0 {
+			// host of server domain is looked up per handshake, so reloaded domain gets its certificate
+			acme.HostPolicy = func(ctx context.Context, host string) error {
+				u, err := url.Parse(a.cfg.Load().Server.Domain)
+				if err != nil {
+					return fmt.Errorf("invalid server domain: %w", err)
+				}
+				return autocert.HostWhitelist(u.Hostname())(ctx, host)
+			}
+		}
+		m := server.NewACMEManager(acme)
+		tc := m.TLSConfig()
+		tc.MinVersion = tls.VersionTLS12
+		return tc, m.HTTPHandler(redirect), nil
+	}
+
+	if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+		return nil, nil, errors.New("tls is enabled without certificate files or ACME")
+	}
+	r, err := server.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, a.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: r.GetCertificate}, redirect, nil
+}
+
+// serveRedirect serves plain HTTP listener redirecting to HTTPS
+func (a *App) serveRedirect(cfg *config.Config, h http.Handler) {
+	rs := server.New(a.Server.Ctx(), flow.New(), server.WithLogger(a.l), server.WithShutdownTimeout(cfg.Server.ShutdownTimeout))
+	rs.E().Handle("/...", h)
+	go func() {
+		a.l.Debug("Redirect server started", "address", cfg.TLS.RedirectAddr)
+		if err := rs.Run(cfg.TLS.RedirectAddr); err != nil {
+			a.l.Error("redirect server stopped", "error", err)
+		}
+	}()
+}
+
+// securityHeaders sends HSTS only when site is served with TLS, here or by proxy in front of it. Domain is
+// checked per request, so it follows reloaded config.
+func securityHeaders(cfg *atomic.Pointer[config.Config]) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withHSTS := server.SecurityHeaders(cfg.Load().Server.HSTSMaxAge)(next)
+		withoutHSTS := server.SecurityHeaders(0)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c := cfg.Load(); c.TLS.IsEnabled || strings.HasPrefix(c.Server.Domain, "https://") {
+				withHSTS.ServeHTTP(w, r)
+				return
+			}
+			withoutHSTS.ServeHTTP(w, r)
+		})
+	}
+}
+
+// addHealth serves probes, readiness checks Valkey, key material and templates when UI is served
+func (a *App) addHealth(cfg *config.Config) {
+	h := a.Health()
+	h.AddCheck("valkey", func(ctx context.Context) error {
+		return a.db.Do(ctx, a.db.B().Ping().Build()).Error()
+	})
+	h.AddCheck("keys", func(context.Context) error {
+		return a.cfg.Load().CheckKeys()
+	})
+	if cfg.Server.UI {
+		h.AddCheck("templates", func(context.Context) error {
+			return pui.Compiled()
+		})
+	}
+	a.E().Handle("/healthz", server.TagRoute("/healthz", http.HandlerFunc(h.Live)), http.MethodGet)
+	a.E().Handle("/readyz", server.TagRoute("/readyz", http.HandlerFunc(h.Ready)), http.MethodGet)
+}
+
+// serveMetrics serves metrics on app listener, or on separate one when metrics address is set
+func (a *App) serveMetrics(cfg *config.Config) {
+	if cfg.Metrics.Addr == "" {
+		a.E().Handle(cfg.Metrics.Path, server.TagRoute(cfg.Metrics.Path, a.m.Handler()), http.MethodGet)
+		return
+	}
+
+	ms := server.New(a.Server.Ctx(), flow.New(), server.WithLogger(a.l), server.WithShutdownTimeout(cfg.Server.ShutdownTimeout))
+	ms.E().Handle(cfg.Metrics.Path, a.m.Handler(), http.MethodGet)
+	go func() {
+		a.l.Debug("Metrics server started", "address", cfg.Metrics.Addr)
+		if err := ms.Run(cfg.Metrics.Addr); err != nil {
+			a.l.Error("metrics server stopped", "error", err)
+		}
+	}()
 }
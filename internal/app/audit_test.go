@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pudottapommin/golib/http/middleware/requestid"
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTrail(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	a := newPolicyApp(t, func(cfg *config.Config) {
+		cfg.Audit.IsEnabled = true
+		cfg.Audit.File = logPath
+	})
+	a.handler = requestid.New().Handler(audit.Middleware(a.mux))
+
+	send := func(method, target, body string, mutate func(*http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		if mutate != nil {
+			mutate(r)
+		}
+		rec := httptest.NewRecorder()
+		a.handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	created := a.createSecret(t, `{"value":"hunter2","password":"correct horse"}`, func(r *http.Request) {
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Request-ID", "create-1")
+	})
+	reveal := "/api/" + created.value
+	sid := string(created.id)
+
+	rec := send(http.MethodPost, reveal, "", func(r *http.Request) { r.Header.Set(api.PassphraseHeader, "wrong") })
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = send(http.MethodPost, reveal, "", func(r *http.Request) { r.Header.Set(api.PassphraseHeader, "correct horse") })
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hunter2", rec.Body.String())
+
+	require.NoError(t, a.svc.Audit.Close())
+	f, err := os.Open(logPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		assert.NotContains(t, scanner.Text(), "hunter2", "secret content must never be audited")
+		assert.NotContains(t, scanner.Text(), "correct horse")
+		var e audit.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	// last view burns the secret
+	require.Len(t, events, 4)
+
+	assert.Equal(t, audit.ActionSecretCreated, events[0].Action)
+	assert.Equal(t, "create-1", events[0].RequestID)
+	assert.Equal(t, "admin", events[0].Actor)
+	assert.Equal(t, "basic", events[0].Credential)
+
+	assert.Equal(t, audit.ActionSecretUnlockFailed, events[1].Action)
+	assert.Equal(t, "wrong_passphrase", events[1].Reason)
+	assert.Equal(t, audit.ActionSecretViewed, events[2].Action)
+	assert.Equal(t, audit.ActionSecretBurned, events[3].Action)
+	assert.Equal(t, events[2].RequestID, events[3].RequestID)
+	for _, e := range events {
+		assert.Equal(t, sid, e.Target)
+		assert.Equal(t, "10.0.0.1", e.ClientIP)
+		assert.NotEmpty(t, e.RequestID)
+	}
+	for _, e := range events[1:] {
+		assert.Equal(t, audit.ActorAnonymous, e.Actor)
+	}
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	mserver "github.com/alicebob/miniredis/v2/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/notify"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+// fakeKeyspaceConfig answers CONFIG GET and SET of notify-keyspace-events, miniredis has no CONFIG
+type fakeKeyspaceConfig struct {
+	mu    sync.Mutex
+	flags string
+	sets  []string
+}
+
+func (f *fakeKeyspaceConfig) serve(c *mserver.Peer, _ string, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		c.WriteMapLen(1)
+		c.WriteBulk("notify-keyspace-events")
+		c.WriteBulk(f.flags)
+	case "SET":
+		f.flags = args[2]
+		f.sets = append(f.sets, args[2])
+		c.WriteOK()
+	}
+}
+
+func TestWatchExpired(t *testing.T) {
+	ctx := t.Context()
+	mr := miniredis.RunT(t)
+	keyspace := &fakeKeyspaceConfig{flags: "Kl"}
+	require.NoError(t, mr.Server().Register("CONFIG", keyspace.serve))
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	payloads := make(chan notify.Payload, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p notify.Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		payloads <- p
+	}))
+	defer srv.Close()
+	l := slog.New(slog.DiscardHandler)
+	n := notify.New(client, nil, l, notify.WithHTTPClient(srv.Client()))
+	go n.Run(ctx)
+
+	events := make(chan storage.RecordEvent, 8)
+	s := storage.NewValkey(client, nil,
+		func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
+			return secrets.NewSecret(id, key)
+		},
+		storage.ObserverFunc(func(_ context.Context, e storage.RecordEvent) {
+			if e.Type == storage.RecordEventExpired {
+				events <- e
+			}
+		}), n)
+
+	store := func(id storage.ID, maxViews uint64) {
+		secret := secrets.NewSecret(id, encryption.GenerateNewKey(32))
+		secret.SetValue("hunter2")
+		secret.SetMaxViews(maxViews)
+		insert, err := s.Store(ctx, secret)
+		require.NoError(t, err)
+		require.NoError(t, n.Register(ctx, id, notify.Target{Webhook: srv.URL + "/" + string(id)}, insert.ExpiresAt))
+	}
+	store("unread", 1)
+	store("partly", 2)
+	require.NoError(t, s.Viewed(ctx, "partly"))
+	assert.Equal(t, notify.EventFirstView, (<-payloads).Event)
+
+	go func() { _ = s.(storage.ExpiryWatcher).WatchExpired(ctx, l) }()
+	require.Eventually(t, func() bool { return mr.PubSubNumPat() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"KlEx"}, keyspace.sets, "missing flags are added to those set by operator")
+
+	expire := func(key string) {
+		mr.Del(key)
+		mr.Publish("__keyevent@0__:expired", key)
+	}
+	expire("unread_counter")
+	expire("unread")
+	expire("unread")
+
+	e := <-events
+	assert.Equal(t, storage.ID("unread"), e.ID)
+	assert.Zero(t, e.Views)
+	p := <-payloads
+	assert.Equal(t, notify.EventExpired, p.Event)
+	assert.Equal(t, "unread", p.SecretID)
+	assert.Zero(t, p.Views)
+	status, err := s.Status(ctx, "unread")
+	require.NoError(t, err)
+	assert.Equal(t, storage.RecordStateExpired, status.State)
+
+	expire("partly")
+	e = <-events
+	assert.Equal(t, storage.ID("partly"), e.ID)
+	assert.EqualValues(t, 1, e.Views, "views used before expiry are reported")
+	p = <-payloads
+	assert.Equal(t, "partly", p.SecretID)
+	assert.EqualValues(t, 1, p.Views)
+
+	select {
+	case e := <-events:
+		t.Fatalf("expiry must be reported once, got %+v", e)
+	case p := <-payloads:
+		t.Fatalf("expiry must be notified once, got %+v", p)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestWatchExpiredKeepsKeyspaceFlags(t *testing.T) {
+	mr := miniredis.RunT(t)
+	keyspace := &fakeKeyspaceConfig{flags: "AKE"}
+	require.NoError(t, mr.Server().Register("CONFIG", keyspace.serve))
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	s := storage.NewValkey(client, nil, nil)
+	go func() { _ = s.(storage.ExpiryWatcher).WatchExpired(t.Context(), slog.New(slog.DiscardHandler)) }()
+	require.Eventually(t, func() bool { return mr.PubSubNumPat() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, keyspace.sets, "flags already reporting expired keys are left alone")
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func TestHealthProbes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	cfg := new(config.Config)
+	require.NoError(t, cfg.Load())
+	cfg.InitCSRF()
+	pCfg := new(atomic.Pointer[config.Config])
+	pCfg.Store(cfg)
+	a := New(context.Background(), db, metrics.New(), pCfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.addHealth(cfg)
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		a.E().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	code, body := get("/readyz")
+	assert.Equal(t, http.StatusOK, code, body)
+	assert.JSONEq(t, `{"status":"ok","checks":{"valkey":"ok","keys":"ok","templates":"ok"}}`, body)
+
+	cfg.Csrf.BlockKey = []byte("short")
+	mr.SetError("LOADING")
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.JSONEq(t, `{"status":"unavailable","checks":{"valkey":"failed","keys":"failed","templates":"ok"}}`, body)
+
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUILoginLockout(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	login := func(password, ip string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"admin"}, "password": {password}}
+		r := httptest.NewRequest(http.MethodPost, "/authenticate", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// default policy locks username out on 5th failure, regardless of client IP
+	for range 4 {
+		rec := login("wrong", "10.0.0.1")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Invalid credentials")
+	}
+	rec := login("wrong", "10.0.0.2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "Too many failed logins")
+
+	rec = login("s3cr3t", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "locked out username must not log in")
+	assert.Empty(t, rec.Result().Cookies())
+}
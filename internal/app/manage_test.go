@@ -0,0 +1,83 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageSecret(t *testing.T) {
+	a := newPolicyApp(t, nil)
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		r.SetBasicAuth("admin", "s3cr3t")
+		rec := httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		return rec
+	}
+	status := func(rec *httptest.ResponseRecorder) api.SecretStatusResponseData {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var s api.SecretStatusResponseData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		return s
+	}
+
+	created := a.createSecret(t, `{"value":"hunter2","expiration":86400,"max_views":2}`, nil)
+	manage := "/api/manage/" + path.Base(created.ManageUrl)
+	sid, token, _ := strings.Cut(path.Base(created.ManageUrl), "-")
+
+	s := status(send(http.MethodGet, manage, ""))
+	assert.Equal(t, "pending", s.State)
+	assert.EqualValues(t, 2, s.ViewsLeft)
+	assert.Equal(t, created.ExpiresAt.Unix(), s.ExpiresAt.Unix())
+
+	for _, wrong := range []string{sid + "-" + strings.Repeat("0", len(token)), sid, "missing-" + token} {
+		for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+			rec := send(method, "/api/manage/"+wrong, `{"expiration":60}`)
+			assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", method, wrong)
+		}
+	}
+
+	// extensions add up to the longest expiration since creation, but not past it
+	s = status(send(http.MethodPatch, manage, `{"expiration":259200}`))
+	assert.Equal(t, created.ExpiresAt.Add(72*time.Hour).Unix(), s.ExpiresAt.Unix())
+	s = status(send(http.MethodPatch, manage, `{"expiration":259200}`))
+	assert.Equal(t, s.CreatedAt.Add(7*24*time.Hour).Unix(), s.ExpiresAt.Unix())
+	rec := send(http.MethodPatch, manage, `{"expiration":30}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, s.ExpiresAt, status(send(http.MethodGet, manage, "")).ExpiresAt, "refused extension changes nothing")
+
+	r := httptest.NewRequest(http.MethodPatch, "/manage/"+path.Base(created.ManageUrl), strings.NewReader("expiration=30"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusConflict, rec.Code, "UI refuses extension too")
+
+	rec = send(http.MethodPost, "/api/"+path.Base(created.Url), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	b, _ := io.ReadAll(rec.Body)
+	assert.Equal(t, "hunter2", string(b))
+	s = status(send(http.MethodGet, manage, ""))
+	assert.Equal(t, "viewed", s.State)
+	assert.EqualValues(t, 1, s.Views)
+	assert.EqualValues(t, 1, s.ViewsLeft)
+
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, manage, "").Code)
+	assert.Equal(t, "burned", status(send(http.MethodGet, manage, "")).State)
+	assert.Equal(t, http.StatusGone, send(http.MethodPatch, manage, `{"expiration":30}`).Code)
+}
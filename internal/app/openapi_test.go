@@ -0,0 +1,97 @@
+package app
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	cfg := new(config.Config)
+	require.NoError(t, cfg.Load())
+	pCfg := new(atomic.Pointer[config.Config])
+	pCfg.Store(cfg)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc, err := services.New(pCfg, db, metrics.New(), l)
+	require.NoError(t, err)
+	h := api.NewHandlers(pCfg, svc, l)
+	mux := flow.New()
+	h.AddHandlers(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	for _, route := range h.Routes() {
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			path := strings.NewReplacer(":value", "{value}").Replace(route.Path)
+			item, ok := doc.Paths[path]
+			require.True(t, ok, "path %s missing from OpenAPI document", path)
+			op, ok := (*item)[strings.ToLower(route.Method)]
+			require.True(t, ok, "operation %s %s missing from OpenAPI document", route.Method, route.Path)
+			assert.NotEmpty(t, op.Responses)
+			assert.Equal(t, route.Policy.IsPublic(), len(op.Security) == 0, "security of %s does not match its policy", path)
+		})
+	}
+
+	// every DTO declared in internal/api must be documented with all of its JSON fields
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "../api/dto.go", nil, 0)
+	require.NoError(t, err)
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		t.Run(spec.Name.Name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[spec.Name.Name]
+			require.True(t, ok, "schema %s missing from OpenAPI document", spec.Name.Name)
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("json")
+				name, _, _ := strings.Cut(tag, ",")
+				if name == "" || name == "-" {
+					continue
+				}
+				assert.Contains(t, schema.Properties, name, "field %s.%s missing from OpenAPI document", spec.Name.Name, name)
+			}
+		})
+		return false
+	})
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/internal/ui"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+var (
+	createPolicy = server.BasicAuth().Or(server.TokenAuth(tokens.ScopeSecretsCreate))
+	adminPolicy  = server.BasicAuth().Or(server.TokenAuth(tokens.ScopeAdmin)).Required()
+
+	// routePolicies is the expected policy of every route served by the app
+	routePolicies = map[string]server.Policy{
+		"PUT /api/create":                  createPolicy,
+		"POST /api/requests":               createPolicy,
+		"GET /api/admin/tokens":            adminPolicy,
+		"POST /api/admin/tokens":           adminPolicy,
+		"DELETE /api/admin/tokens/:value":  adminPolicy,
+		"DELETE /api/secrets/:value":       server.BasicAuth().Or(server.TokenAuth(tokens.ScopeSecretsBurn)).Required(),
+		"GET /api/openapi.json":            server.Public,
+		"PUT /api/requests/upload/:value":  server.Public,
+		"POST /api/requests/reveal/:value": server.Public,
+		"GET /api/manage/:value":           server.Public,
+		"PATCH /api/manage/:value":         server.Public,
+		"DELETE /api/manage/:value":        server.Public,
+		"POST /api/:value":                 server.Public,
+
+		"POST /authenticate":          server.Public,
+		"POST /logout":                server.Public,
+		"GET /":                       server.SessionAuth().Optional(),
+		"PUT /":                       server.SessionAuth(),
+		"GET /auth/oidc/login":        server.Public,
+		"GET /auth/oidc/callback":     server.Public,
+		"GET /requests":               server.SessionAuth(),
+		"POST /requests":              server.SessionAuth(),
+		"GET /requests/upload/:value": server.Public,
+		"PUT /requests/upload/:value": server.Public,
+		"GET /requests/reveal/:value": server.Public,
+		"GET /manage/:value":          server.Public,
+		"PATCH /manage/:value":        server.Public,
+		"DELETE /manage/:value":       server.Public,
+		"POST /:value":                server.Public,
+		"GET /:value":                 server.Public,
+	}
+)
+
+type policyApp struct {
+	mux    *flow.Mux
+	cfg    *atomic.Pointer[config.Config]
+	svc    *services.Services
+	routes []server.Route
+	// handler serves requests of helpers, mux unless test wraps it in middlewares
+	handler http.Handler
+}
+
+// createdSecret is secret created through the API with its reveal value and ID
+type createdSecret struct {
+	api.SecretResponseData
+	rec   *httptest.ResponseRecorder
+	value string
+	id    storage.ID
+}
+
+func newPolicyApp(t *testing.T, mutate func(*config.Config)) *policyApp {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	cfg := new(config.Config)
+	require.NoError(t, cfg.Load())
+	cfg.Auth.IsEnabled = true
+	cfg.Auth.Username = "admin"
+	cfg.Auth.Password = "s3cr3t"
+	cfg.RateLimit.IsEnabled = false
+	if mutate != nil {
+		mutate(cfg)
+	}
+	pCfg := new(atomic.Pointer[config.Config])
+	pCfg.Store(cfg)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc, err := services.New(pCfg, db, metrics.New(), l)
+	require.NoError(t, err)
+	a := &policyApp{mux: flow.New(), cfg: pCfg, svc: svc}
+	apiHandlers := api.NewHandlers(pCfg, a.svc, l)
+	apiHandlers.AddHandlers(a.mux)
+	uiHandlers := ui.NewHandlers(pCfg, a.svc, l)
+	uiHandlers.AddHandlers(a.mux)
+	a.routes = append(apiHandlers.Routes(), uiHandlers.Routes()...)
+	a.handler = a.mux
+	return a
+}
+
+// create serves PUT /api/create with body as admin, mutate adjusts request, e.g. its credentials
+func (a *policyApp) create(body string, mutate func(*http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPut, "/api/create", strings.NewReader(body))
+	r.SetBasicAuth("admin", "s3cr3t")
+	if mutate != nil {
+		mutate(r)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, r)
+	return rec
+}
+
+// createSecret creates secret from body and fails test unless it was created
+func (a *policyApp) createSecret(t *testing.T, body string, mutate func(*http.Request)) createdSecret {
+	t.Helper()
+	c := createdSecret{rec: a.create(body, mutate)}
+	require.Equal(t, http.StatusOK, c.rec.Code, c.rec.Body.String())
+	require.NoError(t, json.Unmarshal(c.rec.Body.Bytes(), &c.SecretResponseData))
+	c.value = path.Base(c.Url)
+	c.id = storage.ID(c.value[strings.LastIndex(c.value, "-")+1:])
+	return c
+}
+
+// basicAuth sets credentials of request
+func basicAuth(username, password string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.SetBasicAuth(username, password)
+	}
+}
+
+func (a *policyApp) do(route server.Route, mutate func(*http.Request)) *httptest.ResponseRecorder {
+	path := strings.ReplaceAll(route.Path, ":value", "00-x")
+	r := httptest.NewRequest(route.Method, path, nil)
+	if mutate != nil {
+		mutate(r)
+	}
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, r)
+	return rec
+}
+
+// denied tells whether response is the one written by guard for missing credentials
+func denied(route server.Route, rec *httptest.ResponseRecorder) bool {
+	if !strings.HasPrefix(route.Path, "/api/") && route.Method == http.MethodGet {
+		return rec.Code == http.StatusFound && rec.Header().Get("Location") == "/"
+	}
+	return rec.Code == http.StatusUnauthorized
+}
+
+func TestRoutePolicies(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	registered := make(map[string]bool, len(a.routes))
+	for _, route := range a.routes {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		t.Run(key, func(t *testing.T) {
+			expected, ok := routePolicies[key]
+			require.True(t, ok, "route %s has no expected policy", key)
+			assert.Equal(t, expected, route.Policy, "expected %s, got %s", expected, route.Policy)
+		})
+	}
+	for key := range routePolicies {
+		assert.True(t, registered[key], "route %s is not registered", key)
+	}
+}
+
+func TestRoutePoliciesEnforced(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	rec := httptest.NewRecorder()
+	_, err := a.svc.Sessions.Login(rec, httptest.NewRequest(http.MethodPost, "/authenticate", nil), "admin")
+	require.NoError(t, err)
+	sessionCookie := rec.Result().Cookies()[0]
+
+	for _, route := range a.routes {
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			rec := a.do(route, nil)
+			if route.Policy.IsPublic() || route.Policy.AllowAnonymous {
+				assert.False(t, denied(route, rec), "public route denied anonymous request")
+				return
+			}
+			assert.True(t, denied(route, rec), "anonymous request got %d", rec.Code)
+
+			if route.Policy.Accepts(server.CredentialBasic) {
+				rec = a.do(route, func(r *http.Request) { r.SetBasicAuth("admin", "wrong") })
+				assert.True(t, denied(route, rec), "wrong password got %d", rec.Code)
+				rec = a.do(route, func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") })
+				assert.False(t, denied(route, rec), "basic credentials denied")
+			}
+			if route.Policy.Accepts(server.CredentialToken) {
+				rec = a.do(route, func(r *http.Request) { r.Header.Set("Authorization", "Bearer oss_invalid") })
+				assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			}
+			if route.Policy.Accepts(server.CredentialSession) {
+				rec = a.do(route, func(r *http.Request) { r.AddCookie(sessionCookie) })
+				assert.False(t, denied(route, rec), "session denied")
+			} else {
+				rec = a.do(route, func(r *http.Request) { r.AddCookie(sessionCookie) })
+				assert.True(t, denied(route, rec), "session accepted by route without session policy")
+			}
+		})
+	}
+}
+
+func TestRoutePoliciesAuthDisabled(t *testing.T) {
+	a := newPolicyApp(t, nil)
+	cfg := *a.cfg.Load()
+	cfg.Auth.IsEnabled = false
+	a.cfg.Store(&cfg)
+
+	for _, route := range a.routes {
+		if route.Policy.IsPublic() {
+			continue
+		}
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			rec := a.do(route, nil)
+			assert.Equal(t, route.Policy.Always, denied(route, rec), "got %d", rec.Code)
+		})
+	}
+}
+
+func TestUIIndexPrincipal(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	rec := httptest.NewRecorder()
+	_, err := a.svc.Sessions.Login(rec, httptest.NewRequest(http.MethodPost, "/authenticate", nil), "alice@example.com")
+	require.NoError(t, err)
+	sessionCookie := rec.Result().Cookies()[0]
+
+	index := server.Route{Method: http.MethodGet, Path: "/"}
+	create := server.Route{Method: http.MethodPut, Path: "/"}
+
+	rec = a.do(index, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `name="secret"`)
+
+	rec = a.do(index, func(r *http.Request) { r.AddCookie(sessionCookie) })
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `name="secret"`)
+	assert.Contains(t, rec.Body.String(), "Signed in as alice@example.com")
+
+	rec = a.do(create, func(r *http.Request) { r.Header.Set("HX-Request", "true") })
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("HX-Redirect"))
+
+	cfg := *a.cfg.Load()
+	cfg.Auth.IsEnabled = false
+	a.cfg.Store(&cfg)
+
+	rec = a.do(index, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `name="secret"`)
+	assert.NotContains(t, rec.Body.String(), "Log out")
+}
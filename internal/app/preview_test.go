@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slackbot = "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+
+func TestRevealRequiresPOST(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	created := a.createSecret(t, `{"value":"hunter2"}`, nil)
+	assert.Equal(t, "noindex, nofollow, noarchive, nosnippet", created.rec.Header().Get("X-Robots-Tag"))
+	value, sid := created.value, created.id
+
+	for _, tt := range []struct {
+		name      string
+		method    string
+		target    string
+		userAgent string
+		code      int
+	}{
+		{name: "api get", method: http.MethodGet, target: "/api/" + value, code: http.StatusMethodNotAllowed},
+		{name: "page", method: http.MethodGet, target: "/" + value, code: http.StatusOK},
+		{name: "unfurler page", method: http.MethodGet, target: "/" + value, userAgent: slackbot, code: http.StatusOK},
+		{name: "unfurler reveal", method: http.MethodPost, target: "/" + value, userAgent: slackbot, code: http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			a.mux.ServeHTTP(rec, r)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "hunter2")
+			if tt.userAgent != "" {
+				assert.Contains(t, rec.Body.String(), "A secure message was shared with you.")
+				assert.NotContains(t, rec.Body.String(), "Click to reveal")
+			}
+
+			left, err := a.svc.Storage.ViewsLeft(context.Background(), sid)
+			require.NoError(t, err)
+			assert.EqualValues(t, 1, left, "secret must not be consumed")
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/"+value, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hunter2", rec.Body.String())
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "noindex, nofollow, noarchive, nosnippet", rec.Header().Get("X-Robots-Tag"))
+}
+
+func TestUnfurlerPreviewHidesExistence(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	bodies := make(map[string]string)
+	for _, target := range []string{"/00-missing", "/requests/reveal/missing"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)")
+		rec := httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "noindex, nofollow, noarchive, nosnippet", rec.Header().Get("X-Robots-Tag"))
+		assert.NotContains(t, rec.Body.String(), "Nothing found")
+		bodies[target] = rec.Body.String()
+	}
+	assert.Equal(t, bodies["/00-missing"], bodies["/requests/reveal/missing"])
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitBudgets(t *testing.T) {
+	a := newPolicyApp(t, func(cfg *config.Config) {
+		cfg.RateLimit.IsEnabled = true
+		cfg.RateLimit.Create.PerMinute = 1
+		cfg.RateLimit.Create.Burst = 1
+		cfg.RateLimit.Reveal.PerMinute = 1
+		cfg.RateLimit.Reveal.Burst = 2
+	})
+	basic := func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") }
+
+	// API and UI creation share create budget of client
+	create := server.Route{Method: http.MethodPut, Path: "/api/create"}
+	assert.NotEqual(t, http.StatusTooManyRequests, a.do(create, basic).Code)
+	rec := a.do(create, basic)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, a.do(server.Route{Method: http.MethodPut, Path: "/requests/upload/:value"}, nil).Code)
+	// budget is taken once guard lets request in, made up bearer tokens neither pass nor get own bucket
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer oss_madeup") }
+	assert.Equal(t, http.StatusUnauthorized, a.do(create, bearer).Code)
+
+	reveal := server.Route{Method: http.MethodPost, Path: "/api/:value"}
+	assert.NotEqual(t, http.StatusTooManyRequests, a.do(reveal, nil).Code)
+	assert.NotEqual(t, http.StatusTooManyRequests, a.do(server.Route{Method: http.MethodPost, Path: "/:value"}, nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, a.do(reveal, nil).Code)
+
+	// routes outside budgets are never limited
+	manage := server.Route{Method: http.MethodGet, Path: "/api/manage/:value"}
+	for range 3 {
+		assert.NotEqual(t, http.StatusTooManyRequests, a.do(manage, nil).Code)
+	}
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+)
+
+// reloadable are settings applied by Reload, entries ending with underscore match whole sections. Changes
+// of other settings are kept until restart, because listeners, stores and middlewares are built from them.
+// Auth credentials are read per request, domain too, also by HSTS and ACME host policy. Limits, SSO callback
+// and log level are reconfigured by OnReload hooks.
+var reloadable = []string{
+	"OSS_LOG_LEVEL",
+	"OSS_SERVER_DOMAIN",
+	"OSS_AUTH_ENABLED", "OSS_AUTH_USERNAME", "OSS_AUTH_PASSWORD",
+	"OSS_RATE_LIMIT_ENABLED", "OSS_RATE_LIMIT_CREATE_", "OSS_RATE_LIMIT_REVEAL_", "OSS_RATE_LIMIT_STATIC_",
+	"OSS_LOCKOUT_ENABLED", "OSS_LOCKOUT_BURN_AFTER", "OSS_LOCKOUT_SECRET_", "OSS_LOCKOUT_IP_", "OSS_LOCKOUT_USERNAME_",
+}
+
+func isReloadable(key string) bool {
+	for _, r := range reloadable {
+		if key == r || (strings.HasSuffix(r, "_") && strings.HasPrefix(key, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnReload registers fn called with every config swapped in by Reload
+func (a *App) OnReload(fn func(*config.Config)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onReload = append(a.onReload, fn)
+}
+
+// Reload atomically swaps next in, settings which can't change at runtime keep current values until restart.
+// Config is validated as it would run, with those settings restored, so their invalid values wait for
+// restart instead of blocking reload. It returns variables of applied settings and of those waiting for restart.
+func (a *App) Reload(next *config.Config) (applied []string, restart []string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.cfg.Load()
+	next.InheritKeys(cur)
+	for _, key := range cur.Changed(next) {
+		if isReloadable(key) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	next.Restore(cur, restart...)
+	if err = next.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err = next.CheckKeys(); err != nil {
+		return nil, nil, err
+	}
+
+	a.cfg.Store(next)
+	for _, fn := range a.onReload {
+		fn(next)
+	}
+	return applied, restart, nil
+}
+
+// WatchConfig reloads config from load on SIGHUP and, with ConfigWatch set, whenever config file is modified,
+// until ctx is done. Invalid config is logged and the current one is kept. Load should use
+// config.WithoutValidation, Reload validates config as it is going to run.
+func (a *App) WatchConfig(ctx context.Context, load func() (*config.Config, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	cfg := a.cfg.Load()
+	file := cfg.File()
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+	if cfg.ConfigWatch > 0 && file != "" {
+		ticker = time.NewTicker(cfg.ConfigWatch)
+		tick = ticker.C
+	}
+	mod := modTime(file)
+
+	go func() {
+		defer signal.Stop(hup)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				a.reloadFrom(load, "signal")
+			case <-tick:
+				// modification time follows symlinks, so swapped Kubernetes ConfigMaps are noticed too
+				if m := modTime(file); !m.Equal(mod) {
+					mod = m
+					a.reloadFrom(load, "file")
+				}
+			}
+		}
+	}()
+}
+
+func (a *App) reloadFrom(load func() (*config.Config, error), trigger string) {
+	next, err := load()
+	if err == nil {
+		var applied, restart []string
+		if applied, restart, err = a.Reload(next); err == nil {
+			a.l.Info("config reloaded", "trigger", trigger, "changed", applied)
+			if len(restart) > 0 {
+				a.l.Warn("config changes require restart", "settings", restart)
+			}
+			return
+		}
+	}
+
+	var verr *config.ValidationError
+	if errors.As(err, &verr) {
+		for _, f := range verr.Fields {
+			a.l.Error("invalid config", "setting", f.Name, "error", f.Err)
+		}
+	}
+	a.l.Error("config reload failed, keeping current config", "trigger", trigger, "error", err)
+}
+
+func modTime(file string) time.Time {
+	if file == "" {
+		return time.Time{}
+	}
+	st, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReloadApp(t *testing.T, p *policyApp) *App {
+	t.Helper()
+	// keys are generated before the app is built, as main does
+	p.cfg.Load().InitCSRF()
+	p.cfg.Load().InitNotify()
+	a := New(t.Context(), nil, metrics.New(), p.cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.OnReload(p.svc.Reload)
+	return a
+}
+
+func TestReload(t *testing.T) {
+	p := newPolicyApp(t, nil)
+	a := newReloadApp(t, p)
+	cur := p.cfg.Load()
+	require.Equal(t, http.StatusOK, p.create(`{"value":"hunter2"}`, basicAuth("admin", "s3cr3t")).Code)
+
+	next := *cur
+	next.Auth.Password = "changed"
+	next.RateLimit.IsEnabled = true
+	next.RateLimit.Create.Burst = 2
+	next.Server.Addr = "0.0.0.0:9999"
+	applied, restart, err := a.Reload(&next)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"OSS_AUTH_PASSWORD", "OSS_RATE_LIMIT_ENABLED", "OSS_RATE_LIMIT_CREATE_BURST"}, applied)
+	assert.Equal(t, []string{"OSS_SERVER_ADDR"}, restart)
+	assert.Equal(t, cur.Server.Addr, p.cfg.Load().Server.Addr, "settings requiring restart keep current value")
+
+	assert.Equal(t, http.StatusUnauthorized, p.create(`{"value":"hunter2"}`, basicAuth("admin", "s3cr3t")).Code, "old password is rejected")
+	assert.Equal(t, http.StatusOK, p.create(`{"value":"hunter2"}`, basicAuth("admin", "changed")).Code)
+	assert.Equal(t, http.StatusOK, p.create(`{"value":"hunter2"}`, basicAuth("admin", "changed")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, p.create(`{"value":"hunter2"}`, basicAuth("admin", "changed")).Code, "reloaded burst is enforced")
+
+	invalid := *p.cfg.Load()
+	invalid.Auth.Password = "ignored"
+	invalid.RateLimit.Create.Burst = 0
+	_, _, err = a.Reload(&invalid)
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "OSS_RATE_LIMIT_CREATE_BURST", verr.Fields[0].Name)
+	assert.Equal(t, "changed", p.cfg.Load().Auth.Password, "invalid config is not applied")
+
+	// restart-only settings are validated at restart, they don't block reloadable changes
+	restartOnly := *p.cfg.Load()
+	restartOnly.Auth.Password = "rotated"
+	restartOnly.Server.Addr = "no port"
+	restartOnly.TLS.IsEnabled = true
+	applied, restart, err = a.Reload(&restartOnly)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"OSS_AUTH_PASSWORD"}, applied)
+	assert.ElementsMatch(t, []string{"OSS_SERVER_ADDR", "OSS_TLS_ENABLED"}, restart)
+	assert.Equal(t, "rotated", p.cfg.Load().Auth.Password)
+	assert.False(t, p.cfg.Load().TLS.IsEnabled)
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "oss.yaml")
+	write := func(password, addr string, mod time.Time) {
+		content := "config_watch: 10ms\nserver:\n  addr: " + addr + "\nauth:\n  enabled: true\n  username: admin\n  password: " + password + "\n"
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(file, mod, mod))
+	}
+	load := func() (*config.Config, error) {
+		c := new(config.Config)
+		return c, c.Load(config.WithFile(file), config.WithEnvironment(map[string]string{}), config.WithoutValidation())
+	}
+	write("s3cr3t", "127.0.0.1:8080", time.Now().Add(-time.Hour))
+
+	p := newPolicyApp(t, func(c *config.Config) {
+		require.NoError(t, c.Load(config.WithFile(file), config.WithEnvironment(map[string]string{})))
+	})
+	a := newReloadApp(t, p)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	a.WatchConfig(ctx, load)
+
+	write("changed", "127.0.0.1:8080", time.Now())
+	assert.Eventually(t, func() bool {
+		return p.cfg.Load().Auth.Password == "changed"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, p.create(`{"value":"hunter2"}`, basicAuth("admin", "changed")).Code)
+
+	// invalid setting requiring restart waits for it, reloadable change next to it is applied
+	write("rotated", "no port", time.Now().Add(time.Minute))
+	assert.Eventually(t, func() bool {
+		return p.cfg.Load().Auth.Password == "rotated"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "127.0.0.1:8080", p.cfg.Load().Server.Addr)
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package app
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchConfigSignal(t *testing.T) {
+	p := newPolicyApp(t, nil)
+	a := newReloadApp(t, p)
+	a.WatchConfig(t.Context(), func() (*config.Config, error) {
+		next := *p.cfg.Load()
+		next.Auth.Password = "changed"
+		return &next, nil
+	})
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return p.cfg.Load().Auth.Password == "changed"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFlow(t *testing.T) {
+	a := newPolicyApp(t, nil)
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		return rec
+	}
+	create := func() api.RequestResponseData {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/api/requests", strings.NewReader(`{"note":"db password"}`))
+		r.SetBasicAuth("admin", "s3cr3t")
+		rec := httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created api.RequestResponseData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		return created
+	}
+	reveal := func(value string) api.RequestStatusResponseData {
+		t.Helper()
+		rec := send(http.MethodPost, "/api/requests/reveal/"+value, "", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var s api.RequestStatusResponseData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		return s
+	}
+
+	t.Run("api", func(t *testing.T) {
+		created := create()
+		rid := path.Base(created.UploadUrl)
+		value := path.Base(created.RevealUrl)
+
+		assert.Equal(t, "open", reveal(value).State)
+		assert.Empty(t, reveal(value).Url)
+
+		rec := send(http.MethodPut, "/api/requests/upload/"+rid, "", `{"value":"hunter2","attachments":[{"content":"cGVt"}]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "attachment without name")
+		rec = send(http.MethodPut, "/api/requests/upload/"+rid, "", `{"value":"hunter2","attachments":[{"name":"key.pem","content":"cGVt"}]}`)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		rec = send(http.MethodPut, "/api/requests/upload/"+rid, "", `{"value":"overwrite"}`)
+		assert.Equal(t, http.StatusGone, rec.Code, "second upload is rejected")
+		rec = send(http.MethodPut, "/api/requests/upload/missing", "", `{"value":"hunter2"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = send(http.MethodPost, "/api/requests/reveal/"+rid+"-AGE-SECRET-KEY-1WRONG", "", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code, "wrong identity")
+		rec = send(http.MethodPost, "/api/requests/reveal/"+rid, "", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code, "missing identity")
+
+		s := reveal(value)
+		assert.Equal(t, "fulfilled", s.State)
+		r := httptest.NewRequest(http.MethodPost, "/api/"+path.Base(s.Url), nil)
+		r.Header.Set("Accept", "application/json")
+		rec = httptest.NewRecorder()
+		a.mux.ServeHTTP(rec, r)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var revealed api.SecretRevealResponseData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &revealed))
+		assert.Equal(t, "hunter2", revealed.Value, "first upload is kept")
+		assert.Equal(t, []api.AttachmentData{{Name: "key.pem", Content: []byte("pem")}}, revealed.Attachments)
+	})
+
+	t.Run("ui", func(t *testing.T) {
+		created := create()
+		rid := path.Base(created.UploadUrl)
+		value := path.Base(created.RevealUrl)
+
+		rec := send(http.MethodGet, "/requests/reveal/"+value, "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		form := "application/x-www-form-urlencoded"
+		rec = send(http.MethodPut, "/requests/upload/"+rid, form, "secret=")
+		assert.Contains(t, rec.Body.String(), "Secret is required")
+		rec = send(http.MethodPut, "/requests/upload/"+rid, form, "secret=hunter2")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "already answered")
+		rec = send(http.MethodPut, "/requests/upload/"+rid, form, "secret=overwrite")
+		assert.Contains(t, rec.Body.String(), "This request was already answered")
+
+		rec = send(http.MethodGet, "/requests/reveal/"+value, "", "")
+		require.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, path.Base(reveal(value).Url), strings.TrimPrefix(rec.Header().Get("Location"), "/"))
+	})
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenStream accepts headers but fails every write, like client gone mid-stream
+type brokenStream struct {
+	*httptest.ResponseRecorder
+}
+
+func (b brokenStream) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func (b brokenStream) Unwrap() http.ResponseWriter {
+	return b.ResponseRecorder
+}
+
+func TestInterruptedRevealKeepsView(t *testing.T) {
+	a := newPolicyApp(t, nil)
+
+	created := a.createSecret(t, `{"value":"hunter2"}`, nil)
+	reveal, sid := "/"+created.value, created.id
+
+	a.mux.ServeHTTP(brokenStream{httptest.NewRecorder()}, httptest.NewRequest(http.MethodPost, reveal, nil))
+	left, err := a.svc.Storage.ViewsLeft(context.Background(), sid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, left, "reveal failing to write must not consume view")
+
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, reveal, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "hunter2")
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	_, err = a.svc.Storage.ViewsLeft(context.Background(), sid)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound, "completed reveal must consume last view")
+}
+
+func TestRevealMalformedValue(t *testing.T) {
+	a := newPolicyApp(t, nil)
+	for _, target := range []string{"/ab", "/api/ab", "/ab-", "/api/-ab"} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			if method == http.MethodGet && strings.HasPrefix(target, "/api/") {
+				continue
+			}
+			rec := httptest.NewRecorder()
+			a.mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+			assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", method, target)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var securityHeaderValues = map[string]string{
+	"X-Frame-Options":        "DENY",
+	"X-Content-Type-Options": "nosniff",
+	"Referrer-Policy":        "no-referrer",
+	"Cache-Control":          "no-store",
+}
+
+func TestSecurityHeadersOnEveryRoute(t *testing.T) {
+	a := newPolicyApp(t, func(cfg *config.Config) {
+		cfg.Server.Domain = "https://oss.test"
+	})
+	h := securityHeaders(a.cfg)(a.mux)
+
+	for _, route := range a.routes {
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			r := httptest.NewRequest(route.Method, strings.ReplaceAll(route.Path, ":value", "00-x"), nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			for header, value := range securityHeaderValues {
+				assert.Equal(t, value, rec.Header().Get(header), header)
+			}
+			assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "'strict-dynamic'")
+			assert.Contains(t, rec.Header().Get("Strict-Transport-Security"), "max-age=63072000")
+		})
+	}
+
+	// HSTS follows reloaded domain
+	next := *a.cfg.Load()
+	next.Server.Domain = "http://oss.test"
+	a.cfg.Store(&next)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+}
+
+func TestPageScriptsCarryNonce(t *testing.T) {
+	a := newPolicyApp(t, func(cfg *config.Config) {
+		cfg.Auth.IsEnabled = false
+	})
+	rec := httptest.NewRecorder()
+	securityHeaders(a.cfg)(a.mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	m := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+	require.Len(t, m, 2)
+	body := rec.Body.String()
+	assert.Equal(t, 2, strings.Count(body, `<script nonce="`+m[1]+`"`), "every script must carry nonce")
+	assert.Equal(t, strings.Count(body, "<script"), strings.Count(body, "<script nonce="))
+	assert.Contains(t, body, `"inlineStyleNonce":"`+m[1]+`"`)
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"), "HSTS must not be sent without TLS")
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/lockout"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// VerifyPassphrase compares passphrase of secret with lockout per secret and client IP. Locked out callers
+// get *lockout.LockedError, secret failing lockout burn threshold is burned and storage.ErrRecordBurned returned.
+// Every failed attempt is audited.
+func (s *Services) VerifyPassphrase(ctx context.Context, sid storage.ID, expected *string, given, ip string) error {
+	if expected == nil || *expected == "" {
+		return nil
+	}
+
+	keys := []lockout.Key{lockout.SecretKey(string(sid)), lockout.IPKey(ip)}
+	if err := s.Lockout.Check(ctx, keys...); err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			s.Audit.Record(ctx, audit.Event{Action: audit.ActionSecretUnlockFailed, Target: string(sid), Reason: "locked_out"})
+			s.Metrics.PassphraseFailed()
+		}
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(*expected)) == 1 {
+		_ = s.Lockout.Reset(ctx, keys[0])
+		return nil
+	}
+
+	s.Audit.Record(ctx, audit.Event{Action: audit.ActionSecretUnlockFailed, Target: string(sid), Reason: "wrong_passphrase"})
+	s.Metrics.PassphraseFailed()
+	res, err := s.Lockout.Fail(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	if res.Burn {
+		if err = s.Storage.Burn(ctx, sid); err != nil {
+			return err
+		}
+		return storage.ErrRecordBurned
+	}
+	if res.RetryAfter > 0 {
+		return &lockout.LockedError{RetryAfter: res.RetryAfter}
+	}
+	return ErrWrongPassphrase
+}
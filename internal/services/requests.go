@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/golib/pkg/id"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/requests"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+// RequestUpload is secret filled in for a request, it gets expiration and max views of the request
+type RequestUpload struct {
+	Value      string
+	Passphrase string
+	Files      []*storage.FileRecord
+}
+
+// CreateRequest stores open request and returns it with age identity, the identity is never stored and
+// belongs to the reveal link only
+func (s *Services) CreateRequest(ctx context.Context, note string, expiration time.Duration, maxViews uint64) (*requests.Request, string, error) {
+	identity, recipient, err := encryption.GenerateKeyPair()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate request key pair: %w", err)
+	}
+	now := time.Now().UTC()
+	req := &requests.Request{
+		ID:         requests.ID(id.New().String()),
+		Recipient:  recipient,
+		Note:       strings.TrimSpace(note),
+		Expiration: expiration,
+		MaxViews:   max(maxViews, 1),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(expiration),
+	}
+	if err = s.Requests.Create(ctx, req); err != nil {
+		return nil, "", err
+	}
+	s.Audit.Record(ctx, audit.Event{Action: audit.ActionRequestCreated, Target: string(req.ID)})
+	return req, identity, nil
+}
+
+// FulfillRequest stores uploaded secret and seals its key to the requester. Only the first upload wins, secret
+// of an upload losing the race is burned and requests.ErrAlreadyFulfilled returned.
+func (s *Services) FulfillRequest(ctx context.Context, rid requests.ID, upload RequestUpload) error {
+	req, err := s.Requests.Get(ctx, rid)
+	if err != nil {
+		return err
+	}
+	if req.State != requests.StateOpen {
+		return requests.ErrAlreadyFulfilled
+	}
+
+	secret := secrets.NewSecret(storage.ID(id.New().String()), encryption.GenerateNewKey(32))
+	secret.SetValue(upload.Value)
+	secret.SetExpiration(req.Expiration)
+	secret.SetMaxViews(req.MaxViews)
+	if upload.Passphrase != "" {
+		secret.SetPassphrase(upload.Passphrase)
+	}
+	for _, f := range upload.Files {
+		secret.AddFile(f.Name, f.Content)
+	}
+
+	insert, err := s.Storage.Store(ctx, secret)
+	if err != nil {
+		return err
+	}
+	sealed, err := encryption.Seal(req.Recipient, insert.Key)
+	if err == nil {
+		err = s.Requests.Fulfill(ctx, req.ID, insert.ID, sealed, insert.ExpiresAt)
+	}
+	if err != nil {
+		_ = s.Storage.Burn(ctx, insert.ID)
+		return err
+	}
+	return nil
+}
+
+// RevealRequest returns request whose reveal link carries identity, with key of its secret once fulfilled.
+// Wrong identity is reported as requests.ErrRequestNotFound, so reveal links can't be guessed from request IDs.
+func (s *Services) RevealRequest(ctx context.Context, rid requests.ID, identity string) (*requests.Request, storage.Key, error) {
+	req, err := s.Requests.Get(ctx, rid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recipient, err := encryption.Recipient(identity); err != nil || recipient != req.Recipient {
+		return nil, nil, requests.ErrRequestNotFound
+	}
+	if req.State != requests.StateFulfilled {
+		return req, nil, nil
+	}
+	key, err := encryption.Open(identity, req.SealedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open sealed key: %w", err)
+	}
+	return req, key, nil
+}
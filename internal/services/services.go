@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/lockout"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/notify"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/requests"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/sso"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+	"github.com/valkey-io/valkey-go"
+)
+
+// Services bundles dependencies shared by API and UI handlers
+type Services struct {
+	Storage  storage.Storage[storage.ID, storage.Key]
+	Requests requests.Store
+	Tokens   tokens.Store
+	Notifier *notify.Notifier
+	Sessions *server.Sessions
+	Lockout  *lockout.Limiter
+	Limiter  *server.RateLimiter
+	Audit    *audit.Auditor
+	Metrics  *metrics.Metrics
+	// SSO and SSOFlows are set when OIDC login is enabled
+	SSO      *sso.Provider
+	SSOFlows sso.FlowStore
+}
+
+func New(cfg *atomic.Pointer[config.Config], client valkey.Client, m *metrics.Metrics, l *slog.Logger) (*Services, error) {
+	c := cfg.Load()
+	svc := &Services{Requests: requests.NewValkey(client), Tokens: tokens.NewValkey(client), Metrics: m}
+
+	var err error
+	if svc.Audit, err = newAuditor(c, client, l); err != nil {
+		return nil, err
+	}
+
+	sessions := server.NewValkeySessionStore(client)
+	if c.Auth.Session.Store == "memory" {
+		sessions = server.NewMemorySessionStore()
+	}
+	svc.Sessions = server.NewSessions(sessions, server.WithTimeouts(c.Auth.Session.IdleTimeout, c.Auth.Session.AbsoluteTimeout))
+
+	svc.Lockout = newLockout(c, client, l)
+	svc.Limiter = newRateLimiter(c, client, l)
+
+	if c.Auth.OIDC.IsEnabled {
+		svc.SSO = sso.New(sso.Config{
+			Issuer:         c.Auth.OIDC.Issuer,
+			ClientID:       c.Auth.OIDC.ClientID,
+			ClientSecret:   c.Auth.OIDC.ClientSecret,
+			RedirectURL:    oidcRedirectURL(c),
+			Scopes:         c.Auth.OIDC.Scopes,
+			AllowedGroups:  c.Auth.OIDC.AllowedGroups,
+			AllowedDomains: c.Auth.OIDC.AllowedDomains,
+			GroupsClaim:    c.Auth.OIDC.GroupsClaim,
+		})
+		svc.SSOFlows = sso.NewValkeyFlowStore(client)
+	}
+
+	observers := []storage.Observer{svc.Audit, m}
+	if c.Notify.IsEnabled {
+		svc.Notifier = notify.New(client, c.Notify.SigningKey, l,
+			notify.WithSMTP(c.Notify.SMTP.Addr, c.Notify.SMTP.From, c.Notify.SMTP.Username, c.Notify.SMTP.Password),
+			notify.WithRetries(c.Notify.MaxRetries, time.Second, time.Minute*5))
+		m.NotificationsDropped(svc.Notifier.Dropped)
+		observers = append(observers, svc.Notifier)
+	}
+
+	var encryptor storage.Encryptor
+	if c.SecretKey != nil {
+		encryptor, _ = storage.NewDefaultEncryptor(c.SecretKey)
+	}
+	svc.Storage = m.InstrumentStorage(storage.NewValkey(client,
+		encryptor,
+		func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
+			return secrets.NewSecret(id, key)
+		},
+		observers...))
+	return svc, nil
+}
+
+// newAuditor builds auditor with sinks from config, disabled audit has no sinks and records nothing
+func newAuditor(c *config.Config, client valkey.Client, l *slog.Logger) (*audit.Auditor, error) {
+	if !c.Audit.IsEnabled {
+		return audit.New(), nil
+	}
+
+	ac := c.Audit
+	opts := []audit.OptsFn{audit.WithLogger(l)}
+	if ac.File != "" {
+		sink, err := audit.NewFileSink(ac.File)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithSink(sink))
+	}
+	if ac.Syslog.IsEnabled {
+		sink, err := audit.NewSyslogSink(ac.Syslog.Network, ac.Syslog.Addr, ac.Syslog.Tag)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithSink(sink))
+	}
+	if ac.Stream.Key != "" {
+		opts = append(opts, audit.WithSink(audit.NewValkeySink(client, ac.Stream.Key, ac.Stream.MaxLen)))
+	}
+	if len(opts) == 1 {
+		return nil, errors.New("audit is enabled without any sink")
+	}
+	return audit.New(opts...), nil
+}
+
+// Reload applies limits of c to rate limiter and lockout and domain to SSO callback, stores are kept until restart
+func (s *Services) Reload(c *config.Config) {
+	s.Limiter.Reconfigure(rateLimits(c)...)
+	s.Lockout.Reconfigure(lockoutPolicies(c)...)
+	if s.SSO != nil {
+		s.SSO.SetRedirectURL(oidcRedirectURL(c))
+	}
+}
+
+// oidcRedirectURL is configured callback URL or the one of server domain
+func oidcRedirectURL(c *config.Config) string {
+	if c.Auth.OIDC.RedirectURL != "" {
+		return c.Auth.OIDC.RedirectURL
+	}
+	return strings.TrimRight(c.Server.Domain, "/") + "/auth/oidc/callback"
+}
+
+// newLockout builds limiter from config, disabled lockout has no policies and limits nothing
+func newLockout(c *config.Config, client valkey.Client, l *slog.Logger) *lockout.Limiter {
+	opts := append([]lockout.OptsFn{lockout.WithLogger(l)}, lockoutPolicies(c)...)
+	if c.Lockout.Store == "memory" {
+		return lockout.New(lockout.NewMemory(), opts...)
+	}
+	opts = append(opts, lockout.WithFallback(lockout.NewMemory(), time.Second))
+	return lockout.New(lockout.NewValkey(client), opts...)
+}
+
+func lockoutPolicies(c *config.Config) []lockout.OptsFn {
+	if !c.Lockout.IsEnabled {
+		return nil
+	}
+	lc := c.Lockout
+	return []lockout.OptsFn{
+		lockout.WithBurnAfter(lc.BurnAfter),
+		lockout.WithPolicy(lockout.ScopeSecret, lockout.Policy{MaxFailures: lc.Secret.MaxFailures, Window: lc.Secret.Window, Lockout: lc.Secret.Lockout}),
+		lockout.WithPolicy(lockout.ScopeIP, lockout.Policy{MaxFailures: lc.IP.MaxFailures, Window: lc.IP.Window, Lockout: lc.IP.Lockout}),
+		lockout.WithPolicy(lockout.ScopeUsername, lockout.Policy{MaxFailures: lc.Username.MaxFailures, Window: lc.Username.Window, Lockout: lc.Username.Lockout}),
+	}
+}
+
+// newRateLimiter builds rate limiter from config, disabled limiter has no budgets and limits nothing
+func newRateLimiter(c *config.Config, client valkey.Client, l *slog.Logger) *server.RateLimiter {
+	store := server.NewValkeyRateLimitStore(client)
+	if c.RateLimit.Store == "memory" {
+		store = server.NewMemoryRateLimitStore()
+	}
+	return server.NewRateLimiter(store, append([]server.RateLimiterOptsFn{server.WithRateLimiterLogger(l)}, rateLimits(c)...)...)
+}
+
+func rateLimits(c *config.Config) []server.RateLimiterOptsFn {
+	if !c.RateLimit.IsEnabled {
+		return nil
+	}
+	rc := c.RateLimit
+	return []server.RateLimiterOptsFn{
+		server.WithRateLimit(server.BudgetCreate, server.RateLimit{PerMinute: rc.Create.PerMinute, Burst: rc.Create.Burst}),
+		server.WithRateLimit(server.BudgetReveal, server.RateLimit{PerMinute: rc.Reveal.PerMinute, Burst: rc.Reveal.Burst}),
+		server.WithRateLimit(server.BudgetStatic, server.RateLimit{PerMinute: rc.Static.PerMinute, Burst: rc.Static.Burst}),
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/hex"
 	"errors"
@@ -15,7 +16,11 @@ import (
 
 	"github.com/pudottapommin/golib/http/middleware/csrf"
 	"github.com/pudottapommin/golib/pkg/id"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/lockout"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/notify"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
@@ -24,12 +29,20 @@ import (
 )
 
 func (h *handlers) indexGET(w http.ResponseWriter, r *http.Request) {
-	isAuthenticated := r.Context().Value(server.AuthContextKey).(bool)
+	principal := server.PrincipalFromContext(r.Context())
 
 	csrfToken := csrf.FromContextStringed(r.Context())
 	csrfField := csrf.FromContextFieldName(r.Context())
-	model := ui.PageIndex{IsAuthenticated: isAuthenticated, FormModel: &ui.FormModel{CsrfField: csrfField, CsrfToken: csrfToken}}
-	if err := ui.Index.ExecutePage(w, model); err != nil {
+	cfg := h.cfg.Load()
+	model := ui.PageIndex{
+		IsAuthenticated: !cfg.Auth.IsEnabled || principal.IsAuthenticated(),
+		CanLogout:       principal.Credential == server.CredentialSession,
+		Subject:         principal.Subject,
+		PasswordEnabled: cfg.Auth.Username != "" || h.svc.SSO == nil,
+		SSOEnabled:      h.svc.SSO != nil,
+		FormModel:       &ui.FormModel{CsrfField: csrfField, CsrfToken: csrfToken, NotifyEnabled: h.svc.Notifier != nil},
+	}
+	if err := ui.Index.ExecutePage(r.Context(), w, model); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -42,7 +55,7 @@ func (h *handlers) indexPUT(w http.ResponseWriter, r *http.Request) {
 
 	value := r.FormValue("secret")
 	if value == "" {
-		if err := ui.Index.ExecuteHTMXSecretError(w, "Secret is required"); err != nil {
+		if err := ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Secret is required"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -58,7 +71,7 @@ func (h *handlers) indexPUT(w http.ResponseWriter, r *http.Request) {
 	maxViews, err := strconv.ParseUint(r.FormValue("maxViews"), 10, 64)
 	switch {
 	case err != nil:
-		if err = ui.Index.ExecuteHTMXSecretError(w, "Invalid max views value"); err != nil {
+		if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Invalid max views value"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -70,7 +83,7 @@ func (h *handlers) indexPUT(w http.ResponseWriter, r *http.Request) {
 	expiration, err := strconv.ParseUint(r.FormValue("expiration"), 10, 64)
 	switch {
 	case err != nil:
-		if err = ui.Index.ExecuteHTMXSecretError(w, "Invalid expiration value"); err != nil {
+		if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Invalid expiration value"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -93,17 +106,65 @@ func (h *handlers) indexPUT(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if v := strings.TrimSpace(r.FormValue("recipients")); v != "" {
+		recipients, err := encryption.ParseRecipients(splitRecipients(v))
+		if err != nil {
+			if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Invalid recipient public key"); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if err = secret.SealTo(recipients); err != nil {
+			h.l.Error("failed to seal secret to recipients", "error", err)
+			if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Failed to store secret"); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	var target *notify.Target
+	if v := r.FormValue("notify"); v != "" && h.svc.Notifier != nil {
+		t, err := notify.ParseTarget(v)
+		if err == nil {
+			err = h.svc.Notifier.Validate(t)
+		}
+		if err != nil {
+			if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Invalid notification e-mail or webhook"); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		target = &t
+	}
+
 	insert, err := h.db.Store(r.Context(), secret)
-	if err = ui.Index.ExecuteHTMXSecretError(w, "Failed to store secret"); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err != nil {
+		h.l.Error("failed to store secret", "error", err)
+		if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Failed to store secret"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
+	if target != nil {
+		if err = h.svc.Notifier.Register(r.Context(), insert.ID, *target, insert.ExpiresAt); err != nil {
+			h.l.Error("failed to register notification", "error", err)
+			_ = h.db.Burn(r.Context(), insert.ID)
+			if err = ui.Index.ExecuteHTMXSecretError(r.Context(), w, "Failed to store secret"); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	domain := h.cfg.Load().Server.Domain
 	model := ui.CardSecretCreated{
-		Url:       fmt.Sprintf("%s/%x-%s", h.cfg.Load().Server.Domain, insert.Key, sid),
+		Url:       fmt.Sprintf("%s/%x-%s", domain, insert.Key, sid),
+		ManageUrl: secrets.ManageUrl(domain, insert.ID, insert.ManageToken),
 		ExpiresAt: insert.ExpiresAt,
 	}
-	if err = ui.Index.ExecuteHTMXSecretCreatedCard(w, model); err != nil {
+	if err = ui.Index.ExecuteHTMXSecretCreatedCard(r.Context(), w, model); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -117,8 +178,12 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parts := strings.Split(value, "-")
-	encKey, err := hex.DecodeString(parts[0])
+	hexKey, sid, ok := secrets.ParseRevealValue(value)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	encKey, err := hex.DecodeString(hexKey)
 	if err != nil {
 		h.l.Error("failed to decode encryption key", slog.Any("err", err), slog.String("path", r.URL.Path))
 		http.Redirect(w, r, "/", http.StatusFound)
@@ -127,7 +192,6 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 
 	csrfToken := csrf.FromContextStringed(r.Context())
 	csrfField := csrf.FromContextFieldName(r.Context())
-	sid := storage.ID(parts[1])
 	secret, err := h.db.Get(ctx, sid, encKey)
 	switch {
 	case errors.Is(err, storage.ErrRecordNotFound) || (err == nil && secret == nil):
@@ -136,7 +200,7 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 			NotFound:  true,
 			FormModel: &ui.FormModel{CsrfField: csrfField, CsrfToken: csrfToken},
 		}
-		if err = ui.Secret.ExecutePage(w, model); err != nil {
+		if err = ui.Secret.ExecutePage(r.Context(), w, model); err != nil {
 			h.l.Error("failed to execute secret page template", "error", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -164,13 +228,22 @@ func (h *handlers) secretGET(w http.ResponseWriter, r *http.Request) {
 		ViewsLeft:  viewsLeft,
 		FormModel:  &ui.FormModel{CsrfField: csrfField, CsrfToken: csrfToken},
 	}
-	if err = ui.Secret.ExecutePage(w, model); err != nil {
+	if err = ui.Secret.ExecutePage(r.Context(), w, model); err != nil {
 		h.l.Error("failed to execute secret page template", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// previewGET serves neutral page to link unfurlers and crawlers, secret is not looked up so they learn
+// neither its content nor whether it exists
+func (h *handlers) previewGET(w http.ResponseWriter, r *http.Request) {
+	if err := ui.Secret.ExecutePage(r.Context(), w, ui.PageSecret{Preview: true, FormModel: &ui.FormModel{}}); err != nil {
+		h.l.Error("failed to execute secret page template", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (h *handlers) secretPOST(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	value := strings.TrimSpace(r.PathValue("value"))
@@ -179,14 +252,17 @@ func (h *handlers) secretPOST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parts := strings.Split(value, "-")
-	encKey, err := hex.DecodeString(parts[0])
+	hexKey, sid, ok := secrets.ParseRevealValue(value)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	encKey, err := hex.DecodeString(hexKey)
 	if err != nil {
 		h.l.Error("failed to decode encryption key", slog.Any("err", err), slog.String("path", r.URL.Path))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	sid := storage.ID(parts[1])
 	secret, err := h.db.Get(ctx, sid, encKey)
 	switch {
 	case errors.Is(err, storage.ErrRecordNotFound) || (err == nil && secret == nil):
@@ -198,19 +274,29 @@ func (h *handlers) secretPOST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if secret.Passphrase() != nil && *secret.Passphrase() != "" {
-		passphrase := r.FormValue("passphrase")
-		if subtle.ConstantTimeCompare([]byte(passphrase), []byte(*secret.Passphrase())) != 1 {
-			if err = ui.Secret.ExecuteHTMXDecryptError(w); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			return
+	err = h.svc.VerifyPassphrase(ctx, sid, secret.Passphrase(), r.FormValue("passphrase"), server.ClientIP(r))
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		server.SetRetryAfter(w, locked.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		if err = ui.Secret.ExecuteHTMXDecryptError(r.Context(), w, "Too many wrong passwords, try again later..."); err != nil {
+			h.l.Error("failed to execute decrypt error template", "error", err)
 		}
-	}
-
-	if err = h.db.Viewed(ctx, sid); err != nil {
-		h.l.Error("failed to mark secret as viewed", "error", err)
+		return
+	case errors.Is(err, storage.ErrRecordBurned):
+		w.WriteHeader(http.StatusGone)
+		if err = ui.Secret.ExecuteHTMXDecryptError(r.Context(), w, "Too many wrong passwords, the secret was burned..."); err != nil {
+			h.l.Error("failed to execute decrypt error template", "error", err)
+		}
+		return
+	case errors.Is(err, services.ErrWrongPassphrase):
+		if err = ui.Secret.ExecuteHTMXDecryptError(r.Context(), w, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case err != nil:
+		h.l.Error("failed to verify passphrase", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -218,62 +304,252 @@ func (h *handlers) secretPOST(w http.ResponseWriter, r *http.Request) {
 	model := ui.CardSecretDecrypted{
 		Url:       r.URL.Path,
 		Secret:    secret.Value(),
+		Sealed:    encryption.DetectFormat([]byte(secret.Value())),
 		ExpiresAt: secret.ExpiresAt(),
 		Files:     secret.Files(),
 	}
 	bb := bytebufferpool.Get()
 	defer bytebufferpool.Put(bb)
-	if err = ui.Secret.ExecuteHTMXSecretDecrypted(bb, model); err != nil {
+	if err = ui.Secret.ExecuteHTMXSecretDecrypted(r.Context(), bb, model); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	sse := server.NewSSEWriter(w, r)
-	if err = sse.WriteHTML(bb.String()); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
+	events := []string{bb.String()}
 	if len(model.Files) > 0 {
 		bb.Reset()
-		if err = ui.Secret.ExecuteHTMXSecretDecryptedFiles(bb, model); err != nil {
+		if err = ui.Secret.ExecuteHTMXSecretDecryptedFiles(r.Context(), bb, model); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if err = sse.WriteHTML(bb.String()); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		events = append(events, bb.String())
+	}
+
+	// view is consumed once whole secret was written without error, stream failing midway leaves it for retry.
+	// It is best effort, successful write only means proxies or kernel buffered it, not that client got it.
+	sse := server.NewSSEWriter(w, r)
+	defer sse.Close()
+	for _, event := range events {
+		if err = sse.WriteHTML(event); err != nil {
+			h.l.Warn("secret stream failed, view not consumed", "error", err)
 			return
 		}
 	}
+	if err = h.db.Viewed(context.WithoutCancel(ctx), sid); err != nil {
+		h.l.Error("failed to mark secret as viewed", "error", err)
+	}
+}
+
+// splitRecipients splits textarea input into age recipients (one per line) and armored OpenPGP key blocks
+func splitRecipients(v string) []string {
+	var keys []string
+	var block strings.Builder
+	for line := range strings.Lines(v) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case block.Len() > 0:
+			block.WriteString(line)
+			if strings.HasPrefix(trimmed, "-----END PGP PUBLIC KEY BLOCK-----") {
+				keys = append(keys, block.String())
+				block.Reset()
+			}
+		case strings.HasPrefix(trimmed, "-----BEGIN PGP PUBLIC KEY BLOCK-----"):
+			block.WriteString(line)
+		case trimmed != "":
+			keys = append(keys, trimmed)
+		}
+	}
+	if block.Len() > 0 {
+		keys = append(keys, block.String())
+	}
+	return keys
 }
 
 func (h *handlers) authenticatePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	username := r.FormValue("username")
 	password := r.FormValue("password")
+	keys := []lockout.Key{lockout.UsernameKey(username), lockout.IPKey(server.ClientIP(r))}
+
+	var locked *lockout.LockedError
+	err := h.svc.Lockout.Check(ctx, keys...)
+	switch {
+	case errors.As(err, &locked):
+		h.svc.Audit.Record(ctx, audit.Event{Action: audit.ActionLoginFailed, Actor: username, Reason: "locked_out"})
+		h.writeLoginLocked(w, r, locked.RetryAfter)
+		return
+	case err != nil:
+		h.l.Error("failed to check login lockout", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	cfg := h.cfg.Load()
 	if username == "" || password == "" {
-		if err := ui.Index.ExecuteHTMXAuthError(w); err != nil {
+		if err := ui.Index.ExecuteHTMXAuthError(r.Context(), w, ""); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	} else if subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Auth.Username)) != 1 || subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Auth.Password)) != 1 {
-		if err := ui.Index.ExecuteHTMXAuthError(w); err != nil {
+		h.svc.Audit.Record(ctx, audit.Event{Action: audit.ActionLoginFailed, Actor: username, Reason: "invalid_credentials"})
+		res, err := h.svc.Lockout.Fail(ctx, keys...)
+		switch {
+		case err != nil:
+			h.l.Error("failed to count login failure", "error", err)
+		case res.RetryAfter > 0:
+			h.writeLoginLocked(w, r, res.RetryAfter)
+			return
+		}
+		if err := ui.Index.ExecuteHTMXAuthError(r.Context(), w, ""); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
+	_ = h.svc.Lockout.Reset(ctx, keys[0])
 
-	server.AuthSetCookie(w)
+	if _, err := h.svc.Sessions.Login(w, r, username); err != nil {
+		h.l.Error("failed to create session", "error", err)
+		if err = ui.Index.ExecuteHTMXAuthError(r.Context(), w, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	h.svc.Audit.Record(ctx, audit.Event{Action: audit.ActionLoginSucceeded, Actor: username, Credential: "password"})
 
 	csrfToken := csrf.FromContextStringed(r.Context())
 	csrfField := csrf.FromContextFieldName(r.Context())
-	if err := ui.Index.ExecuteHTMXSecretForm(w, ui.FormModel{CsrfToken: csrfToken, CsrfField: csrfField}); err != nil {
+	if err := ui.Index.ExecuteHTMXSecretForm(r.Context(), w, ui.FormModel{CsrfToken: csrfToken, CsrfField: csrfField, NotifyEnabled: h.svc.Notifier != nil}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+func (h *handlers) writeLoginLocked(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	server.SetRetryAfter(w, wait)
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := ui.Index.ExecuteHTMXAuthError(r.Context(), w, "Too many failed logins, try again later..."); err != nil {
+		h.l.Error("failed to execute auth error template", "error", err)
+	}
+}
+
+func (h *handlers) logoutPOST(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.Sessions.Logout(w, r); err != nil {
+		h.l.Error("failed to delete session", "error", err)
+	}
+	w.Header().Set("HX-Redirect", "/")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *handlers) manageGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	model := ui.PageManage{
+		Url: r.URL.Path,
+		FormModel: &ui.FormModel{
+			CsrfField: csrf.FromContextFieldName(ctx),
+			CsrfToken: csrf.FromContextStringed(ctx),
+		},
+	}
+
+	sid, token, ok := secrets.ParseManageValue(r.PathValue("value"))
+	if ok {
+		if err := h.db.VerifyManageToken(ctx, sid, token); err == nil {
+			model.Status, err = h.db.Status(ctx, sid)
+			if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+				h.l.Error("failed to get secret status", "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else if !errors.Is(err, storage.ErrRecordNotFound) && !errors.Is(err, storage.ErrInvalidManageToken) {
+			h.l.Error("failed to verify manage token", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	model.NotFound = model.Status == nil
+
+	if err := ui.Manage.ExecutePage(r.Context(), w, model); err != nil {
+		h.l.Error("failed to execute manage page template", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) managePATCH(w http.ResponseWriter, r *http.Request) {
+	sid, ok := h.authorizeManage(w, r)
+	if !ok {
+		return
+	}
+
+	expiration, err := strconv.Atoi(r.FormValue("expiration"))
+	if _, valid := secrets.ExpirationRanges[expiration]; err != nil || !valid {
+		http.Error(w, "invalid expiration", http.StatusBadRequest)
+		return
+	}
+	_, err = h.db.Extend(r.Context(), sid, time.Second*time.Duration(expiration))
+	if errors.Is(err, storage.ErrLifetimeExceeded) {
+		http.Error(w, "secret can't live longer than a week", http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, storage.ErrRecordBurned) {
+		h.l.Error("failed to extend secret", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.renderManageStatus(w, r, sid)
+}
+
+func (h *handlers) manageDELETE(w http.ResponseWriter, r *http.Request) {
+	sid, ok := h.authorizeManage(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Burn(r.Context(), sid); err != nil {
+		h.l.Error("failed to burn secret", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.renderManageStatus(w, r, sid)
+}
+
+func (h *handlers) authorizeManage(w http.ResponseWriter, r *http.Request) (storage.ID, bool) {
+	sid, token, ok := secrets.ParseManageValue(r.PathValue("value"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	}
+	switch err := h.db.VerifyManageToken(r.Context(), sid, token); {
+	case errors.Is(err, storage.ErrRecordNotFound) || errors.Is(err, storage.ErrInvalidManageToken):
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	case err != nil:
+		h.l.Error("failed to verify manage token", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	return sid, true
+}
+
+func (h *handlers) renderManageStatus(w http.ResponseWriter, r *http.Request, sid storage.ID) {
+	ctx := r.Context()
+	status, err := h.db.Status(ctx, sid)
+	if err != nil {
+		h.l.Error("failed to get secret status", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	model := ui.PageManage{
+		Url:    r.URL.Path,
+		Status: status,
+		FormModel: &ui.FormModel{
+			CsrfField: csrf.FromContextFieldName(ctx),
+			CsrfToken: csrf.FromContextStringed(ctx),
+		},
+	}
+	if err = ui.Manage.ExecuteHTMXStatusCard(r.Context(), w, model); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func readFile(file *multipart.FileHeader) ([]byte, error) {
 	f, err := file.Open()
 	if err != nil {
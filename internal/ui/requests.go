@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/golib/http/middleware/csrf"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/requests"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/ui"
+)
+
+func (h *handlers) requestsGET(w http.ResponseWriter, r *http.Request) {
+	model := ui.PageRequest{FormModel: &ui.FormModel{
+		CsrfField: csrf.FromContextFieldName(r.Context()),
+		CsrfToken: csrf.FromContextStringed(r.Context()),
+	}}
+	if err := ui.Request.ExecutePage(r.Context(), w, model); err != nil {
+		h.l.Error("failed to execute request page template", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) requestsPOST(w http.ResponseWriter, r *http.Request) {
+	expiration, err := strconv.Atoi(r.FormValue("expiration"))
+	if _, ok := secrets.ExpirationRanges[expiration]; err != nil || !ok {
+		if err = ui.Request.ExecuteHTMXError(r.Context(), w, "Invalid expiration value"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	maxViews, err := strconv.ParseUint(r.FormValue("maxViews"), 10, 64)
+	if err != nil || maxViews == 0 {
+		if err = ui.Request.ExecuteHTMXError(r.Context(), w, "Invalid max views value"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	req, identity, err := h.svc.CreateRequest(r.Context(), r.FormValue("note"), time.Second*time.Duration(expiration), maxViews)
+	if err != nil {
+		h.l.Error("failed to store request", "error", err)
+		if err = ui.Request.ExecuteHTMXError(r.Context(), w, "Failed to create request"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	domain := h.cfg.Load().Server.Domain
+	model := ui.CardRequestCreated{
+		UploadUrl: secrets.RequestUploadUrl(domain, string(req.ID)),
+		RevealUrl: secrets.RequestRevealUrl(domain, string(req.ID), identity),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err = ui.Request.ExecuteHTMXCreatedCard(r.Context(), w, model); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) uploadGET(w http.ResponseWriter, r *http.Request) {
+	model := ui.PageUpload{
+		Url: r.URL.Path,
+		FormModel: &ui.FormModel{
+			CsrfField: csrf.FromContextFieldName(r.Context()),
+			CsrfToken: csrf.FromContextStringed(r.Context()),
+		},
+	}
+
+	req, err := h.svc.Requests.Get(r.Context(), requests.ID(strings.TrimSpace(r.PathValue("value"))))
+	switch {
+	case errors.Is(err, requests.ErrRequestNotFound) || (err == nil && req.State != requests.StateOpen):
+		model.NotFound = true
+	case err != nil:
+		h.l.Error("failed to get request", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	default:
+		model.Note = req.Note
+	}
+
+	if err = ui.Upload.ExecutePage(r.Context(), w, model); err != nil {
+		h.l.Error("failed to execute upload page template", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) uploadPUT(w http.ResponseWriter, r *http.Request) {
+	upload := services.RequestUpload{Value: r.FormValue("secret")}
+	if upload.Value == "" {
+		if err := ui.Upload.ExecuteHTMXError(r.Context(), w, "Secret is required"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if r.MultipartForm != nil {
+		for _, file := range r.MultipartForm.File["attachments"] {
+			b, err := readFile(file)
+			if err != nil {
+				h.l.Error("failed to read file", slog.Any("err", err), slog.String("name", file.Filename))
+				continue
+			}
+			upload.Files = append(upload.Files, &storage.FileRecord{Name: file.Filename, Content: b})
+		}
+	}
+
+	err := h.svc.FulfillRequest(r.Context(), requests.ID(strings.TrimSpace(r.PathValue("value"))), upload)
+	switch {
+	case errors.Is(err, requests.ErrRequestNotFound) || errors.Is(err, requests.ErrAlreadyFulfilled):
+		if err = ui.Upload.ExecuteHTMXError(r.Context(), w, "This request was already answered"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case err != nil:
+		h.l.Error("failed to fulfill request", "error", err)
+		if err = ui.Upload.ExecuteHTMXError(r.Context(), w, "Failed to store secret"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err = ui.Upload.ExecuteHTMXDoneCard(r.Context(), w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) revealGET(w http.ResponseWriter, r *http.Request) {
+	model := ui.PageRequest{FormModel: &ui.FormModel{}}
+
+	var req *requests.Request
+	var key storage.Key
+	var err error
+	if rid, identity, ok := secrets.ParseRequestRevealValue(r.PathValue("value")); ok {
+		req, key, err = h.svc.RevealRequest(r.Context(), requests.ID(rid), identity)
+	} else {
+		err = requests.ErrRequestNotFound
+	}
+
+	switch {
+	case errors.Is(err, requests.ErrRequestNotFound):
+		model.NotFound = true
+	case err != nil:
+		h.l.Error("failed to reveal request", "error", err)
+		model.NotFound = true
+	case key == nil:
+		model.Pending = true
+	default:
+		http.Redirect(w, r, fmt.Sprintf("/%x-%s", key, req.SecretID), http.StatusFound)
+		return
+	}
+
+	if err = ui.Request.ExecutePage(r.Context(), w, model); err != nil {
+		h.l.Error("failed to execute request page template", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
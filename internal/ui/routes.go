@@ -1,68 +1,86 @@
 package ui
 
 import (
-	"context"
 	"log/slog"
 	"net/http"
 	"sync/atomic"
 
 	"github.com/alexedwards/flow"
 	"github.com/pudottapommin/onetime-secrets-service/config"
-	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
-	"github.com/valkey-io/valkey-go"
 )
 
 type handlers struct {
-	l   *slog.Logger
-	cfg *atomic.Pointer[config.Config]
-	db  storage.Storage[storage.ID, storage.Key]
+	l     *slog.Logger
+	cfg   *atomic.Pointer[config.Config]
+	db    storage.Storage[storage.ID, storage.Key]
+	svc   *services.Services
+	guard *server.Guard
 }
 
-func NewHandlers(cfg *atomic.Pointer[config.Config], client valkey.Client, l *slog.Logger) *handlers {
-	var encryptor storage.Encryptor
-	if cfg.Load().SecretKey != nil {
-		encryptor, _ = storage.NewDefaultEncryptor(cfg.Load().SecretKey)
-	}
+func NewHandlers(cfg *atomic.Pointer[config.Config], svc *services.Services, l *slog.Logger) *handlers {
 	return &handlers{
 		cfg: cfg,
 		l:   l,
-		db: storage.NewValkey(client,
-			encryptor,
-			func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
-				return secrets.NewSecret(id, key)
+		db:  svc.Storage,
+		svc: svc,
+		guard: server.NewGuard(
+			server.WithGuardLogger(l),
+			server.WithAuthEnabled(func() bool { return cfg.Load().Auth.IsEnabled }),
+			server.WithSessionAuth(svc.Sessions),
+			server.WithDeniedHandler(func(w http.ResponseWriter, r *http.Request) {
+				// htmx requests reload the page which shows login form instead
+				if r.Header.Get("HX-Request") != "" {
+					w.Header().Set("HX-Redirect", "/")
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.Method == http.MethodGet {
+					http.Redirect(w, r, "/", http.StatusFound)
+					return
+				}
+				w.WriteHeader(http.StatusUnauthorized)
 			}),
+		),
 	}
 }
 
 func (h *handlers) AddHandlers(e *flow.Mux) {
-	e.Group(func(g *flow.Mux) {
-		g.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				cfg := h.cfg.Load()
-				if !cfg.Auth.IsEnabled {
-					next.ServeHTTP(w, r)
-					return
-				}
-
-				switch r.URL.Path {
-				case "/":
-					isAuthenticated := true
-					if err := server.AuthValidateToken(r); err != nil {
-						isAuthenticated = false
-					}
-					r = r.WithContext(context.WithValue(r.Context(), server.AuthContextKey, isAuthenticated))
-				}
-
-				next.ServeHTTP(w, r)
-			})
-		})
+	session := server.SessionAuth()
 
-		g.HandleFunc("/authenticate", h.authenticatePOST, "post")
-		g.HandleFunc("/", h.indexPUT, "PUT")
-		g.HandleFunc("/", h.indexGET, "GET")
+	h.guard.Handle(e, http.MethodPost, "/authenticate", server.Public, h.authenticatePOST)
+	h.guard.Handle(e, http.MethodPost, "/logout", server.Public, h.logoutPOST)
+	h.guard.Handle(e, http.MethodGet, "/", session.Optional(), h.indexGET)
+	h.guard.Handle(e, http.MethodGet, "/auth/oidc/login", server.Public, h.ssoLoginGET)
+	h.guard.Handle(e, http.MethodGet, "/auth/oidc/callback", server.Public, h.ssoCallbackGET)
+	h.guard.Handle(e, http.MethodGet, "/requests", session, h.requestsGET)
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute)
+		h.guard.Handle(g, http.MethodGet, "/requests/upload/:value", server.Public, h.uploadGET)
+		h.guard.Handle(g, http.MethodGet, "/manage/:value", server.Public, h.manageGET)
+		h.guard.Handle(g, http.MethodPatch, "/manage/:value", server.Public, h.managePATCH)
+		h.guard.Handle(g, http.MethodDelete, "/manage/:value", server.Public, h.manageDELETE)
+	})
+	limitCreate := h.svc.Limiter.Limit(server.BudgetCreate)
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute)
+		h.guard.Handle(g, http.MethodPut, "/", session, h.indexPUT, limitCreate)
+		h.guard.Handle(g, http.MethodPost, "/requests", session, h.requestsPOST, limitCreate)
+		h.guard.Handle(g, http.MethodPut, "/requests/upload/:value", server.Public, h.uploadPUT, limitCreate)
 	})
-	e.HandleFunc("/:value", h.secretPOST, "POST")
-	e.HandleFunc("/:value", h.secretGET, "GET")
+	// secret pages only ask for confirmation, revealing takes POST; link unfurlers and crawlers get neutral
+	// preview without looking up the secret at all
+	e.Group(func(g *flow.Mux) {
+		g.Use(server.SecretRoute, h.svc.Limiter.Limit(server.BudgetReveal), server.LinkPreview(http.HandlerFunc(h.previewGET)))
+		h.guard.Handle(g, http.MethodGet, "/requests/reveal/:value", server.Public, h.revealGET)
+		h.guard.Handle(g, http.MethodPost, "/:value", server.Public, h.secretPOST)
+		h.guard.Handle(g, http.MethodGet, "/:value", server.Public, h.secretGET)
+	})
+}
+
+// Routes lists every route registered by AddHandlers with its auth policy
+func (h *handlers) Routes() []server.Route {
+	return h.guard.Routes()
 }
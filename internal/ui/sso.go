@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pudottapommin/golib/http/middleware/csrf"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/audit"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/sso"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/ui"
+)
+
+const (
+	ssoCookieName = "oss_oidc"
+	ssoFlowTTL    = time.Minute * 10
+)
+
+func (h *handlers) ssoLoginGET(w http.ResponseWriter, r *http.Request) {
+	if h.svc.SSO == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	flow, url, err := h.svc.SSO.Begin(r.Context())
+	if err == nil {
+		err = h.svc.SSOFlows.Save(r.Context(), flow, ssoFlowTTL)
+	}
+	if err != nil {
+		h.l.Error("failed to start sso login", "error", err)
+		h.renderAuthError(w, r, "Single sign-on is unavailable, try again later")
+		return
+	}
+
+	// lax so the cookie comes back with the top-level redirect from provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoCookieName,
+		Value:    flow.State,
+		Path:     "/auth/oidc",
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   int(ssoFlowTTL.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (h *handlers) ssoCallbackGET(w http.ResponseWriter, r *http.Request) {
+	if h.svc.SSO == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: ssoCookieName, Path: "/auth/oidc", MaxAge: -1, Secure: true, HttpOnly: true})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		h.l.Warn("sso login rejected by provider", "error", e, "description", q.Get("error_description"))
+		h.renderAuthError(w, r, "Single sign-on was cancelled or rejected")
+		return
+	}
+
+	state := q.Get("state")
+	ck, err := r.Cookie(ssoCookieName)
+	if err != nil || ck.Value != state {
+		h.renderAuthError(w, r, "Login session expired, try again")
+		return
+	}
+	flow, err := h.svc.SSOFlows.Take(r.Context(), state)
+	if err != nil {
+		if !errors.Is(err, sso.ErrInvalidState) {
+			h.l.Error("failed to load sso login flow", "error", err)
+		}
+		h.renderAuthError(w, r, "Login session expired, try again")
+		return
+	}
+
+	identity, err := h.svc.SSO.Finish(r.Context(), flow, state, q.Get("code"))
+	switch {
+	case errors.Is(err, sso.ErrAccessDenied):
+		h.svc.Audit.Record(r.Context(), audit.Event{Action: audit.ActionLoginFailed, Credential: "sso", Reason: "access_denied"})
+		h.renderAuthError(w, r, "Your account is not allowed to use this service")
+		return
+	case err != nil:
+		h.l.Error("failed to finish sso login", "error", err)
+		h.renderAuthError(w, r, "Single sign-on failed, try again")
+		return
+	}
+	h.l.Info("sso login", "subject", identity.Subject, "email", identity.Email)
+
+	subject := identity.Email
+	if subject == "" {
+		subject = identity.Subject
+	}
+	if _, err = h.svc.Sessions.Login(w, r, subject); err != nil {
+		h.l.Error("failed to create session", "error", err)
+		h.renderAuthError(w, r, "Single sign-on failed, try again")
+		return
+	}
+	h.svc.Audit.Record(r.Context(), audit.Event{Action: audit.ActionLoginSucceeded, Actor: subject, Credential: "sso"})
+	// strict auth cookie is not sent on redirects chained from provider, continue with same-site navigation
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(`<!doctype html><meta http-equiv="refresh" content="0;url=/"><a href="/">Continue</a>`))
+}
+
+func (h *handlers) renderAuthError(w http.ResponseWriter, r *http.Request, msg string) {
+	cfg := h.cfg.Load()
+	model := ui.PageIndex{
+		PasswordEnabled: cfg.Auth.Username != "" || h.svc.SSO == nil,
+		SSOEnabled:      h.svc.SSO != nil,
+		AuthError:       msg,
+		FormModel:       &ui.FormModel{CsrfField: csrf.FromContextFieldName(r.Context()), CsrfToken: csrf.FromContextStringed(r.Context())},
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	if err := ui.Index.ExecutePage(r.Context(), w, model); err != nil {
+		h.l.Error("failed to execute index page template", "error", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package audit records who created, viewed, failed to unlock and burned which secret. Events never
+// carry secret content, only identifiers and who did what from where.
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pudottapommin/golib/http/middleware/requestid"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+type (
+	Action string
+
+	// Event is single audit record, Target is ID of secret, request or token depending on Action
+	Event struct {
+		Time       time.Time `json:"time"`
+		Action     Action    `json:"action"`
+		Target     string    `json:"target,omitempty"`
+		Reason     string    `json:"reason,omitempty"`
+		RequestID  string    `json:"request_id,omitempty"`
+		Actor      string    `json:"actor,omitempty"`
+		Credential string    `json:"credential,omitempty"`
+		ClientIP   string    `json:"client_ip,omitempty"`
+	}
+
+	// Sink writes events somewhere durable, it has to be safe for concurrent use
+	Sink interface {
+		Write(context.Context, Event) error
+		Close() error
+	}
+
+	// Auditor fills events with origin of request and writes them to every sink
+	Auditor struct {
+		l     *slog.Logger
+		sinks []Sink
+	}
+	OptsFn func(*Auditor)
+
+	origin struct {
+		requestID string
+		clientIP  string
+	}
+	originContextKey struct{}
+)
+
+const (
+	ActionSecretCreated      Action = "secret.created"
+	ActionSecretViewed       Action = "secret.viewed"
+	ActionSecretUnlockFailed Action = "secret.unlock_failed"
+	ActionSecretBurned       Action = "secret.burned"
+	ActionSecretExtended     Action = "secret.extended"
+	ActionSecretExpired      Action = "secret.expired"
+	ActionRequestCreated     Action = "request.created"
+	ActionTokenCreated       Action = "token.created"
+	ActionTokenRevoked       Action = "token.revoked"
+	ActionLoginSucceeded     Action = "login.succeeded"
+	ActionLoginFailed        Action = "login.failed"
+)
+
+const (
+	// ActorAnonymous is actor of requests without credentials
+	ActorAnonymous = "anonymous"
+	// ActorSystem is actor of events not caused by request, e.g. expiry
+	ActorSystem = "system"
+)
+
+var storageActions = map[storage.RecordEventType]Action{
+	storage.RecordEventStored:   ActionSecretCreated,
+	storage.RecordEventViewed:   ActionSecretViewed,
+	storage.RecordEventBurned:   ActionSecretBurned,
+	storage.RecordEventExtended: ActionSecretExtended,
+	storage.RecordEventExpired:  ActionSecretExpired,
+}
+
+func WithSink(s Sink) OptsFn {
+	return func(a *Auditor) {
+		a.sinks = append(a.sinks, s)
+	}
+}
+
+func WithLogger(l *slog.Logger) OptsFn {
+	return func(a *Auditor) {
+		a.l = l
+	}
+}
+
+// New creates auditor, auditor without sinks drops every event
+func New(opts ...OptsFn) *Auditor {
+	a := &Auditor{l: slog.Default()}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Middleware remembers request ID and client IP, so events recorded deeper with request context,
+// e.g. by Storage, know where they came from. It has to run after requestid and TrustProxies middlewares.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o := origin{requestID: requestid.Get(r), clientIP: server.ClientIP(r)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), originContextKey{}, o)))
+	})
+}
+
+// Record writes event to every sink. Request ID, client IP and actor are taken from ctx unless set,
+// failing sinks are logged and never fail the operation which is audited.
+func (a *Auditor) Record(ctx context.Context, e Event) {
+	if len(a.sinks) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	o, fromRequest := ctx.Value(originContextKey{}).(origin)
+	if e.RequestID == "" {
+		e.RequestID = o.requestID
+	}
+	if e.ClientIP == "" {
+		e.ClientIP = o.clientIP
+	}
+	if e.Actor == "" {
+		p := server.PrincipalFromContext(ctx)
+		switch {
+		case p.IsAuthenticated():
+			e.Actor, e.Credential = p.Subject, p.Credential.String()
+		case fromRequest:
+			e.Actor = ActorAnonymous
+		default:
+			e.Actor = ActorSystem
+		}
+	}
+
+	// context of finished request must not stop audit trail from being written
+	ctx = context.WithoutCancel(ctx)
+	for _, s := range a.sinks {
+		if err := s.Write(ctx, e); err != nil {
+			a.l.Error("failed to write audit event", "action", e.Action, "target", e.Target, "error", err)
+		}
+	}
+}
+
+// Observe implements storage.Observer, it records lifecycle of secrets
+func (a *Auditor) Observe(ctx context.Context, e storage.RecordEvent) {
+	action, ok := storageActions[e.Type]
+	if !ok {
+		return
+	}
+	a.Record(ctx, Event{Action: action, Target: string(e.ID)})
+}
+
+// Close closes every sink
+func (a *Auditor) Close() error {
+	var errs []error
+	for _, s := range a.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
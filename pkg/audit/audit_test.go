@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/golib/http/middleware/requestid"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+type recordingSink struct {
+	events []Event
+}
+
+func (s *recordingSink) Write(_ context.Context, e Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func TestAuditorRecordsOrigin(t *testing.T) {
+	sink := new(recordingSink)
+	a := New(WithSink(sink))
+
+	h := requestid.New().Handler(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Observe(r.Context(), storage.RecordEvent{Type: storage.RecordEventViewed, ID: "s1"})
+		r = server.WithPrincipal(r, &server.Principal{Credential: server.CredentialToken, Subject: "ci"})
+		a.Observe(r.Context(), storage.RecordEvent{Type: storage.RecordEventStored, ID: "s2"})
+		a.Record(r.Context(), Event{Action: ActionLoginFailed, Actor: "mallory", Reason: "invalid_credentials"})
+	})))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	a.Observe(context.Background(), storage.RecordEvent{Type: storage.RecordEventExpired, ID: "s3"})
+
+	require.Len(t, sink.events, 4)
+	for _, e := range sink.events[:3] {
+		assert.Equal(t, "req-1", e.RequestID)
+		assert.Equal(t, "10.0.0.1", e.ClientIP)
+		assert.False(t, e.Time.IsZero())
+	}
+	assert.Equal(t, Event{Action: ActionSecretViewed, Target: "s1", Actor: ActorAnonymous}, strip(sink.events[0]))
+	assert.Equal(t, Event{Action: ActionSecretCreated, Target: "s2", Actor: "ci", Credential: "token"}, strip(sink.events[1]))
+	assert.Equal(t, Event{Action: ActionLoginFailed, Actor: "mallory", Reason: "invalid_credentials"}, strip(sink.events[2]))
+	assert.Equal(t, Event{Action: ActionSecretExpired, Target: "s3", Actor: ActorSystem}, withoutTime(sink.events[3]))
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	a := New(WithSink(sink))
+	a.Record(context.Background(), Event{Action: ActionSecretCreated, Target: "s1"})
+	a.Record(context.Background(), Event{Action: ActionSecretBurned, Target: "s1"})
+	require.NoError(t, a.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var actions []Action
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []Action{ActionSecretCreated, ActionSecretBurned}, actions)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestValkeySink(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	a := New(WithSink(NewValkeySink(client, "oss_audit", 100)))
+	a.Record(context.Background(), Event{Action: ActionSecretViewed, Target: "s1", ClientIP: "10.0.0.1"})
+
+	entries, err := client.Do(context.Background(), client.B().Xrange().Key("oss_audit").Start("-").End("+").Build()).AsXRange()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "secret.viewed", entries[0].FieldValues["action"])
+	assert.Equal(t, "s1", entries[0].FieldValues["target"])
+	assert.Equal(t, "10.0.0.1", entries[0].FieldValues["client_ip"])
+	assert.Equal(t, ActorSystem, entries[0].FieldValues["actor"])
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewSyslogSink("udp", conn.LocalAddr().String(), "oss")
+	require.NoError(t, err)
+	defer sink.Close()
+	New(WithSink(sink)).Record(context.Background(), Event{Action: ActionSecretBurned, Target: "s1"})
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<86>"), "authpriv.info priority, got %q", msg)
+	assert.Contains(t, msg, "oss")
+	assert.Contains(t, msg, `"action":"secret.burned"`)
+}
+
+// strip drops fields which differ between runs
+func strip(e Event) Event {
+	e.RequestID, e.ClientIP = "", ""
+	return withoutTime(e)
+}
+
+func withoutTime(e Event) Event {
+	e.Time = time.Time{}
+	return e
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+type fileSink struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewFileSink appends events as JSON lines to file at path, "-" writes to stdout
+func NewFileSink(path string) (Sink, error) {
+	if path == "-" {
+		return &fileSink{w: nopCloser{os.Stdout}}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: error opening log file: %w", err)
+	}
+	return &fileSink{w: f}, nil
+}
+
+func (s *fileSink) Write(_ context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: error encoding event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("audit: error writing log file: %w", err)
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
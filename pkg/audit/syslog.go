@@ -0,0 +1,38 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink sends events as JSON messages with authpriv facility, empty network and addr use local syslog
+func NewSyslogSink(network, addr, tag string) (Sink, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, tag)
+	if err != nil {
+		return nil, fmt.Errorf("audit: error connecting to syslog: %w", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(_ context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: error encoding event: %w", err)
+	}
+	if err = s.w.Info(string(b)); err != nil {
+		return fmt.Errorf("audit: error writing to syslog: %w", err)
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+// NewSyslogSink is not supported on this platform
+func NewSyslogSink(_, _, _ string) (Sink, error) {
+	return nil, errors.New("audit: syslog is not supported on this platform")
+}
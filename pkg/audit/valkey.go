@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type valkeySink struct {
+	client valkey.Client
+	stream string
+	maxLen int64
+}
+
+// NewValkeySink appends events to Valkey stream, stream is approximately trimmed to maxLen entries,
+// zero maxLen keeps every event
+func NewValkeySink(client valkey.Client, stream string, maxLen int64) Sink {
+	return &valkeySink{client: client, stream: stream, maxLen: maxLen}
+}
+
+func (s *valkeySink) Write(ctx context.Context, e Event) error {
+	args := make([]string, 0, 20)
+	if s.maxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(s.maxLen, 10))
+	}
+	args = append(args, "*",
+		"time", e.Time.Format(time.RFC3339Nano),
+		"action", string(e.Action),
+		"target", e.Target,
+		"reason", e.Reason,
+		"request_id", e.RequestID,
+		"actor", e.Actor,
+		"credential", e.Credential,
+		"client_ip", e.ClientIP,
+	)
+	cmd := s.client.B().Arbitrary("XADD").Keys(s.stream).Args(args...).Build()
+	if err := s.client.Do(ctx, cmd).Error(); err != nil {
+		return fmt.Errorf("audit: error appending to stream: %w", err)
+	}
+	return nil
+}
+
+func (_ *valkeySink) Close() error {
+	return nil
+}
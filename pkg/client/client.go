@@ -0,0 +1,294 @@
+// Package client is a Go SDK for the one-time secrets service HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUserAgent = "oss-client/1"
+	passphraseHeader = "X-OSS-Passphrase"
+)
+
+type (
+	Client struct {
+		baseURL    string
+		http       *http.Client
+		userAgent  string
+		username   string
+		password   string
+		token      string
+		maxRetries int
+		backoff    time.Duration
+		maxBackoff time.Duration
+	}
+	OptsFn func(*Client)
+)
+
+// New creates client for server at baseURL, e.g. https://secrets.example.com
+func New(baseURL string, opts ...OptsFn) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		http:       &http.Client{Timeout: 30 * time.Second},
+		userAgent:  defaultUserAgent,
+		maxRetries: 3,
+		backoff:    200 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func WithHTTPClient(hc *http.Client) OptsFn {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithBasicAuth authenticates requests with server username and password
+func WithBasicAuth(username, password string) OptsFn {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithToken authenticates requests with bearer API token
+func WithToken(token string) OptsFn {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets how many times failed requests are retried, backoff doubles up to maxBackoff
+func WithRetries(max int, backoff, maxBackoff time.Duration) OptsFn {
+	return func(c *Client) {
+		c.maxRetries = max
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func WithUserAgent(ua string) OptsFn {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// Create stores new secret and returns its links
+func (c *Client) Create(ctx context.Context, req *CreateRequest) (*Secret, error) {
+	body := createRequestData{
+		Value:      req.Value,
+		Recipients: req.Recipients,
+	}
+	if req.Passphrase != "" {
+		body.Password = &req.Passphrase
+	}
+	if req.TTL > 0 {
+		expiration := int(req.TTL / time.Second)
+		body.Expiration = &expiration
+	}
+	if req.MaxViews > 0 {
+		body.MaxViews = &req.MaxViews
+	}
+	if req.Notify != nil {
+		body.Notify = &notifyData{Webhook: req.Notify.Webhook, Email: req.Notify.Email}
+	}
+	for _, a := range req.Attachments {
+		body.Attachments = append(body.Attachments, attachmentData{Name: a.Name, Content: a.Content})
+	}
+
+	var res secretResponseData
+	if err := c.do(ctx, http.MethodPut, "/api/create", body, &res); err != nil {
+		return nil, err
+	}
+	return &Secret{URL: res.Url, ManageURL: res.ManageUrl, ExpiresAt: res.ExpiresAt}, nil
+}
+
+// Reveal fetches secret behind link, each call consumes a view
+func (c *Client) Reveal(ctx context.Context, link string) (*RevealedSecret, error) {
+	return c.RevealWithPassphrase(ctx, link, "")
+}
+
+// RevealWithPassphrase reveals passphrase protected secret, wrong passphrase matches ErrForbidden and
+// repeated failures ErrRateLimited or ErrGone once server burns the secret
+func (c *Client) RevealWithPassphrase(ctx context.Context, link, passphrase string) (*RevealedSecret, error) {
+	value, err := linkValue(link)
+	if err != nil {
+		return nil, err
+	}
+
+	var header http.Header
+	if passphrase != "" {
+		header = http.Header{passphraseHeader: {passphrase}}
+	}
+	var res revealResponseData
+	if err = c.doHeader(ctx, http.MethodPost, "/api/"+value, header, nil, &res); err != nil {
+		return nil, err
+	}
+	s := &RevealedSecret{Value: res.Value, Sealed: res.Sealed, ExpiresAt: res.ExpiresAt}
+	for _, a := range res.Attachments {
+		s.Attachments = append(s.Attachments, Attachment{Name: a.Name, Content: a.Content})
+	}
+	return s, nil
+}
+
+// Burn deletes secret using its manage link
+func (c *Client) Burn(ctx context.Context, manageLink string) error {
+	value, err := linkValue(manageLink)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodDelete, "/api/manage/"+value, nil, nil)
+}
+
+// Status reports secret state using its manage link without revealing it
+func (c *Client) Status(ctx context.Context, manageLink string) (*Status, error) {
+	value, err := linkValue(manageLink)
+	if err != nil {
+		return nil, err
+	}
+
+	var res statusResponseData
+	if err = c.do(ctx, http.MethodGet, "/api/manage/"+value, nil, &res); err != nil {
+		return nil, err
+	}
+	return &Status{
+		State:     State(res.State),
+		Views:     res.Views,
+		ViewsLeft: res.ViewsLeft,
+		MaxViews:  res.MaxViews,
+		CreatedAt: res.CreatedAt,
+		ExpiresAt: res.ExpiresAt,
+	}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	return c.doHeader(ctx, method, path, nil, body, out)
+}
+
+func (c *Client) doHeader(ctx context.Context, method, path string, header http.Header, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, path, header, payload)
+		if err == nil && res.StatusCode < http.StatusBadRequest {
+			defer res.Body.Close()
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(res.Body).Decode(out)
+		}
+
+		var wait time.Duration
+		if err == nil {
+			apiErr := newError(res)
+			err, wait = apiErr, apiErr.RetryAfter
+			res.Body.Close()
+		}
+		// waits longer than maxBackoff, e.g. lockouts, are left to caller
+		if attempt >= c.maxRetries || !retryable(method, err) || wait > c.maxBackoff || ctx.Err() != nil {
+			return err
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff = min(backoff*2, c.maxBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.username != "" || c.password != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.http.Do(req)
+}
+
+// retryable reports whether failed request may be sent again, creating and revealing are retried only
+// when server did not process it so a secret is never stored twice nor a view consumed without response
+func retryable(method string, err error) bool {
+	idempotent := method != http.MethodPut && method != http.MethodPost
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent
+		}
+		return false
+	}
+	return idempotent
+}
+
+func retryAfter(res *http.Response) time.Duration {
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 0
+}
+
+// linkValue extracts last path segment of secret or manage link, bare values are passed through
+func linkValue(link string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return "", err
+	}
+	value := u.Path[strings.LastIndexByte(u.Path, '/')+1:]
+	if value == "" {
+		return "", fmt.Errorf("client: invalid link %q", link)
+	}
+	return value, nil
+}
@@ -0,0 +1,247 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/config"
+	"github.com/pudottapommin/onetime-secrets-service/internal/api"
+	"github.com/pudottapommin/onetime-secrets-service/internal/services"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/client"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func newTestServer(t *testing.T, mutate func(*config.Config)) *httptest.Server {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	db, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	cfg := new(config.Config)
+	require.NoError(t, cfg.Load())
+	if mutate != nil {
+		mutate(cfg)
+	}
+	pCfg := new(atomic.Pointer[config.Config])
+	pCfg.Store(cfg)
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mux := flow.New()
+	svc, err := services.New(pCfg, db, metrics.New(), l)
+	require.NoError(t, err)
+	api.NewHandlers(pCfg, svc, l).AddHandlers(mux)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	cfg.Server.Domain = srv.URL
+	return srv
+}
+
+func TestClientLifecycle(t *testing.T) {
+	srv := newTestServer(t, nil)
+	ctx := context.Background()
+
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	secret, err := c.Create(ctx, &client.CreateRequest{
+		Value:       "hunter2",
+		TTL:         time.Hour,
+		MaxViews:    2,
+		Attachments: []client.Attachment{{Name: "key.txt", Content: []byte("data")}},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret.URL)
+	assert.NotEmpty(t, secret.ManageURL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), secret.ExpiresAt, time.Minute)
+
+	status, err := c.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StatePending, status.State)
+	assert.EqualValues(t, 2, status.MaxViews)
+
+	revealed, err := c.Reveal(ctx, secret.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", revealed.Value)
+	require.Len(t, revealed.Attachments, 1)
+	assert.Equal(t, "key.txt", revealed.Attachments[0].Name)
+	assert.Equal(t, []byte("data"), revealed.Attachments[0].Content)
+
+	status, err = c.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StateViewed, status.State)
+	assert.EqualValues(t, 1, status.ViewsLeft)
+
+	require.NoError(t, c.Burn(ctx, secret.ManageURL))
+
+	_, err = c.Reveal(ctx, secret.URL)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	status, err = c.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StateBurned, status.State)
+}
+
+func TestClientAuth(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.IsEnabled = true
+		cfg.Auth.Username = "admin"
+		cfg.Auth.Password = "pass"
+	})
+	ctx := context.Background()
+
+	c, err := client.New(srv.URL, client.WithBasicAuth("admin", "pass"))
+	require.NoError(t, err)
+	_, err = c.Create(ctx, &client.CreateRequest{Value: "hunter2"})
+	require.NoError(t, err)
+
+	_, err = c.Reveal(ctx, srv.URL+"/00-missing")
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"url":"u","manage_url":"m","expires_at":"2026-01-01T00:00:00Z"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(srv.URL, client.WithRetries(2, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+	secret, err := c.Create(context.Background(), &client.CreateRequest{Value: "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "u", secret.URL)
+	assert.EqualValues(t, 3, calls.Load())
+
+	calls.Store(0)
+	c, err = client.New(srv.URL, client.WithRetries(1, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+	_, err = c.Create(context.Background(), &client.CreateRequest{Value: "x"})
+	assert.ErrorIs(t, err, client.ErrServer)
+}
+
+func TestClientTokens(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.Auth.Username = "admin"
+		cfg.Auth.Password = "pass"
+	})
+	ctx := context.Background()
+
+	admin, err := client.New(srv.URL, client.WithBasicAuth("admin", "pass"))
+	require.NoError(t, err)
+	token, err := admin.CreateToken(ctx, &client.CreateTokenRequest{Name: "ci", Scopes: []string{"secrets:create"}, RateLimit: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, token.Value)
+
+	list, err := admin.ListTokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].Value)
+
+	c, err := client.New(srv.URL, client.WithToken(token.Value), client.WithRetries(0, 0, 0))
+	require.NoError(t, err)
+	secret, err := c.Create(ctx, &client.CreateRequest{Value: "hunter2"})
+	require.NoError(t, err)
+
+	_, err = c.Create(ctx, &client.CreateRequest{Value: "hunter2"})
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	_, err = c.ListTokens(ctx)
+	assert.ErrorIs(t, err, client.ErrForbidden)
+
+	status, err := admin.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StatePending, status.State)
+
+	require.NoError(t, admin.RevokeToken(ctx, token.ID))
+	_, err = c.Create(ctx, &client.CreateRequest{Value: "hunter2"})
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+
+	anonymous, err := client.New(srv.URL)
+	require.NoError(t, err)
+	_, err = anonymous.ListTokens(ctx)
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+
+	sid := secret.URL[strings.LastIndexByte(secret.URL, '-')+1:]
+	assert.ErrorIs(t, anonymous.BurnByID(ctx, sid), client.ErrUnauthorized)
+	require.NoError(t, admin.BurnByID(ctx, sid))
+	status, err = admin.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StateBurned, status.State)
+}
+
+func TestClientPassphraseLockout(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.Lockout.Secret.MaxFailures = 2
+		cfg.Lockout.BurnAfter = 3
+	})
+	ctx := context.Background()
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	secret, err := c.Create(ctx, &client.CreateRequest{Value: "hunter2", Passphrase: "correct horse", MaxViews: 5})
+	require.NoError(t, err)
+
+	_, err = c.Reveal(ctx, secret.URL)
+	require.ErrorIs(t, err, client.ErrForbidden)
+
+	revealed, err := c.RevealWithPassphrase(ctx, secret.URL, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", revealed.Value)
+
+	// successful reveal resets secret failures, second failure in a row locks it out
+	_, err = c.RevealWithPassphrase(ctx, secret.URL, "wrong")
+	require.ErrorIs(t, err, client.ErrForbidden)
+	_, err = c.RevealWithPassphrase(ctx, secret.URL, "wrong")
+	require.ErrorIs(t, err, client.ErrRateLimited)
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Greater(t, apiErr.RetryAfter, time.Minute)
+
+	_, err = c.RevealWithPassphrase(ctx, secret.URL, "correct horse")
+	require.ErrorIs(t, err, client.ErrRateLimited, "locked out secret must not be revealed even with correct passphrase")
+
+	status, err := c.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), status.Views)
+}
+
+func TestClientPassphraseBurn(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.Lockout.BurnAfter = 2
+	})
+	ctx := context.Background()
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	secret, err := c.Create(ctx, &client.CreateRequest{Value: "hunter2", Passphrase: "correct horse"})
+	require.NoError(t, err)
+
+	_, err = c.RevealWithPassphrase(ctx, secret.URL, "wrong")
+	require.ErrorIs(t, err, client.ErrForbidden)
+	_, err = c.RevealWithPassphrase(ctx, secret.URL, "wrong")
+	require.ErrorIs(t, err, client.ErrGone)
+
+	status, err := c.Status(ctx, secret.ManageURL)
+	require.NoError(t, err)
+	assert.Equal(t, client.StateBurned, status.State)
+}
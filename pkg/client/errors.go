@@ -0,0 +1,60 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBadRequest   = errors.New("client: bad request")
+	ErrUnauthorized = errors.New("client: unauthorized")
+	ErrForbidden    = errors.New("client: forbidden")
+	ErrNotFound     = errors.New("client: secret not found")
+	ErrGone         = errors.New("client: secret burned")
+	ErrRateLimited  = errors.New("client: rate limited")
+	ErrServer       = errors.New("client: server error")
+)
+
+// Error is returned for non-2xx API responses, use errors.Is with Err* sentinels to match status classes
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set when server told client how long to wait, e.g. after too many wrong passphrases
+	RetryAfter time.Duration
+}
+
+func newError(res *http.Response) *Error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(msg)), RetryAfter: retryAfter(res)}
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrGone:
+		return e.StatusCode == http.StatusGone
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
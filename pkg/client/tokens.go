@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type (
+	CreateTokenRequest struct {
+		Name   string
+		Scopes []string
+		// TTL is rounded down to seconds, token never expires when zero
+		TTL time.Duration
+		// RateLimit is max requests per minute, zero means unlimited
+		RateLimit int
+	}
+	Token struct {
+		ID        string
+		Name      string
+		Scopes    []string
+		RateLimit int
+		CreatedAt time.Time
+		ExpiresAt time.Time
+		// Value is only set by CreateToken, server never returns it again
+		Value string
+	}
+)
+
+type (
+	tokenCreateData struct {
+		Name       string   `json:"name"`
+		Scopes     []string `json:"scopes"`
+		Expiration *int     `json:"expiration,omitempty"`
+		RateLimit  *int     `json:"rate_limit,omitempty"`
+	}
+	tokenResponseData struct {
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		RateLimit int        `json:"rate_limit"`
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		Token     string     `json:"token,omitempty"`
+	}
+)
+
+// CreateToken issues API token, requires admin scope or server credentials
+func (c *Client) CreateToken(ctx context.Context, req *CreateTokenRequest) (*Token, error) {
+	body := tokenCreateData{Name: req.Name, Scopes: req.Scopes}
+	if req.TTL > 0 {
+		expiration := int(req.TTL / time.Second)
+		body.Expiration = &expiration
+	}
+	if req.RateLimit > 0 {
+		body.RateLimit = &req.RateLimit
+	}
+
+	var res tokenResponseData
+	if err := c.do(ctx, http.MethodPost, "/api/admin/tokens", body, &res); err != nil {
+		return nil, err
+	}
+	return res.token(), nil
+}
+
+func (c *Client) ListTokens(ctx context.Context) ([]*Token, error) {
+	var res []tokenResponseData
+	if err := c.do(ctx, http.MethodGet, "/api/admin/tokens", nil, &res); err != nil {
+		return nil, err
+	}
+	list := make([]*Token, 0, len(res))
+	for _, t := range res {
+		list = append(list, t.token())
+	}
+	return list, nil
+}
+
+func (c *Client) RevokeToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/admin/tokens/"+url.PathEscape(id), nil, nil)
+}
+
+// BurnByID deletes secret by its ID without manage link, requires secrets:burn scope
+func (c *Client) BurnByID(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/secrets/"+url.PathEscape(id), nil, nil)
+}
+
+func (t *tokenResponseData) token() *Token {
+	token := &Token{
+		ID:        t.ID,
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		RateLimit: t.RateLimit,
+		CreatedAt: t.CreatedAt,
+		Value:     t.Token,
+	}
+	if t.ExpiresAt != nil {
+		token.ExpiresAt = *t.ExpiresAt
+	}
+	return token
+}
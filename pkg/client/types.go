@@ -0,0 +1,96 @@
+package client
+
+import (
+	"time"
+)
+
+type State string
+
+const (
+	StatePending State = "pending"
+	StateViewed  State = "viewed"
+	StateBurned  State = "burned"
+	StateExpired State = "expired"
+)
+
+type (
+	CreateRequest struct {
+		Value      string
+		Passphrase string
+		// TTL is rounded down to seconds, server default applies when zero
+		TTL         time.Duration
+		MaxViews    uint64
+		Attachments []Attachment
+		// Recipients are age X25519 recipients or ASCII armored OpenPGP public keys
+		Recipients []string
+		Notify     *Notify
+	}
+	Notify struct {
+		Webhook string
+		Email   string
+	}
+	Attachment struct {
+		Name    string
+		Content []byte
+	}
+	Secret struct {
+		URL       string
+		ManageURL string
+		ExpiresAt time.Time
+	}
+	RevealedSecret struct {
+		Value string
+		// Sealed is "age" or "openpgp" when secret was sealed to recipients
+		Sealed      string
+		Attachments []Attachment
+		ExpiresAt   time.Time
+	}
+	Status struct {
+		State     State
+		Views     uint64
+		ViewsLeft uint64
+		MaxViews  uint64
+		CreatedAt time.Time
+		ExpiresAt time.Time
+	}
+)
+
+// wire formats mirror internal/api DTOs which are not importable outside this module
+type (
+	createRequestData struct {
+		Value       string           `json:"value"`
+		Password    *string          `json:"password,omitempty"`
+		Expiration  *int             `json:"expiration,omitempty"`
+		MaxViews    *uint64          `json:"max_views,omitempty"`
+		Notify      *notifyData      `json:"notify,omitempty"`
+		Recipients  []string         `json:"recipients,omitempty"`
+		Attachments []attachmentData `json:"attachments,omitempty"`
+	}
+	notifyData struct {
+		Webhook string `json:"webhook,omitempty"`
+		Email   string `json:"email,omitempty"`
+	}
+	attachmentData struct {
+		Name    string `json:"name"`
+		Content []byte `json:"content"`
+	}
+	secretResponseData struct {
+		Url       string    `json:"url"`
+		ManageUrl string    `json:"manage_url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	revealResponseData struct {
+		Value       string           `json:"value"`
+		Sealed      string           `json:"sealed,omitempty"`
+		Attachments []attachmentData `json:"attachments,omitempty"`
+		ExpiresAt   time.Time        `json:"expires_at"`
+	}
+	statusResponseData struct {
+		State     string    `json:"state"`
+		Views     uint64    `json:"views"`
+		ViewsLeft uint64    `json:"views_left"`
+		MaxViews  uint64    `json:"max_views"`
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+)
@@ -0,0 +1,187 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+type SealedFormat string
+
+const (
+	FormatNone    SealedFormat = ""
+	FormatAge     SealedFormat = "age"
+	FormatOpenPGP SealedFormat = "openpgp"
+
+	MaxRecipients = 10
+
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+	pgpArmorHeader = "-----BEGIN PGP MESSAGE-----"
+	pgpKeyHeader   = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpMessageType = "PGP MESSAGE"
+)
+
+var (
+	ErrInvalidRecipient   = errors.New("encryption: invalid recipient public key")
+	ErrMixedRecipients    = errors.New("encryption: recipients must all be age or all be OpenPGP keys")
+	ErrTooManyRecipients  = fmt.Errorf("encryption: at most %d recipients are allowed", MaxRecipients)
+	ErrUnknownSealedInput = errors.New("encryption: input is neither age nor OpenPGP armored message")
+)
+
+// Recipients seals payloads to named public keys, either age X25519 or OpenPGP
+type Recipients struct {
+	format SealedFormat
+	age    []age.Recipient
+	pgp    openpgp.EntityList
+}
+
+// ParseRecipients accepts age1… recipients or ASCII armored OpenPGP public keys, empty entries are skipped
+func ParseRecipients(keys []string) (*Recipients, error) {
+	r := new(Recipients)
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		var format SealedFormat
+		switch {
+		case strings.HasPrefix(key, "age1"):
+			format = FormatAge
+			recipient, err := age.ParseX25519Recipient(key)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+			}
+			r.age = append(r.age, recipient)
+		case strings.HasPrefix(key, pgpKeyHeader):
+			format = FormatOpenPGP
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidRecipient, err)
+			}
+			for _, e := range entities {
+				if _, ok := e.EncryptionKey(time.Now()); !ok {
+					return nil, fmt.Errorf("%w: key has no valid encryption subkey", ErrInvalidRecipient)
+				}
+			}
+			r.pgp = append(r.pgp, entities...)
+		default:
+			return nil, ErrInvalidRecipient
+		}
+
+		if r.format != FormatNone && r.format != format {
+			return nil, ErrMixedRecipients
+		}
+		r.format = format
+	}
+	if len(r.age)+len(r.pgp) > MaxRecipients {
+		return nil, ErrTooManyRecipients
+	}
+	return r, nil
+}
+
+func (r *Recipients) Format() SealedFormat {
+	return r.format
+}
+
+func (r *Recipients) IsEmpty() bool {
+	return r.format == FormatNone
+}
+
+// Encrypt returns ASCII armored ciphertext openable only by private keys of recipients
+func (r *Recipients) Encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch r.format {
+	case FormatAge:
+		aw := agearmor.NewWriter(&buf)
+		w, err := age.Encrypt(aw, r.age...)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if err = errors.Join(w.Close(), aw.Close()); err != nil {
+			return nil, err
+		}
+	case FormatOpenPGP:
+		aw, err := pgparmor.Encode(&buf, pgpMessageType, nil)
+		if err != nil {
+			return nil, err
+		}
+		w, err := openpgp.Encrypt(aw, r.pgp, nil, &openpgp.FileHints{IsBinary: true}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if err = errors.Join(w.Close(), aw.Close()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidRecipient
+	}
+	return buf.Bytes(), nil
+}
+
+// DetectFormat tells which format sealed ciphertext uses, FormatNone for anything else
+func DetectFormat(ciphertext []byte) SealedFormat {
+	trimmed := bytes.TrimSpace(ciphertext)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte(ageArmorHeader)):
+		return FormatAge
+	case bytes.HasPrefix(trimmed, []byte(pgpArmorHeader)):
+		return FormatOpenPGP
+	default:
+		return FormatNone
+	}
+}
+
+// OpenSealed decrypts ciphertext produced by Recipients.Encrypt using age identities file
+// or ASCII armored OpenPGP private key, passphrase unlocks protected OpenPGP keys
+func OpenSealed(ciphertext, identity, passphrase []byte) ([]byte, error) {
+	switch DetectFormat(ciphertext) {
+	case FormatAge:
+		identities, err := age.ParseIdentities(bytes.NewReader(identity))
+		if err != nil {
+			return nil, err
+		}
+		r, err := age.Decrypt(agearmor.NewReader(bytes.NewReader(bytes.TrimSpace(ciphertext))), identities...)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case FormatOpenPGP:
+		keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(identity))
+		if err != nil {
+			return nil, err
+		}
+		if len(passphrase) > 0 {
+			for _, e := range keyring {
+				if err = e.DecryptPrivateKeys(passphrase); err != nil {
+					return nil, err
+				}
+			}
+		}
+		block, err := pgparmor.Decode(bytes.NewReader(ciphertext))
+		if err != nil {
+			return nil, err
+		}
+		md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(md.UnverifiedBody)
+	default:
+		return nil, ErrUnknownSealedInput
+	}
+}
@@ -0,0 +1,76 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecipientsAge(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	r, err := ParseRecipients([]string{identity.Recipient().String(), ""})
+	require.NoError(t, err)
+	assert.Equal(t, FormatAge, r.Format())
+
+	sealed, err := r.Encrypt([]byte("hunter2"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatAge, DetectFormat(sealed))
+
+	opened, err := OpenSealed(sealed, []byte(identity.String()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", string(opened))
+}
+
+func TestRecipientsOpenPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("Recipient", "", "recipient@example.com", nil)
+	require.NoError(t, err)
+
+	var public, private bytes.Buffer
+	w, err := pgparmor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	w, err = pgparmor.Encode(&private, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	r, err := ParseRecipients([]string{public.String()})
+	require.NoError(t, err)
+	assert.Equal(t, FormatOpenPGP, r.Format())
+
+	sealed, err := r.Encrypt([]byte("hunter2"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatOpenPGP, DetectFormat(sealed))
+
+	opened, err := OpenSealed(sealed, private.Bytes(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", string(opened))
+}
+
+func TestParseRecipientsErrors(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, err = ParseRecipients([]string{"ssh-ed25519 AAAA"})
+	assert.ErrorIs(t, err, ErrInvalidRecipient)
+
+	_, err = ParseRecipients([]string{identity.Recipient().String(), "-----BEGIN PGP PUBLIC KEY BLOCK-----\n"})
+	assert.Error(t, err)
+
+	keys := make([]string, MaxRecipients+1)
+	for i := range keys {
+		keys[i] = identity.Recipient().String()
+	}
+	_, err = ParseRecipients(keys)
+	assert.ErrorIs(t, err, ErrTooManyRecipients)
+
+	assert.Equal(t, FormatNone, DetectFormat([]byte("plain text")))
+}
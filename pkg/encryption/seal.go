@@ -0,0 +1,63 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+)
+
+var ErrInvalidSealed = errors.New("encryption: invalid sealed message")
+
+// GenerateKeyPair returns age X25519 identity and its recipient, used to seal messages for a single recipient
+func GenerateKeyPair() (identity, recipient string, err error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", err
+	}
+	return id.String(), id.Recipient().String(), nil
+}
+
+// Recipient derives age recipient from identity produced by GenerateKeyPair
+func Recipient(identity string) (string, error) {
+	id, err := age.ParseX25519Identity(identity)
+	if err != nil {
+		return "", err
+	}
+	return id.Recipient().String(), nil
+}
+
+// Seal encrypts msg with age so only holder of identity matching recipient can open it
+func Seal(recipient string, msg []byte) ([]byte, error) {
+	r, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Open decrypts message produced by Seal
+func Open(identity string, sealed []byte) ([]byte, error) {
+	id, err := age.ParseX25519Identity(identity)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(sealed), id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSealed, err)
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,41 @@
+package encryption
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	identity, recipient, err := GenerateKeyPair()
+	require.NoError(t, err)
+
+	sealed, err := Seal(recipient, []byte("secret key"))
+	require.NoError(t, err)
+
+	opened, err := Open(identity, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret key"), opened)
+
+	derived, err := Recipient(identity)
+	require.NoError(t, err)
+	assert.Equal(t, recipient, derived)
+
+	other, _, err := GenerateKeyPair()
+	require.NoError(t, err)
+	_, err = Open(other, sealed)
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+
+	// sealed messages are plain age files, any age implementation opens them
+	id, err := age.ParseX25519Identity(identity)
+	require.NoError(t, err)
+	r, err := age.Decrypt(bytes.NewReader(sealed), id)
+	require.NoError(t, err)
+	plain, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret key"), plain)
+}
@@ -0,0 +1,236 @@
+// Package lockout counts failed attempts (wrong passphrases, wrong credentials) and locks out
+// secrets, clients and usernames which fail too often.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type (
+	// Scope is kind of thing failures are counted for
+	Scope string
+
+	Key struct {
+		Scope Scope
+		Value string
+	}
+
+	// Policy locks key for Lockout once it fails MaxFailures times within Window
+	Policy struct {
+		MaxFailures int
+		Window      time.Duration
+		Lockout     time.Duration
+	}
+
+	// Store counts failures, it has to be safe for concurrent use
+	Store interface {
+		// Fail counts failure of key and locks it when policy is exceeded, returns failures
+		// within window and lockout duration when key got locked
+		Fail(ctx context.Context, key string, p Policy) (int, time.Duration, error)
+		// Locked returns remaining lockout of key, zero when key is not locked
+		Locked(ctx context.Context, key string) (time.Duration, error)
+		// Reset forgets failures and lockout of key
+		Reset(ctx context.Context, key string) error
+	}
+
+	// Result of counted failure
+	Result struct {
+		// Failures within window per scope
+		Failures map[Scope]int
+		// RetryAfter is longest lockout caused by this failure
+		RetryAfter time.Duration
+		// Burn tells that secret failed often enough to be burned
+		Burn bool
+	}
+
+	// Limiter applies policies per scope, scopes without policy are not limited
+	Limiter struct {
+		l        *slog.Logger
+		store    Store
+		fallback Store
+		timeout  time.Duration
+
+		mu        sync.RWMutex
+		policies  map[Scope]Policy
+		burnAfter int
+	}
+	OptsFn func(*Limiter)
+
+	// LockedError is returned while key is locked out
+	LockedError struct {
+		RetryAfter time.Duration
+	}
+)
+
+const (
+	ScopeSecret   Scope = "secret"
+	ScopeIP       Scope = "ip"
+	ScopeUsername Scope = "username"
+)
+
+var ErrLocked = errors.New("lockout: too many failed attempts")
+
+func SecretKey(id string) Key {
+	return Key{Scope: ScopeSecret, Value: id}
+}
+
+func IPKey(ip string) Key {
+	return Key{Scope: ScopeIP, Value: ip}
+}
+
+func UsernameKey(username string) Key {
+	return Key{Scope: ScopeUsername, Value: username}
+}
+
+func (k Key) String() string {
+	return string(k.Scope) + ":" + k.Value
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+func WithPolicy(scope Scope, p Policy) OptsFn {
+	return func(l *Limiter) {
+		if p.MaxFailures > 0 && p.Window > 0 {
+			l.policies[scope] = p
+		}
+	}
+}
+
+// WithFallback is used whenever store fails or does not answer within timeout, e.g. while Valkey is unreachable
+func WithFallback(store Store, timeout time.Duration) OptsFn {
+	return func(l *Limiter) {
+		l.fallback = store
+		l.timeout = timeout
+	}
+}
+
+// WithBurnAfter marks secret for burning after n wrong passphrases within secret window, zero disables it
+func WithBurnAfter(n int) OptsFn {
+	return func(l *Limiter) {
+		l.burnAfter = n
+	}
+}
+
+func WithLogger(l *slog.Logger) OptsFn {
+	return func(lim *Limiter) {
+		lim.l = l
+	}
+}
+
+func New(store Store, opts ...OptsFn) *Limiter {
+	l := &Limiter{l: slog.Default(), store: store, policies: make(map[Scope]Policy)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Reconfigure replaces policies and burn threshold with those set by opts, e.g. on config reload, counted
+// failures are kept. Options other than WithPolicy and WithBurnAfter are ignored.
+func (l *Limiter) Reconfigure(opts ...OptsFn) {
+	next := &Limiter{policies: make(map[Scope]Policy)}
+	for _, opt := range opts {
+		opt(next)
+	}
+	l.mu.Lock()
+	l.policies, l.burnAfter = next.policies, next.burnAfter
+	l.mu.Unlock()
+}
+
+func (l *Limiter) policy(scope Scope) (Policy, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	p, ok := l.policies[scope]
+	return p, ok
+}
+
+// Check returns *LockedError when any of keys is locked out
+func (l *Limiter) Check(ctx context.Context, keys ...Key) error {
+	var wait time.Duration
+	for _, key := range l.limited(keys) {
+		var d time.Duration
+		err := l.do(ctx, func(ctx context.Context, s Store) (err error) {
+			d, err = s.Locked(ctx, key.String())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		wait = max(wait, d)
+	}
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail counts failure for every key
+func (l *Limiter) Fail(ctx context.Context, keys ...Key) (*Result, error) {
+	res := &Result{Failures: make(map[Scope]int)}
+	for _, key := range l.limited(keys) {
+		var (
+			n int
+			d time.Duration
+		)
+		p, _ := l.policy(key.Scope)
+		err := l.do(ctx, func(ctx context.Context, s Store) (err error) {
+			n, d, err = s.Fail(ctx, key.String(), p)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Failures[key.Scope] = n
+		res.RetryAfter = max(res.RetryAfter, d)
+	}
+	l.mu.RLock()
+	res.Burn = l.burnAfter > 0 && res.Failures[ScopeSecret] >= l.burnAfter
+	l.mu.RUnlock()
+	return res, nil
+}
+
+// Reset forgets failures of keys, e.g. after successful login
+func (l *Limiter) Reset(ctx context.Context, keys ...Key) error {
+	for _, key := range l.limited(keys) {
+		if err := l.do(ctx, func(ctx context.Context, s Store) error { return s.Reset(ctx, key.String()) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) limited(keys []Key) []Key {
+	limited := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := l.policy(key.Scope); ok && key.Value != "" {
+			limited = append(limited, key)
+		}
+	}
+	return limited
+}
+
+func (l *Limiter) do(ctx context.Context, fn func(ctx context.Context, s Store) error) error {
+	if l.fallback == nil {
+		return fn(ctx, l.store)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, l.timeout)
+	err := fn(tctx, l.store)
+	cancel()
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	l.l.Warn("lockout store failed, using fallback", "error", err)
+	return fn(ctx, l.fallback)
+}
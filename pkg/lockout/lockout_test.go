@@ -0,0 +1,119 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func stores(t *testing.T) map[string]Store {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return map[string]Store{
+		"memory": NewMemory(),
+		"valkey": NewValkey(client),
+	}
+}
+
+func TestLimiterLocksOut(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(store,
+				WithPolicy(ScopeSecret, Policy{MaxFailures: 3, Window: time.Hour, Lockout: time.Minute}),
+				WithPolicy(ScopeIP, Policy{MaxFailures: 10, Window: time.Hour, Lockout: time.Minute}),
+			)
+			keys := []Key{SecretKey("s1"), IPKey("10.0.0.1"), UsernameKey("not limited")}
+
+			for i := 1; i < 3; i++ {
+				res, err := l.Fail(ctx, keys...)
+				require.NoError(t, err)
+				assert.Equal(t, i, res.Failures[ScopeSecret])
+				assert.Zero(t, res.RetryAfter)
+				require.NoError(t, l.Check(ctx, keys...))
+			}
+
+			res, err := l.Fail(ctx, keys...)
+			require.NoError(t, err)
+			assert.Equal(t, time.Minute, res.RetryAfter)
+			assert.False(t, res.Burn)
+			_, ok := res.Failures[ScopeUsername]
+			assert.False(t, ok, "scope without policy must not be counted")
+
+			err = l.Check(ctx, keys...)
+			require.ErrorIs(t, err, ErrLocked)
+			var locked *LockedError
+			require.True(t, errors.As(err, &locked))
+			assert.InDelta(t, time.Minute, locked.RetryAfter, float64(time.Second))
+
+			// other secrets from the same client are still allowed
+			require.NoError(t, l.Check(ctx, SecretKey("s2"), IPKey("10.0.0.1")))
+
+			require.NoError(t, l.Reset(ctx, SecretKey("s1")))
+			require.NoError(t, l.Check(ctx, keys...))
+		})
+	}
+}
+
+func TestLimiterBurnAfter(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemory(),
+		WithPolicy(ScopeSecret, Policy{MaxFailures: 10, Window: time.Hour, Lockout: time.Minute}),
+		WithBurnAfter(2),
+	)
+	res, err := l.Fail(ctx, SecretKey("s1"))
+	require.NoError(t, err)
+	assert.False(t, res.Burn)
+	res, err = l.Fail(ctx, SecretKey("s1"))
+	require.NoError(t, err)
+	assert.True(t, res.Burn)
+
+	l.Reconfigure(WithPolicy(ScopeSecret, Policy{MaxFailures: 10, Window: time.Hour, Lockout: time.Minute}))
+	res, err = l.Fail(ctx, SecretKey("s1"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Failures[ScopeSecret], "failures are kept across reconfigure")
+	assert.False(t, res.Burn, "reconfigure without burn after disables it")
+}
+
+func TestLimiterFallback(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	l := New(NewValkey(client),
+		WithFallback(NewMemory(), time.Millisecond*100),
+		WithPolicy(ScopeUsername, Policy{MaxFailures: 1, Window: time.Hour, Lockout: time.Minute}),
+	)
+	mr.Close()
+
+	res, err := l.Fail(ctx, UsernameKey("admin"))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, res.RetryAfter)
+	assert.ErrorIs(t, l.Check(ctx, UsernameKey("admin")), ErrLocked)
+}
+
+func TestMemoryWindowExpires(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	p := Policy{MaxFailures: 2, Window: time.Millisecond * 20, Lockout: time.Millisecond * 20}
+
+	n, _, err := s.Fail(ctx, "k", p)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	time.Sleep(p.Window)
+	n, locked, err := s.Fail(ctx, "k", p)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Zero(t, locked)
+}
@@ -0,0 +1,84 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	memoryStore struct {
+		mu        sync.Mutex
+		entries   map[string]*memoryEntry
+		lastSweep time.Time
+	}
+	memoryEntry struct {
+		failures    int
+		resetAt     time.Time
+		lockedUntil time.Time
+	}
+)
+
+const sweepPeriod = time.Minute
+
+// NewMemory stores failures in process memory, counts are not shared by replicas
+func NewMemory() Store {
+	return &memoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *memoryStore) Fail(_ context.Context, key string, p Policy) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	e, ok := s.entries[key]
+	if !ok || !now.Before(e.resetAt) {
+		e = &memoryEntry{resetAt: now.Add(p.Window), lockedUntil: lockedUntil(e)}
+		s.entries[key] = e
+	}
+	e.failures++
+	if e.failures < p.MaxFailures {
+		return e.failures, 0, nil
+	}
+	e.lockedUntil = now.Add(p.Lockout)
+	return e.failures, p.Lockout, nil
+}
+
+func (s *memoryStore) Locked(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		return max(time.Until(e.lockedUntil), 0), nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops entries whose window and lockout passed, it has to be called with lock held
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepPeriod {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if !now.Before(e.resetAt) && !now.Before(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func lockedUntil(e *memoryEntry) time.Time {
+	if e == nil {
+		return time.Time{}
+	}
+	return e.lockedUntil
+}
@@ -0,0 +1,71 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+// failScript counts failure in window and sets lock key once max failures is reached
+var failScript = valkey.NewLuaScript(`local n = redis.call('INCR', KEYS[1])
+if n == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+if n >= tonumber(ARGV[2]) then
+  redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+  return {n, tonumber(ARGV[3])}
+end
+return {n, 0}`)
+
+type valkeyStore struct {
+	client valkey.Client
+}
+
+// NewValkey stores failures in Valkey so all replicas share them
+func NewValkey(client valkey.Client) Store {
+	return &valkeyStore{client: client}
+}
+
+func (s *valkeyStore) Fail(ctx context.Context, key string, p Policy) (int, time.Duration, error) {
+	res, err := failScript.Exec(ctx, s.client,
+		[]string{s.failuresKey(key), s.lockedKey(key)},
+		[]string{
+			strconv.FormatInt(p.Window.Milliseconds(), 10),
+			strconv.Itoa(p.MaxFailures),
+			strconv.FormatInt(max(p.Lockout.Milliseconds(), 1), 10),
+		}).AsIntSlice()
+	if err != nil {
+		return 0, 0, fmt.Errorf("lockout: error counting failure: %w", err)
+	}
+	if len(res) != 2 {
+		return 0, 0, fmt.Errorf("lockout: unexpected reply %v", res)
+	}
+	return int(res[0]), time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (s *valkeyStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := s.client.Do(ctx, s.client.B().Pttl().Key(s.lockedKey(key)).Build()).AsInt64()
+	if err != nil {
+		return 0, fmt.Errorf("lockout: error checking lock: %w", err)
+	}
+	if ms <= 0 {
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (s *valkeyStore) Reset(ctx context.Context, key string) error {
+	if err := s.client.Do(ctx, s.client.B().Del().Key(s.failuresKey(key), s.lockedKey(key)).Build()).Error(); err != nil {
+		return fmt.Errorf("lockout: error resetting failures: %w", err)
+	}
+	return nil
+}
+
+func (_ *valkeyStore) failuresKey(key string) string {
+	return "{" + key + "}_lockout_failures"
+}
+
+func (_ *valkeyStore) lockedKey(key string) string {
+	return "{" + key + "}_lockout_locked"
+}
@@ -0,0 +1,138 @@
+// Package metrics exposes Prometheus metrics of secrets lifecycle, HTTP requests, Storage operations
+// and Valkey connections. Metrics never carry secret IDs or other high cardinality labels.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+const namespace = "oss"
+
+// Metrics holds collectors in own registry, so every instance starts from zero
+type Metrics struct {
+	reg *prometheus.Registry
+
+	secrets            *prometheus.CounterVec
+	passphraseFailures prometheus.Counter
+	attachmentBytes    prometheus.Counter
+	httpDuration       *prometheus.HistogramVec
+	storageDuration    *prometheus.HistogramVec
+	valkeyDials        *prometheus.CounterVec
+	valkeyConns        prometheus.Gauge
+}
+
+// routeOther labels requests not served by tagged route, e.g. 404s, so unknown paths don't explode cardinality
+const routeOther = "other"
+
+var secretEvents = map[storage.RecordEventType]string{
+	storage.RecordEventStored:  "created",
+	storage.RecordEventViewed:  "revealed",
+	storage.RecordEventBurned:  "burned",
+	storage.RecordEventExpired: "expired",
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		reg: prometheus.NewRegistry(),
+		secrets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "secrets_total",
+			Help:      "Secrets by lifecycle event: created, revealed, burned and expired.",
+		}, []string{"event"}),
+		passphraseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "passphrase_failures_total",
+			Help:      "Wrong or locked out passphrase attempts.",
+		}),
+		attachmentBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "attachment_bytes_total",
+			Help:      "Bytes of attachments stored with secrets.",
+		}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Latency of Storage operations.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+		valkeyDials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "valkey",
+			Name:      "dials_total",
+			Help:      "Connections dialed by Valkey client by result.",
+		}, []string{"result"}),
+		valkeyConns: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "valkey",
+			Name:      "connections_open",
+			Help:      "Open connections of Valkey client pools.",
+		}),
+	}
+	for _, event := range secretEvents {
+		m.secrets.WithLabelValues(event)
+	}
+	m.reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.secrets, m.passphraseFailures, m.attachmentBytes, m.httpDuration, m.storageDuration,
+		m.valkeyDials, m.valkeyConns,
+	)
+	return m
+}
+
+// Handler serves metrics in Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{Registry: m.reg})
+}
+
+// Middleware observes latency of requests labelled by route pattern tagged with server.TagRoute
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, route := server.RecordRoute(r)
+		sw := server.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		pattern := route()
+		if pattern == "" {
+			pattern = routeOther
+		}
+		m.httpDuration.WithLabelValues(r.Method, pattern, strconv.Itoa(sw.Status())).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Observe implements storage.Observer, it counts lifecycle events of secrets
+func (m *Metrics) Observe(_ context.Context, e storage.RecordEvent) {
+	if event, ok := secretEvents[e.Type]; ok {
+		m.secrets.WithLabelValues(event).Inc()
+	}
+}
+
+// PassphraseFailed counts wrong or locked out passphrase attempt
+func (m *Metrics) PassphraseFailed() {
+	m.passphraseFailures.Inc()
+}
+
+// NotificationsDropped exposes count of notifications dropped by notifier, dropped is read on every scrape
+func (m *Metrics) NotificationsDropped(dropped func() uint64) {
+	m.reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_dropped_total",
+		Help:      "Notifications dropped because notifier queue was full.",
+	}, func() float64 { return float64(dropped()) }))
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestMiddlewareLabelsRoutes(t *testing.T) {
+	m := New()
+	mux := flow.New()
+	mux.Use(m.Middleware)
+	guard := server.NewGuard()
+	guard.Handle(mux, http.MethodGet, "/api/:value", server.Public, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	for _, path := range []string{"/api/a", "/api/b", "/unknown/path"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `oss_http_request_duration_seconds_count{code="410",method="GET",route="/api/:value"} 2`)
+	assert.Contains(t, out, `oss_http_request_duration_seconds_count{code="404",method="GET",route="other"} 1`)
+	assert.NotContains(t, out, "/api/a")
+}
+
+func TestStorageMetrics(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	m := New()
+	s := m.InstrumentStorage(storage.NewValkey(client, nil,
+		func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
+			return secrets.NewSecret(id, key)
+		}, m))
+
+	secret := secrets.NewSecret("s1", encryption.GenerateNewKey(32))
+	secret.SetValue("hunter2")
+	secret.AddFile("a.txt", make([]byte, 100))
+	insert, err := s.Store(ctx, secret)
+	require.NoError(t, err)
+	require.NoError(t, s.Viewed(ctx, insert.ID))
+	require.NoError(t, s.Burn(ctx, insert.ID))
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `oss_secrets_total{event="created"} 1`)
+	assert.Contains(t, out, `oss_secrets_total{event="revealed"} 1`)
+	assert.Contains(t, out, `oss_secrets_total{event="expired"} 0`)
+	assert.Contains(t, out, "oss_attachment_bytes_total 100")
+	assert.Contains(t, out, `oss_storage_operation_duration_seconds_count{operation="store"} 1`)
+	assert.Contains(t, out, `oss_storage_operation_duration_seconds_count{operation="viewed"} 1`)
+	assert.NotContains(t, out, "s1")
+
+	_, ok := s.(storage.ExpiryWatcher)
+	assert.True(t, ok, "instrumented storage must keep reporting expired records")
+}
+
+func TestDialValkey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	m := New()
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true, DialCtxFn: m.DialValkey})
+	require.NoError(t, err)
+	require.NoError(t, client.Do(context.Background(), client.B().Ping().Build()).Error())
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `oss_valkey_dials_total{result="ok"}`)
+	assert.Regexp(t, `oss_valkey_connections_open [1-9]`, out)
+
+	client.Close()
+	assert.Eventually(t, func() bool {
+		return strings.Contains(scrape(t, m), "oss_valkey_connections_open 0")
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestNotificationsDropped(t *testing.T) {
+	m := New()
+	var dropped uint64
+	m.NotificationsDropped(func() uint64 { return dropped })
+	dropped = 3
+	assert.Contains(t, scrape(t, m), "oss_notifications_dropped_total 3")
+}
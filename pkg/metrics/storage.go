@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+type instrumentedStorage struct {
+	storage.Storage[storage.ID, storage.Key]
+	m *Metrics
+}
+
+var (
+	_ storage.ExpiryWatcher = (*instrumentedStorage)(nil)
+
+	errNoExpiryWatcher = errors.New("metrics: storage does not report expired records")
+)
+
+// InstrumentStorage observes latency of every operation of s and counts bytes of stored attachments
+func (m *Metrics) InstrumentStorage(s storage.Storage[storage.ID, storage.Key]) storage.Storage[storage.ID, storage.Key] {
+	return &instrumentedStorage{Storage: s, m: m}
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time) {
+	s.m.storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStorage) Store(ctx context.Context, record storage.Record[storage.ID, storage.Key]) (*storage.InsertResult[storage.ID, storage.Key], error) {
+	var size int
+	for _, f := range record.Files() {
+		size += len(f.Content)
+	}
+	defer s.observe("store", time.Now())
+	res, err := s.Storage.Store(ctx, record)
+	if err == nil {
+		s.m.attachmentBytes.Add(float64(size))
+	}
+	return res, err
+}
+
+func (s *instrumentedStorage) Get(ctx context.Context, id storage.ID, k storage.Key) (storage.Record[storage.ID, storage.Key], error) {
+	defer s.observe("get", time.Now())
+	return s.Storage.Get(ctx, id, k)
+}
+
+func (s *instrumentedStorage) Burn(ctx context.Context, id storage.ID) error {
+	defer s.observe("burn", time.Now())
+	return s.Storage.Burn(ctx, id)
+}
+
+func (s *instrumentedStorage) ViewsLeft(ctx context.Context, id storage.ID) (uint64, error) {
+	defer s.observe("views_left", time.Now())
+	return s.Storage.ViewsLeft(ctx, id)
+}
+
+func (s *instrumentedStorage) Viewed(ctx context.Context, id storage.ID) error {
+	defer s.observe("viewed", time.Now())
+	return s.Storage.Viewed(ctx, id)
+}
+
+func (s *instrumentedStorage) Status(ctx context.Context, id storage.ID) (*storage.RecordStatus, error) {
+	defer s.observe("status", time.Now())
+	return s.Storage.Status(ctx, id)
+}
+
+func (s *instrumentedStorage) Extend(ctx context.Context, id storage.ID, d time.Duration) (time.Time, error) {
+	defer s.observe("extend", time.Now())
+	return s.Storage.Extend(ctx, id, d)
+}
+
+func (s *instrumentedStorage) VerifyManageToken(ctx context.Context, id storage.ID, token string) error {
+	defer s.observe("verify_manage_token", time.Now())
+	return s.Storage.VerifyManageToken(ctx, id, token)
+}
+
+// WatchExpired passes through to wrapped storage, so instrumenting it does not hide expiry events
+func (s *instrumentedStorage) WatchExpired(ctx context.Context, l *slog.Logger) error {
+	w, ok := s.Storage.(storage.ExpiryWatcher)
+	if !ok {
+		return errNoExpiryWatcher
+	}
+	return w.WatchExpired(ctx, l)
+}
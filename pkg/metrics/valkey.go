@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+)
+
+type countedConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+// DialValkey is valkey.ClientOption DialCtxFn dialing like the default one, it counts dials and open
+// connections of Valkey client pools
+func (m *Metrics) DialValkey(ctx context.Context, addr string, d *net.Dialer, cfg *tls.Config) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if cfg != nil {
+		conn, err = (&tls.Dialer{NetDialer: d, Config: cfg}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		m.valkeyDials.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	m.valkeyDials.WithLabelValues("ok").Inc()
+	m.valkeyConns.Inc()
+	return &countedConn{Conn: conn, closed: m.valkeyConns.Dec}, nil
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
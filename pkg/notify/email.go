@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type emailSender struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+var eventSubjects = map[EventType]string{
+	EventFirstView: "Your secret was opened",
+	EventBurned:    "Your secret was burned",
+	EventExpired:   "Your secret expired unread",
+}
+
+// subject of message about p, secrets expiring with some views used were not unread
+func subject(p Payload) string {
+	if p.Event == EventExpired && p.Views > 0 {
+		return fmt.Sprintf("Your secret expired after %d views", p.Views)
+	}
+	return eventSubjects[p.Event]
+}
+
+func (s *emailSender) send(to string, p Payload) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, _ := strings.Cut(s.addr, ":")
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject(p))
+	fmt.Fprintf(&msg, "Date: %s\r\n", p.OccurredAt.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Event: %s\r\n", p.Event)
+	fmt.Fprintf(&msg, "Secret: %s\r\n", p.SecretID)
+	fmt.Fprintf(&msg, "Views: %d\r\n", p.Views)
+	fmt.Fprintf(&msg, "Views left: %d\r\n", p.ViewsLeft)
+	fmt.Fprintf(&msg, "Occurred at: %s\r\n", p.OccurredAt.Format(time.RFC3339))
+
+	if err := smtp.SendMail(s.addr, auth, s.from, []string{to}, msg.Bytes()); err != nil {
+		return fmt.Errorf("notify: sending email failed: %w", err)
+	}
+	return nil
+}
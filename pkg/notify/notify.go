@@ -0,0 +1,354 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/valkey-io/valkey-go"
+)
+
+type (
+	EventType string
+
+	// Target is where creator of a secret wants to be notified, at least one of the fields is set
+	Target struct {
+		Webhook string `json:"webhook,omitempty"`
+		Email   string `json:"email,omitempty"`
+	}
+
+	// Payload is delivered to targets, it never contains secret content
+	Payload struct {
+		Event      EventType `json:"event"`
+		SecretID   string    `json:"secret_id"`
+		Views      uint64    `json:"views"`
+		ViewsLeft  uint64    `json:"views_left"`
+		OccurredAt time.Time `json:"occurred_at"`
+	}
+
+	OptsFn   func(*Notifier)
+	Notifier struct {
+		client     valkey.Client
+		l          *slog.Logger
+		webhook    *webhookSender
+		email      *emailSender
+		queues     []chan job
+		maxRetries int
+		backoff    time.Duration
+		maxBackoff time.Duration
+		retention  time.Duration
+		dropped    atomic.Uint64
+	}
+
+	// job is observed event waiting for its target to be looked up and notified, target and payload are
+	// set once looked up for delivery waiting for retry
+	job struct {
+		event      storage.RecordEvent
+		occurredAt time.Time
+		target     *Target
+		payload    Payload
+		attempt    int
+	}
+)
+
+const (
+	EventFirstView EventType = "secret.first_view"
+	EventBurned    EventType = "secret.burned"
+	EventExpired   EventType = "secret.expired"
+)
+
+var (
+	ErrInvalidTarget   = errors.New("notify: invalid target")
+	ErrEmailDisabled   = errors.New("notify: email notifications are not configured")
+	errPermanentFailed = errors.New("notify: permanent delivery failure")
+)
+
+func New(client valkey.Client, signingKey []byte, l *slog.Logger, opts ...OptsFn) *Notifier {
+	n := &Notifier{
+		client:     client,
+		l:          l,
+		webhook:    &webhookSender{client: newWebhookClient(), key: signingKey},
+		queues:     make([]chan job, 4),
+		maxRetries: 5,
+		backoff:    time.Second,
+		maxBackoff: time.Minute * 5,
+		retention:  time.Hour * 24,
+	}
+	for i := range n.queues {
+		n.queues[i] = make(chan job, 64)
+	}
+	for i := range opts {
+		opts[i](n)
+	}
+	return n
+}
+
+func WithSMTP(addr, from, username, password string) OptsFn {
+	return func(n *Notifier) {
+		if addr != "" && from != "" {
+			n.email = &emailSender{addr: addr, from: from, username: username, password: password}
+		}
+	}
+}
+
+// WithHTTPClient replaces client delivering webhooks, it also drops the refusal of non-public addresses
+func WithHTTPClient(c *http.Client) OptsFn {
+	return func(n *Notifier) {
+		n.webhook.client = c
+		n.webhook.anyAddress = true
+	}
+}
+
+func WithRetries(maxRetries int, backoff, maxBackoff time.Duration) OptsFn {
+	return func(n *Notifier) {
+		n.maxRetries = maxRetries
+		n.backoff = backoff
+		n.maxBackoff = maxBackoff
+	}
+}
+
+// ParseTarget accepts either webhook URL or an e-mail address as entered in UI
+func ParseTarget(value string) (Target, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return Target{Webhook: value}, nil
+	}
+	return Target{Email: value}, nil
+}
+
+// Validate checks target is deliverable by this notifier
+func (n *Notifier) Validate(t Target) error {
+	if t.Webhook == "" && t.Email == "" {
+		return ErrInvalidTarget
+	}
+	if t.Webhook != "" {
+		u, err := url.Parse(t.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("%w: webhook must be absolute http(s) URL", ErrInvalidTarget)
+		}
+		// literal addresses are refused early, names are checked once resolved when delivering
+		addr, err := netip.ParseAddr(u.Hostname())
+		if !n.webhook.anyAddress && ((err == nil && !isPublicAddr(addr)) || strings.EqualFold(u.Hostname(), "localhost")) {
+			return fmt.Errorf("%w: webhook must not point to internal address", ErrInvalidTarget)
+		}
+	}
+	if t.Email != "" {
+		if n.email == nil {
+			return ErrEmailDisabled
+		}
+		if _, err := mail.ParseAddress(t.Email); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTarget, err)
+		}
+	}
+	return nil
+}
+
+// Register stores target for secret, it outlives the secret so expiry can still be reported
+func (n *Notifier) Register(ctx context.Context, id storage.ID, t Target, expiresAt time.Time) error {
+	if err := n.Validate(t); err != nil {
+		return err
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("notify: error encoding target: %w", err)
+	}
+	cmd := n.client.B().Set().Key(n.generateKey(id)).Value(string(b)).Exat(expiresAt.Add(n.retention)).Build()
+	if err = n.client.Do(ctx, cmd).Error(); err != nil {
+		return fmt.Errorf("notify: error storing target: %w", err)
+	}
+	return nil
+}
+
+// Observe implements storage.Observer, events are only queued, so looking up targets and delivering never
+// block or fail storage operations
+func (n *Notifier) Observe(_ context.Context, e storage.RecordEvent) {
+	switch e.Type {
+	case storage.RecordEventViewed:
+		if e.Views != 1 {
+			return
+		}
+	case storage.RecordEventBurned, storage.RecordEventExpired, storage.RecordEventExtended:
+	default:
+		return
+	}
+
+	n.enqueue(job{event: e, occurredAt: time.Now().UTC()})
+}
+
+// Dropped returns how many notifications were dropped because queue of their worker was full
+func (n *Notifier) Dropped() uint64 {
+	return n.dropped.Load()
+}
+
+func (n *Notifier) enqueue(j job) {
+	select {
+	case n.queue(j.event.ID) <- j:
+	default:
+		dropped := n.dropped.Add(1)
+		n.l.Warn("notification queue is full, dropping notification",
+			slog.String("event", string(j.event.Type)), slog.Uint64("dropped", dropped))
+	}
+}
+
+// queue returns queue of worker handling events of record id. Events of a secret always go to the same
+// worker, so they are handled in order and final event can't remove target before first view is delivered.
+func (n *Notifier) queue(id storage.ID) chan job {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return n.queues[h.Sum32()%uint32(len(n.queues))]
+}
+
+// Run delivers queued notifications until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, queue := range n.queues {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-queue:
+					n.handle(ctx, j)
+				}
+			}
+		}()
+	}
+	for range n.queues {
+		<-done
+	}
+}
+
+// handle looks up target registered for record of event and delivers payload to it, jobs waiting for
+// retry are delivered right away
+func (n *Notifier) handle(ctx context.Context, j job) {
+	if j.target != nil {
+		n.deliver(ctx, j)
+		return
+	}
+
+	e := j.event
+	var event EventType
+	switch e.Type {
+	case storage.RecordEventViewed:
+		event = EventFirstView
+	case storage.RecordEventBurned:
+		event = EventBurned
+	case storage.RecordEventExpired:
+		event = EventExpired
+	case storage.RecordEventExtended:
+		cmd := n.client.B().Expireat().Key(n.generateKey(e.ID)).Timestamp(e.ExpiresAt.Add(n.retention).Unix()).Build()
+		if err := n.client.Do(ctx, cmd).Error(); err != nil {
+			n.l.Error("failed to extend notification target", slog.Any("err", err))
+		}
+		return
+	default:
+		return
+	}
+
+	t, ok := n.lookup(ctx, e.ID)
+	if !ok {
+		return
+	}
+	if event != EventFirstView {
+		_ = n.client.Do(ctx, n.client.B().Del().Key(n.generateKey(e.ID)).Build()).Error()
+	}
+	j.target = &t
+	j.payload = Payload{Event: event, SecretID: string(e.ID), Views: e.Views, ViewsLeft: e.ViewsLeft, OccurredAt: j.occurredAt}
+	n.deliver(ctx, j)
+}
+
+// deliver sends payload of job once, channels failing temporarily are queued again after exponential
+// backoff, so unreachable targets don't hold workers
+func (n *Notifier) deliver(ctx context.Context, j job) {
+	retry, err := n.send(ctx, *j.target, j.payload)
+	if err == nil {
+		return
+	}
+	if (retry == Target{}) || j.attempt >= n.maxRetries {
+		if j.attempt > 0 {
+			err = fmt.Errorf("notify: giving up after %d retries: %w", j.attempt, err)
+		}
+		n.l.Error("failed to deliver notification", slog.Any("err", err), slog.String("event", string(j.payload.Event)))
+		return
+	}
+
+	delay := min(n.backoff<<j.attempt, n.maxBackoff)
+	j.target, j.attempt = &retry, j.attempt+1
+	n.l.Warn("failed to deliver notification, retrying", slog.Any("err", err),
+		slog.String("event", string(j.payload.Event)), slog.Duration("in", delay))
+	time.AfterFunc(delay, func() {
+		if ctx.Err() == nil {
+			n.enqueue(j)
+		}
+	})
+}
+
+// Deliver sends payload to every channel of target once, workers retry failed deliveries later
+func (n *Notifier) Deliver(ctx context.Context, t Target, p Payload) error {
+	_, err := n.send(ctx, t, p)
+	return err
+}
+
+// send delivers payload to every channel of target, returned target holds channels worth retrying
+func (n *Notifier) send(ctx context.Context, t Target, p Payload) (Target, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return Target{}, fmt.Errorf("notify: error encoding payload: %w", err)
+	}
+
+	var (
+		retry Target
+		errs  []error
+	)
+	if t.Webhook != "" {
+		if err = n.webhook.send(ctx, t.Webhook, body); err != nil {
+			errs = append(errs, err)
+			if !errors.Is(err, errPermanentFailed) {
+				retry.Webhook = t.Webhook
+			}
+		}
+	}
+	if t.Email != "" {
+		if n.email == nil {
+			errs = append(errs, ErrEmailDisabled)
+		} else if err = n.email.send(t.Email, p); err != nil {
+			errs = append(errs, err)
+			if !errors.Is(err, errPermanentFailed) {
+				retry.Email = t.Email
+			}
+		}
+	}
+	return retry, errors.Join(errs...)
+}
+
+func (n *Notifier) lookup(ctx context.Context, id storage.ID) (Target, bool) {
+	b, err := n.client.Do(ctx, n.client.B().Get().Key(n.generateKey(id)).Build()).AsBytes()
+	if err != nil {
+		if !valkey.IsValkeyNil(err) {
+			n.l.Error("failed to load notification target", slog.Any("err", err))
+		}
+		return Target{}, false
+	}
+	var t Target
+	if err = json.Unmarshal(b, &t); err != nil {
+		n.l.Error("failed to decode notification target", slog.Any("err", err))
+		return Target{}, false
+	}
+	return t, true
+}
+
+func (_ *Notifier) generateKey(id storage.ID) string {
+	return string(id + "_notify")
+}
@@ -0,0 +1,369 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func TestDeliverWebhookSigned(t *testing.T) {
+	key := []byte("signing-key")
+	var received Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, Verify(key, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+		assert.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := New(nil, key, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()))
+	p := Payload{Event: EventFirstView, SecretID: "sid", Views: 1, OccurredAt: time.Now().UTC()}
+	require.NoError(t, n.Deliver(context.Background(), Target{Webhook: srv.URL}, p))
+	assert.Equal(t, EventFirstView, received.Event)
+	assert.Equal(t, "sid", received.SecretID)
+}
+
+func TestDeliverWebhookRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n := New(nil, nil, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()), WithRetries(5, time.Millisecond, time.Millisecond*10))
+	assert.Error(t, n.Deliver(context.Background(), Target{Webhook: srv.URL}, Payload{Event: EventBurned}))
+	assert.Equal(t, int32(1), calls.Load(), "Deliver tries once")
+
+	go n.Run(t.Context())
+	n.enqueue(job{event: storage.RecordEvent{ID: "sid"}, target: &Target{Webhook: srv.URL}, payload: Payload{Event: EventBurned}})
+	assert.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, time.Millisecond*5, "workers retry")
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int32(3), calls.Load(), "delivered notification is not retried")
+}
+
+func TestRetryDoesNotHoldWorker(t *testing.T) {
+	delivered := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var p Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		delivered <- p.SecretID
+	}))
+	defer srv.Close()
+
+	n := New(nil, nil, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()), WithRetries(5, time.Hour, time.Hour))
+	go n.Run(t.Context())
+
+	// both jobs land on the same worker, the second one must not wait for backoff of the first
+	n.enqueue(job{event: storage.RecordEvent{ID: "sid"}, target: &Target{Webhook: srv.URL + "/down"}, payload: Payload{SecretID: "down"}})
+	n.enqueue(job{event: storage.RecordEvent{ID: "sid"}, target: &Target{Webhook: srv.URL + "/up"}, payload: Payload{SecretID: "up"}})
+	select {
+	case id := <-delivered:
+		assert.Equal(t, "up", id)
+	case <-time.After(time.Second):
+		t.Fatal("worker is held by retry")
+	}
+}
+
+func TestObserveCountsDropped(t *testing.T) {
+	n := New(nil, nil, slog.New(slog.DiscardHandler))
+	ctx := context.Background()
+	for range cap(n.queue("sid")) + 2 {
+		n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventBurned, ID: "sid"})
+	}
+	assert.EqualValues(t, 2, n.Dropped())
+}
+
+func TestDeliverWebhookPermanentFailure(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	n := New(nil, nil, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()), WithRetries(5, time.Millisecond, time.Millisecond*10))
+	assert.ErrorIs(t, n.Deliver(context.Background(), Target{Webhook: srv.URL}, Payload{Event: EventBurned}), errPermanentFailed)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDeliverWebhookRefusesInternalAddresses(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	n := New(nil, nil, slog.New(slog.DiscardHandler), WithRetries(5, time.Millisecond, time.Millisecond*10))
+	for _, hook := range []string{srv.URL, "http://localhost:" + port} {
+		err := n.Deliver(context.Background(), Target{Webhook: hook}, Payload{Event: EventBurned})
+		assert.ErrorIs(t, err, errBlockedAddress, hook)
+		assert.ErrorIs(t, err, errPermanentFailed, "%s is not retried", hook)
+	}
+	assert.Zero(t, calls.Load())
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:4700::6810:84e5": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"100.64.0.1":           false,
+		"::ffff:127.0.0.1":     false,
+	} {
+		assert.Equal(t, public, isPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	addr, messages := runSMTPStandIn(t)
+
+	n := New(nil, nil, slog.New(slog.DiscardHandler), WithSMTP(addr, "oss@localhost", "", ""))
+	p := Payload{Event: EventExpired, SecretID: "sid", OccurredAt: time.Now().UTC()}
+	require.NoError(t, n.Deliver(context.Background(), Target{Email: "ops@localhost"}, p))
+
+	select {
+	case msg := <-messages:
+		assert.Contains(t, msg, "To: ops@localhost")
+		assert.Contains(t, msg, "Subject: Your secret expired unread")
+		assert.Contains(t, msg, "Secret: sid")
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+
+	addr, messages = runSMTPStandIn(t)
+	n = New(nil, nil, slog.New(slog.DiscardHandler), WithSMTP(addr, "oss@localhost", "", ""))
+	p.Views = 2
+	require.NoError(t, n.Deliver(context.Background(), Target{Email: "ops@localhost"}, p))
+	select {
+	case msg := <-messages:
+		assert.Contains(t, msg, "Subject: Your secret expired after 2 views")
+		assert.NotContains(t, msg, "unread")
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	n := New(nil, nil, slog.New(slog.DiscardHandler))
+	assert.NoError(t, n.Validate(Target{Webhook: "https://example.com/hook"}))
+	assert.ErrorIs(t, n.Validate(Target{}), ErrInvalidTarget)
+	assert.ErrorIs(t, n.Validate(Target{Webhook: "ftp://example.com"}), ErrInvalidTarget)
+	for _, hook := range []string{"http://127.0.0.1:6379", "http://169.254.169.254/latest", "https://10.0.0.1", "http://[::1]/", "http://localhost/"} {
+		assert.ErrorIs(t, n.Validate(Target{Webhook: hook}), ErrInvalidTarget, hook)
+	}
+	assert.ErrorIs(t, n.Validate(Target{Email: "ops@example.com"}), ErrEmailDisabled)
+
+	n = New(nil, nil, slog.New(slog.DiscardHandler), WithSMTP("localhost:25", "oss@localhost", "", ""))
+	assert.NoError(t, n.Validate(Target{Email: "ops@example.com"}))
+	assert.ErrorIs(t, n.Validate(Target{Email: "not an email"}), ErrInvalidTarget)
+}
+
+func TestRegisterAndObserve(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	received := make(chan Payload, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		received <- p
+	}))
+	defer srv.Close()
+	next := func() Payload {
+		t.Helper()
+		select {
+		case p := <-received:
+			return p
+		case <-time.After(time.Second):
+			t.Fatal("no notification delivered")
+			return Payload{}
+		}
+	}
+
+	ctx := context.Background()
+	n := New(client, nil, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()))
+	expiresAt := time.Now().Add(time.Hour)
+	require.ErrorIs(t, n.Register(ctx, "sid", Target{}, expiresAt), ErrInvalidTarget)
+	require.NoError(t, n.Register(ctx, "sid", Target{Webhook: srv.URL}, expiresAt))
+	assert.InDelta(t, time.Hour+n.retention, mr.TTL("sid_notify"), float64(time.Second))
+
+	// Observe only queues, Valkey is not touched until workers run
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventViewed, ID: "sid", Views: 1, ViewsLeft: 1})
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventViewed, ID: "sid", Views: 2})
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventStored, ID: "sid"})
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventExtended, ID: "sid", ExpiresAt: expiresAt.Add(time.Hour)})
+	assert.Len(t, n.queue("sid"), 2, "only first view and extension are queued")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go n.Run(runCtx)
+
+	p := next()
+	assert.Equal(t, EventFirstView, p.Event)
+	assert.Equal(t, "sid", p.SecretID)
+	assert.EqualValues(t, 1, p.ViewsLeft)
+	assert.Eventually(t, func() bool {
+		return mr.TTL("sid_notify") > 2*time.Hour+n.retention-time.Second
+	}, time.Second, 10*time.Millisecond, "extension moves retention of target")
+
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventBurned, ID: "sid"})
+	assert.Equal(t, EventBurned, next().Event)
+	assert.Eventually(t, func() bool { return !mr.Exists("sid_notify") }, time.Second, 10*time.Millisecond,
+		"final event removes target")
+
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventExpired, ID: "sid"})
+	n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventBurned, ID: "unregistered"})
+	select {
+	case p := <-received:
+		t.Fatalf("unexpected notification %+v", p)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestObserveViewedAndBurnedTogether(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	var mu sync.Mutex
+	received := make(map[string][]EventType)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p Payload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		mu.Lock()
+		received[p.SecretID] = append(received[p.SecretID], p.Event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ctx := t.Context()
+	n := New(client, nil, slog.New(slog.DiscardHandler), WithHTTPClient(srv.Client()))
+	go n.Run(ctx)
+
+	// single view secret reports view and burn back to back, both have to find the target
+	const secrets = 20
+	for i := range secrets {
+		id := storage.ID(fmt.Sprintf("sid%d", i))
+		require.NoError(t, n.Register(ctx, id, Target{Webhook: srv.URL}, time.Now().Add(time.Hour)))
+		n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventViewed, ID: id, Views: 1})
+		n.Observe(ctx, storage.RecordEvent{Type: storage.RecordEventBurned, ID: id, Views: 1})
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		total := 0
+		for _, events := range received {
+			total += len(events)
+		}
+		return total == 2*secrets
+	}, time.Second*5, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for id, events := range received {
+		assert.Equal(t, []EventType{EventFirstView, EventBurned}, events, id)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget(" https://example.com/hook ")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Webhook: "https://example.com/hook"}, target)
+
+	target, err = ParseTarget("ops@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Email: "ops@example.com"}, target)
+}
+
+// runSMTPStandIn speaks just enough SMTP for net/smtp.SendMail and hands over received messages
+func runSMTPStandIn(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	messages := make(chan string, 1)
+	var wg sync.WaitGroup
+	t.Cleanup(wg.Wait)
+	wg.Go(func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(line string) {
+			_, _ = rw.WriteString(line + "\r\n")
+			_ = rw.Flush()
+		}
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 end with .")
+				var msg strings.Builder
+				for {
+					l, err := rw.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				messages <- msg.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	})
+	return ln.Addr().String(), messages
+}
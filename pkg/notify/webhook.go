@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-OSS-Signature"
+	HeaderTimestamp = "X-OSS-Timestamp"
+)
+
+type webhookSender struct {
+	client *http.Client
+	key    []byte
+	// anyAddress is set when client was replaced and does not refuse non-public addresses
+	anyAddress bool
+}
+
+var (
+	errBlockedAddress = errors.New("notify: webhook address is not public")
+
+	// blockedPrefixes are ranges not covered by netip.Addr helpers which must not be reached by webhooks
+	blockedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("64:ff9b::/96"),
+	}
+)
+
+// newWebhookClient returns client which refuses to connect to loopback, private, link-local and other
+// non-public addresses. Webhook URLs come from anonymous creators, so the check runs on the address
+// actually dialed, names resolving or rebinding to internal addresses are refused too.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: time.Second * 5,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublicAddr(ap.Addr()) {
+				return fmt.Errorf("%w: %s", errBlockedAddress, address)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: time.Second * 10,
+		Transport: &http.Transport{
+			// proxies usually live on internal addresses, webhooks are dialed directly
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: time.Second * 5,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     time.Second * 90,
+		},
+		// every redirect is dialed through the same check, just limit the chain
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *webhookSender) send(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPermanentFailed, err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(s.key, ts, body))
+
+	res, err := s.client.Do(req)
+	if errors.Is(err, errBlockedAddress) {
+		return fmt.Errorf("%w: %w", errPermanentFailed, err)
+	}
+	if err != nil {
+		return fmt.Errorf("notify: webhook request failed: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: webhook responded with %d", errPermanentFailed, res.StatusCode)
+	default:
+		return fmt.Errorf("notify: webhook responded with %d", res.StatusCode)
+	}
+}
+
+// Sign computes signature sent in X-OSS-Signature header, receivers should recompute it
+// over the raw request body and the X-OSS-Timestamp header
+func Sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature produced by Sign in constant time
+func Verify(key []byte, timestamp string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(key, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,250 @@
+// Package openapi builds OpenAPI 3.1 documents with JSON schemas derived from Go types.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Version = "3.1.0"
+
+type (
+	Document struct {
+		OpenAPI    string               `json:"openapi"`
+		Info       Info                 `json:"info"`
+		Servers    []Server             `json:"servers,omitempty"`
+		Paths      map[string]*PathItem `json:"paths"`
+		Components Components           `json:"components"`
+	}
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+	Server struct {
+		URL string `json:"url"`
+	}
+	Components struct {
+		Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+	SecurityScheme struct {
+		Type   string `json:"type"`
+		Scheme string `json:"scheme,omitempty"`
+	}
+	PathItem  map[string]*Operation
+	Operation struct {
+		Summary     string                `json:"summary,omitempty"`
+		OperationID string                `json:"operationId,omitempty"`
+		Parameters  []Parameter           `json:"parameters,omitempty"`
+		RequestBody *RequestBody          `json:"requestBody,omitempty"`
+		Responses   map[string]*Response  `json:"responses"`
+		Security    []map[string][]string `json:"security,omitempty"`
+	}
+	Parameter struct {
+		Name     string  `json:"name"`
+		In       string  `json:"in"`
+		Required bool    `json:"required"`
+		Schema   *Schema `json:"schema"`
+	}
+	RequestBody struct {
+		Required bool                  `json:"required"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+	Response struct {
+		Description string                `json:"description"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 any                `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		ContentEncoding      string             `json:"contentEncoding,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	}
+)
+
+// Op describes single route, Request and Responses values are Go types rendered as schemas
+// (nil for empty body, string for text/plain)
+type Op struct {
+	Summary   string
+	Request   any
+	Responses map[int]any
+	Security  []string
+}
+
+type Builder struct {
+	doc *Document
+}
+
+func New(title, version string, servers ...string) *Builder {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	for _, s := range servers {
+		doc.Servers = append(doc.Servers, Server{URL: s})
+	}
+	return &Builder{doc: doc}
+}
+
+// SecurityScheme registers HTTP authentication scheme, e.g. ("basicAuth", "basic")
+func (b *Builder) SecurityScheme(name, scheme string) *Builder {
+	if b.doc.Components.SecuritySchemes == nil {
+		b.doc.Components.SecuritySchemes = make(map[string]*SecurityScheme)
+	}
+	b.doc.Components.SecuritySchemes[name] = &SecurityScheme{Type: "http", Scheme: scheme}
+	return b
+}
+
+// Add documents route, path uses flow syntax where :name segments become path parameters
+func (b *Builder) Add(method, path string, op Op) *Builder {
+	path, params := convertPath(path)
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	o := &Operation{
+		Summary:     op.Summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]*Response, len(op.Responses)),
+	}
+	for _, p := range params {
+		o.Parameters = append(o.Parameters, Parameter{Name: p, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	if op.Request != nil {
+		o.RequestBody = &RequestBody{Required: true, Content: b.content(op.Request)}
+	}
+	for status, v := range op.Responses {
+		r := &Response{Description: http.StatusText(status)}
+		if v != nil {
+			r.Content = b.content(v)
+		}
+		o.Responses[strconv.Itoa(status)] = r
+	}
+	for _, s := range op.Security {
+		o.Security = append(o.Security, map[string][]string{s: {}})
+	}
+	(*item)[strings.ToLower(method)] = o
+	return b
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+func (b *Builder) content(v any) map[string]*MediaType {
+	if _, ok := v.(string); ok {
+		return map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	}
+	return map[string]*MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(v))}}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schema renders t, named structs are stored in components and referenced
+func (b *Builder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", ContentEncoding: "base64"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "uint64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.doc.Components.Schemas[t.Name()]; !ok {
+			// placeholder guards recursive types
+			b.doc.Components.Schemas[t.Name()] = &Schema{}
+			*b.doc.Components.Schemas[t.Name()] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for f := range t.Fields() {
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := b.schema(f.Type)
+		optional := strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero")
+		if typ, ok := fs.Type.(string); ok && f.Type.Kind() == reflect.Pointer {
+			fs.Type = []string{typ, "null"}
+		}
+		if !optional && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+	return s
+}
+
+func convertPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			segments[i] = "{" + name + "}"
+			params = append(params, name)
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for seg := range strings.SplitSeq(path, "/") {
+		seg = strings.Trim(seg, "{}")
+		seg = strings.NewReplacer(".", "_", "-", "_").Replace(seg)
+		if seg == "" || seg == "api" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return sb.String()
+}
@@ -0,0 +1,47 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+type (
+	ID    string
+	State string
+
+	// Request asks someone to send a secret, the resulting secret key is sealed with age to Recipient
+	// whose identity only the requester holds
+	Request struct {
+		ID         ID
+		Recipient  string
+		Note       string
+		Expiration time.Duration
+		MaxViews   uint64
+		CreatedAt  time.Time
+		ExpiresAt  time.Time
+		State      State
+		SecretID   storage.ID
+		SealedKey  []byte
+	}
+
+	Store interface {
+		Create(context.Context, *Request) error
+		Get(context.Context, ID) (*Request, error)
+		// Fulfill attaches uploaded secret, it succeeds only once per request and keeps
+		// the request around for as long as the secret lives
+		Fulfill(ctx context.Context, id ID, secretID storage.ID, sealedKey []byte, expiresAt time.Time) error
+	}
+)
+
+const (
+	StateOpen      State = "open"
+	StateFulfilled State = "fulfilled"
+)
+
+var (
+	ErrRequestNotFound  = errors.New("request not found")
+	ErrAlreadyFulfilled = errors.New("request already fulfilled")
+)
@@ -0,0 +1,102 @@
+package requests
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	fieldRecipient  = "recipient"
+	fieldNote       = "note"
+	fieldExpiration = "expiration"
+	fieldMaxViews   = "max_views"
+	fieldCreatedAt  = "created_at"
+	fieldExpiresAt  = "expires_at"
+	fieldState      = "state"
+	fieldSecretID   = "secret_id"
+	fieldSealedKey  = "sealed_key"
+)
+
+var fulfillScript = valkey.NewLuaScript(`if redis.call('HGET', KEYS[1], 'state') ~= 'open' then return 0 end
+redis.call('HSET', KEYS[1], 'state', 'fulfilled', 'secret_id', ARGV[1], 'sealed_key', ARGV[2])
+redis.call('EXPIREAT', KEYS[1], ARGV[3])
+return 1`)
+
+type valkeyStore struct {
+	client valkey.Client
+}
+
+func NewValkey(client valkey.Client) Store {
+	return &valkeyStore{client: client}
+}
+
+func (s *valkeyStore) Create(ctx context.Context, r *Request) error {
+	key := s.generateKey(r.ID)
+	cmds := valkey.Commands{
+		s.client.B().Hset().Key(key).FieldValue().
+			FieldValue(fieldRecipient, r.Recipient).
+			FieldValue(fieldNote, r.Note).
+			FieldValue(fieldExpiration, strconv.FormatInt(int64(r.Expiration.Seconds()), 10)).
+			FieldValue(fieldMaxViews, strconv.FormatUint(r.MaxViews, 10)).
+			FieldValue(fieldCreatedAt, strconv.FormatInt(r.CreatedAt.Unix(), 10)).
+			FieldValue(fieldExpiresAt, strconv.FormatInt(r.ExpiresAt.Unix(), 10)).
+			FieldValue(fieldState, string(StateOpen)).
+			Build(),
+		s.client.B().Expireat().Key(key).Timestamp(r.ExpiresAt.Unix()).Build(),
+	}
+	for _, res := range s.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return fmt.Errorf("requests: error storing request: %w", err)
+		}
+	}
+	r.State = StateOpen
+	return nil
+}
+
+func (s *valkeyStore) Get(ctx context.Context, id ID) (*Request, error) {
+	m, err := s.client.Do(ctx, s.client.B().Hgetall().Key(s.generateKey(id)).Build()).AsStrMap()
+	if err != nil {
+		return nil, fmt.Errorf("requests: error getting request: %w", err)
+	}
+	if len(m) == 0 {
+		return nil, ErrRequestNotFound
+	}
+
+	r := &Request{ID: id, Recipient: m[fieldRecipient], Note: m[fieldNote], State: State(m[fieldState]), SecretID: storage.ID(m[fieldSecretID])}
+	if r.SealedKey, err = hex.DecodeString(m[fieldSealedKey]); err != nil {
+		return nil, fmt.Errorf("requests: error decoding sealed key: %w", err)
+	}
+	if v, err := strconv.ParseInt(m[fieldExpiration], 10, 64); err == nil {
+		r.Expiration = time.Duration(v) * time.Second
+	}
+	r.MaxViews, _ = strconv.ParseUint(m[fieldMaxViews], 10, 64)
+	if v, err := strconv.ParseInt(m[fieldCreatedAt], 10, 64); err == nil {
+		r.CreatedAt = time.Unix(v, 0).UTC()
+	}
+	if v, err := strconv.ParseInt(m[fieldExpiresAt], 10, 64); err == nil {
+		r.ExpiresAt = time.Unix(v, 0).UTC()
+	}
+	return r, nil
+}
+
+func (s *valkeyStore) Fulfill(ctx context.Context, id ID, secretID storage.ID, sealedKey []byte, expiresAt time.Time) error {
+	args := []string{string(secretID), hex.EncodeToString(sealedKey), strconv.FormatInt(expiresAt.Unix(), 10)}
+	ok, err := fulfillScript.Exec(ctx, s.client, []string{s.generateKey(id)}, args).AsInt64()
+	if err != nil {
+		return fmt.Errorf("requests: error fulfilling request: %w", err)
+	}
+	if ok == 0 {
+		return ErrAlreadyFulfilled
+	}
+	return nil
+}
+
+func (_ *valkeyStore) generateKey(id ID) string {
+	return string(id + "_request")
+}
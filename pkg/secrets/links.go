@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+)
+
+// ManageUrl builds private management link handed out to the creator of a secret
+func ManageUrl(domain string, id storage.ID, token string) string {
+	return fmt.Sprintf("%s/manage/%s-%s", domain, id, token)
+}
+
+// ParseManageValue splits path value of management link into secret ID and manage token
+func ParseManageValue(value string) (storage.ID, string, bool) {
+	sid, token, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok || sid == "" || token == "" {
+		return "", "", false
+	}
+	return storage.ID(sid), token, true
+}
+
+// ParseRevealValue splits path value of reveal link into hex encoded key and secret ID
+func ParseRevealValue(value string) (string, storage.ID, bool) {
+	key, sid, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok || key == "" || sid == "" {
+		return "", "", false
+	}
+	return key, storage.ID(sid), true
+}
+
+// RevealUrl builds link revealing secret, the key never reaches storage
+func RevealUrl(domain string, key storage.Key, id storage.ID) string {
+	return fmt.Sprintf("%s/%x-%s", domain, key, id)
+}
+
+// RequestUploadUrl builds link handed to whoever should fill in requested secret
+func RequestUploadUrl(domain, requestID string) string {
+	return fmt.Sprintf("%s/requests/upload/%s", domain, requestID)
+}
+
+// RequestRevealUrl builds link kept by requester, it carries age identity opening the uploaded secret
+func RequestRevealUrl(domain, requestID, identity string) string {
+	return fmt.Sprintf("%s/requests/reveal/%s-%s", domain, requestID, identity)
+}
+
+// ParseRequestRevealValue splits path value of request reveal link into request ID and age identity
+func ParseRequestRevealValue(value string) (string, string, bool) {
+	rid, identity, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok || rid == "" || identity == "" {
+		return "", "", false
+	}
+	return rid, identity, true
+}
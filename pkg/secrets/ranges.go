@@ -1,5 +1,6 @@
 package secrets
 
+// ExpirationRanges are expirations offered in seconds, the longest one is storage.MaxLifetime
 var ExpirationRanges = map[int]string{
 	30:     "30 seconds",
 	60:     "1 minute",
@@ -3,6 +3,7 @@ package secrets
 import (
 	"time"
 
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
 )
 
@@ -83,6 +84,31 @@ func (s *Secret) Seal() {
 	s.expiresAt = time.Now().Add(s.expiration).UTC()
 }
 
+// SealTo encrypts value and attachments to recipient public keys, so only their private keys can read them
+func (s *Secret) SealTo(recipients *encryption.Recipients) error {
+	if recipients == nil || recipients.IsEmpty() {
+		return nil
+	}
+
+	value, err := recipients.Encrypt([]byte(s.value))
+	if err != nil {
+		return err
+	}
+	s.value = string(value)
+
+	ext := ".age"
+	if recipients.Format() == encryption.FormatOpenPGP {
+		ext = ".asc"
+	}
+	for _, f := range s.files {
+		if f.Content, err = recipients.Encrypt(f.Content); err != nil {
+			return err
+		}
+		f.Name += ext
+	}
+	return nil
+}
+
 func (s *Secret) Reinit(value string, passphrase *string, expiresAt time.Time, files []*storage.FileRecord) {
 	s.value = value
 	s.passphrase = passphrase
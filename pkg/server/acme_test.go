@@ -0,0 +1,327 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeStandIn is Pebble-like ACME CA for tests, it validates tls-alpn-01 challenges against vaAddr
+// and issues certificates signed by its own root
+type acmeStandIn struct {
+	t      *testing.T
+	srv    *httptest.Server
+	vaAddr string
+
+	caKey  *ecdsa.PrivateKey
+	caCert *x509.Certificate
+
+	mu         sync.Mutex
+	thumbprint string
+	domain     string
+	token      string
+	status     string
+	cert       []byte
+}
+
+type jws struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+}
+
+func newACMEStandIn(t *testing.T) *acmeStandIn {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stand-in root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &acmeStandIn{t: t, caKey: caKey, caCert: caCert, status: acme.StatusPending, token: "token-1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dir", ca.directory)
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) { ca.nonce(w) })
+	mux.HandleFunc("POST /account", ca.account)
+	mux.HandleFunc("POST /order", ca.newOrder)
+	mux.HandleFunc("POST /order/1", ca.order)
+	mux.HandleFunc("POST /authz/1", ca.authz)
+	mux.HandleFunc("POST /chal/1", ca.challenge)
+	mux.HandleFunc("POST /finalize/1", ca.finalize)
+	mux.HandleFunc("POST /cert/1", ca.certificate)
+	ca.srv = httptest.NewServer(mux)
+	t.Cleanup(ca.srv.Close)
+	return ca
+}
+
+func (ca *acmeStandIn) url(path string) string { return ca.srv.URL + path }
+
+func (ca *acmeStandIn) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.caCert)
+	return pool
+}
+
+func (ca *acmeStandIn) nonce(w http.ResponseWriter) {
+	w.Header().Set("Replay-Nonce", rand.Text())
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func (ca *acmeStandIn) respond(w http.ResponseWriter, code int, location string, v any) {
+	ca.nonce(w)
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// decode returns payload and protected header of JWS request, signatures are not verified
+func (ca *acmeStandIn) decode(r *http.Request, payload any) map[string]json.RawMessage {
+	var req jws
+	require.NoError(ca.t, json.NewDecoder(r.Body).Decode(&req))
+	protected, err := base64.RawURLEncoding.DecodeString(req.Protected)
+	require.NoError(ca.t, err)
+	var header map[string]json.RawMessage
+	require.NoError(ca.t, json.Unmarshal(protected, &header))
+	if payload != nil && req.Payload != "" {
+		b, err := base64.RawURLEncoding.DecodeString(req.Payload)
+		require.NoError(ca.t, err)
+		require.NoError(ca.t, json.Unmarshal(b, payload))
+	}
+	return header
+}
+
+func (ca *acmeStandIn) directory(w http.ResponseWriter, _ *http.Request) {
+	ca.respond(w, http.StatusOK, "", map[string]string{
+		"newNonce":   ca.url("/nonce"),
+		"newAccount": ca.url("/account"),
+		"newOrder":   ca.url("/order"),
+		"revokeCert": ca.url("/revoke"),
+		"keyChange":  ca.url("/key-change"),
+	})
+}
+
+func (ca *acmeStandIn) account(w http.ResponseWriter, r *http.Request) {
+	header := ca.decode(r, nil)
+	var jwk map[string]string
+	require.NoError(ca.t, json.Unmarshal(header["jwk"], &jwk))
+	require.Equal(ca.t, "EC", jwk["kty"], "stand-in supports EC account keys only")
+	sum := sha256.Sum256(fmt.Appendf(nil, `{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk["crv"], jwk["x"], jwk["y"]))
+
+	ca.mu.Lock()
+	ca.thumbprint = base64.RawURLEncoding.EncodeToString(sum[:])
+	ca.mu.Unlock()
+	ca.respond(w, http.StatusCreated, ca.url("/account/1"), map[string]string{"status": acme.StatusValid})
+}
+
+func (ca *acmeStandIn) orderBody() map[string]any {
+	status := acme.StatusPending
+	switch {
+	case ca.cert != nil:
+		status = acme.StatusValid
+	case ca.status == acme.StatusValid:
+		status = acme.StatusReady
+	case ca.status == acme.StatusInvalid:
+		status = acme.StatusInvalid
+	}
+	body := map[string]any{
+		"status":         status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": ca.domain}},
+		"authorizations": []string{ca.url("/authz/1")},
+		"finalize":       ca.url("/finalize/1"),
+	}
+	if ca.cert != nil {
+		body["certificate"] = ca.url("/cert/1")
+	}
+	return body
+}
+
+func (ca *acmeStandIn) newOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Identifiers []struct{ Value string } `json:"identifiers"`
+	}
+	ca.decode(r, &req)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.domain = req.Identifiers[0].Value
+	ca.respond(w, http.StatusCreated, ca.url("/order/1"), ca.orderBody())
+}
+
+func (ca *acmeStandIn) order(w http.ResponseWriter, r *http.Request) {
+	ca.decode(r, nil)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.respond(w, http.StatusOK, ca.url("/order/1"), ca.orderBody())
+}
+
+func (ca *acmeStandIn) challengeBody() map[string]string {
+	return map[string]string{"type": "tls-alpn-01", "url": ca.url("/chal/1"), "token": ca.token, "status": ca.status}
+}
+
+func (ca *acmeStandIn) authz(w http.ResponseWriter, r *http.Request) {
+	ca.decode(r, nil)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.respond(w, http.StatusOK, "", map[string]any{
+		"status":     ca.status,
+		"identifier": map[string]string{"type": "dns", "value": ca.domain},
+		"challenges": []map[string]string{ca.challengeBody()},
+	})
+}
+
+// challenge validates tls-alpn-01 the way CA does: certificate served for acme-tls/1 protocol has to carry
+// digest of key authorization
+func (ca *acmeStandIn) challenge(w http.ResponseWriter, r *http.Request) {
+	ca.decode(r, nil)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.status = acme.StatusInvalid
+	conn, err := tls.Dial("tcp", ca.vaAddr, &tls.Config{
+		ServerName:         ca.domain,
+		NextProtos:         []string{acme.ALPNProto},
+		InsecureSkipVerify: true,
+	})
+	if err == nil {
+		defer conn.Close()
+		keyAuth := sha256.Sum256([]byte(ca.token + "." + ca.thumbprint))
+		leaf := conn.ConnectionState().PeerCertificates[0]
+		for _, ext := range leaf.Extensions {
+			var digest []byte
+			if ext.Id.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}) {
+				if _, err := asn1.Unmarshal(ext.Value, &digest); err == nil && string(digest) == string(keyAuth[:]) {
+					ca.status = acme.StatusValid
+				}
+			}
+		}
+	}
+	ca.respond(w, http.StatusOK, "", ca.challengeBody())
+}
+
+func (ca *acmeStandIn) finalize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	ca.decode(r, &req)
+	der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	require.NoError(ca.t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(ca.t, err)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	require.Equal(ca.t, acme.StatusValid, ca.status, "order finalized before authorization")
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 90),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	ca.cert, err = x509.CreateCertificate(rand.Reader, tmpl, ca.caCert, csr.PublicKey, ca.caKey)
+	require.NoError(ca.t, err)
+	ca.respond(w, http.StatusOK, ca.url("/order/1"), ca.orderBody())
+}
+
+func (ca *acmeStandIn) certificate(w http.ResponseWriter, r *http.Request) {
+	ca.decode(r, nil)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.nonce(w)
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert})
+	_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.caCert.Raw})
+}
+
+func TestACME(t *testing.T) {
+	ca := newACMEStandIn(t)
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	addr := freeAddr(t)
+	ca.vaAddr = addr
+	m := NewACMEManager(ACMEConfig{Domains: []string{"oss.test"}, DirectoryURL: ca.url("/dir"), Cache: NewValkeyCertCache(client)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, flow.New())
+	s.E().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}, http.MethodGet)
+	done := make(chan error, 1)
+	go func() { done <- s.RunTLS(addr, m.TLSConfig()) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	https := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{ServerName: "oss.test", RootCAs: ca.roots()},
+	}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = https.Get("https://" + addr + "/")
+		return err == nil
+	}, time.Second*10, time.Millisecond*50, "certificate was not issued: %v", err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "stand-in root", resp.TLS.PeerCertificates[0].Issuer.CommonName)
+
+	cached, err := NewValkeyCertCache(client).Get(context.Background(), "oss.test")
+	require.NoError(t, err, "certificate has to be shared through cache")
+	assert.True(t, strings.Contains(string(cached), "CERTIFICATE"))
+
+	_, err = (&tls.Dialer{Config: &tls.Config{ServerName: "other.test", RootCAs: ca.roots()}}).DialContext(context.Background(), "tcp", addr)
+	assert.Error(t, err, "hosts outside of policy must not get certificates")
+}
+
+func TestACMEHostPolicy(t *testing.T) {
+	ctx := context.Background()
+	host := "oss.test"
+	m := NewACMEManager(ACMEConfig{Domains: []string{"ignored.test"}, HostPolicy: func(ctx context.Context, h string) error {
+		return autocert.HostWhitelist(host)(ctx, h)
+	}})
+	assert.NoError(t, m.HostPolicy(ctx, "oss.test"))
+	assert.Error(t, m.HostPolicy(ctx, "ignored.test"), "host policy replaces domains")
+	host = "new.test"
+	assert.NoError(t, m.HostPolicy(ctx, "new.test"), "policy is asked on every handshake")
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Check reports whether dependency needed to serve requests is usable
+	Check func(ctx context.Context) error
+
+	// Health serves liveness and readiness probes, readiness fails once draining started or any check fails
+	Health struct {
+		l        *slog.Logger
+		timeout  time.Duration
+		draining atomic.Bool
+
+		mu     sync.RWMutex
+		names  []string
+		checks map[string]Check
+	}
+	HealthOptsFn func(*Health)
+
+	healthResponse struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+)
+
+const (
+	healthOK          = "ok"
+	healthFailed      = "failed"
+	healthDraining    = "draining"
+	healthUnavailable = "unavailable"
+)
+
+func NewHealth(opts ...HealthOptsFn) *Health {
+	h := &Health{l: slog.Default(), timeout: time.Second * 2, checks: make(map[string]Check)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func WithHealthLogger(l *slog.Logger) HealthOptsFn {
+	return func(h *Health) {
+		h.l = l
+	}
+}
+
+// WithCheckTimeout limits how long readiness waits for every check
+func WithCheckTimeout(d time.Duration) HealthOptsFn {
+	return func(h *Health) {
+		h.timeout = d
+	}
+}
+
+// AddCheck registers readiness check, checks run in order they were added
+func (h *Health) AddCheck(name string, c Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = c
+}
+
+// Drain fails readiness so load balancers stop routing new requests while in-flight ones finish
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// Live reports process is up and serving, it never checks dependencies so restarts are not triggered by them
+func (h *Health) Live(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: healthOK})
+}
+
+// Ready runs checks and reports their state, errors are logged but not exposed since probes are public
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.Draining() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: healthDraining})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := healthResponse{Status: healthOK, Checks: make(map[string]string, len(h.names))}
+	code := http.StatusOK
+	for _, name := range h.names {
+		if err := h.checks[name](ctx); err != nil {
+			h.l.Warn("readiness check failed", "check", name, "error", err)
+			res.Checks[name] = healthFailed
+			res.Status = healthUnavailable
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[name] = healthOK
+	}
+	writeHealth(w, code, res)
+}
+
+func writeHealth(w http.ResponseWriter, code int, res healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
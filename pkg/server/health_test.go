@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, healthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var res healthResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	return rec.Code, res
+}
+
+func TestHealth(t *testing.T) {
+	h := NewHealth()
+	failing := errors.New("connection refused 10.0.0.1:6379")
+	var dbErr error
+	h.AddCheck("valkey", func(context.Context) error { return dbErr })
+	h.AddCheck("keys", func(context.Context) error { return nil })
+
+	code, res := probe(t, h.Ready)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, healthResponse{Status: "ok", Checks: map[string]string{"valkey": "ok", "keys": "ok"}}, res)
+
+	dbErr = failing
+	code, res = probe(t, h.Ready)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthResponse{Status: "unavailable", Checks: map[string]string{"valkey": "failed", "keys": "ok"}}, res)
+
+	code, _ = probe(t, h.Live)
+	assert.Equal(t, http.StatusOK, code, "liveness must not depend on checks")
+
+	dbErr = nil
+	h.Drain()
+	code, res = probe(t, h.Ready)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", res.Status)
+	code, _ = probe(t, h.Live)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	h := NewHealth(WithCheckTimeout(time.Millisecond * 10))
+	h.AddCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	code, _ := probe(t, h.Ready)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func TestServerDrains(t *testing.T) {
+	addr := freeAddr(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, flow.New(), WithDrainDelay(time.Millisecond*200), WithShutdownTimeout(time.Millisecond*500))
+	s.E().HandleFunc("/readyz", s.Health().Ready, http.MethodGet)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(addr) }()
+
+	ready := func() int {
+		resp, err := http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			return 0
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Eventually(t, func() bool { return ready() == http.StatusOK }, time.Second, time.Millisecond*10)
+
+	cancel()
+	assert.Eventually(t, func() bool { return ready() == http.StatusServiceUnavailable }, time.Millisecond*150, time.Millisecond*10,
+		"readiness must fail while server still serves")
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 2):
+		t.Fatal("server did not shut down after drain delay")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, flow.New(), WithShutdownTimeout(time.Millisecond*100))
+	streamEnded := make(chan struct{})
+	s.E().HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		defer close(streamEnded)
+		sse := NewSSEWriter(w, r)
+		defer sse.Close()
+		_ = sse.WriteHTML("first")
+		// hung stream, only closed connection ends it
+		<-r.Context().Done()
+	}, http.MethodGet)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(addr) }()
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		var err error
+		resp, err = http.Get("http://" + addr + "/stream")
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	defer resp.Body.Close()
+	assert.EqualValues(t, 1, s.ActiveStreams())
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 2):
+		t.Fatal("hung stream blocked shutdown")
+	}
+	<-streamEnded
+	assert.EqualValues(t, 0, s.ActiveStreams())
+}
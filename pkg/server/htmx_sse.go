@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/valyala/bytebufferpool"
 )
@@ -21,33 +22,47 @@ type SSEResponse struct {
 	w              io.Writer
 	rc             *http.ResponseController
 	acceptEncoding string
+	writeTimeout   time.Duration
+	end            func()
 
 	hasSentMessage bool
 }
 
 // NewSSEWriter creates a new SSE writer and sets up the required headers
 // Returns nil if the ResponseWriter doesn't support flushing
+// Stream is tracked by Server until Close, so shutdown waits for it
 func NewSSEWriter(w http.ResponseWriter, r *http.Request) *SSEResponse {
 	rc := http.NewResponseController(w)
 
 	// Set SSE headers
 	w.Header().Set("Access-Control-Allow-Origin", "*") // Optional: if calling from a different domain
-	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Content-Type", "text/event-stream")
 	if r.ProtoMajor == 1 {
 		w.Header().Set("Connection", "keep-alive")
 	}
 
-	if err := rc.Flush(); err != nil {
-		panic(fmt.Sprintf("response writer failed to flush: %v", err))
-	}
-
-	return &SSEResponse{
+	sse := &SSEResponse{
 		mu:             new(sync.Mutex),
 		w:              w,
 		rc:             rc,
 		acceptEncoding: r.Header.Get("Accept-Encoding"),
 	}
+	var s *Server
+	if s, sse.end = trackStream(r); s != nil {
+		sse.writeTimeout = s.streamWriteTimeout
+	}
+
+	if err := rc.Flush(); err != nil {
+		sse.end()
+		panic(fmt.Sprintf("response writer failed to flush: %v", err))
+	}
+	return sse
+}
+
+// Close ends tracking of stream, it doesn't close the connection
+func (sse *SSEResponse) Close() {
+	sse.end()
 }
 
 func (sse *SSEResponse) WriteHTML(html string) error {
@@ -80,6 +95,10 @@ func (sse *SSEResponse) WriteHTML(html string) error {
 		return fmt.Errorf("failed to write newline: %w", err)
 	}
 
+	if sse.writeTimeout > 0 {
+		// not every writer supports deadlines, e.g. recorders in tests
+		_ = sse.rc.SetWriteDeadline(time.Now().Add(sse.writeTimeout))
+	}
 	if _, err := buf.WriteTo(sse.w); err != nil {
 		return fmt.Errorf("failed to write to response writer: %w", err)
 	}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type clientIPContextKey struct{}
+
+// ClientIP returns address of client which sent request, resolved by TrustProxies when peer is trusted proxy
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// TrustProxies is middleware resolving client IP from header set by trusted proxies. For X-Forwarded-For
+// rightmost address not belonging to trusted proxy is used, other headers (e.g. X-Real-IP) hold single address.
+// Header of untrusted peers is ignored so clients can't spoof their address.
+func TrustProxies(header string, trusted []netip.Prefix) func(http.Handler) http.Handler {
+	header = http.CanonicalHeaderKey(header)
+	isTrusted := func(s string) bool {
+		addr, err := netip.ParseAddr(strings.TrimSpace(s))
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		if header == "" || len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer := peerIP(r)
+			if !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := peer
+			values := strings.Split(strings.Join(r.Header.Values(header), ","), ",")
+			for i := len(values) - 1; i >= 0; i-- {
+				v := strings.TrimSpace(values[i])
+				if _, err := netip.ParseAddr(v); err != nil {
+					break
+				}
+				ip = v
+				if !isTrusted(v) {
+					break
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, ip)))
+		})
+	}
+}
+
+// ParsePrefixes parses CIDRs and single addresses
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// SetRetryAfter sets Retry-After header in whole seconds, rounded up
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((d+time.Second-1)/time.Second)))
+}
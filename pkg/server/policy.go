@@ -0,0 +1,330 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/alexedwards/flow"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+)
+
+type (
+	// Credential is a way request proves who sent it, credentials are combined as bit set
+	Credential uint8
+
+	// Policy declares credentials accepted by route, route without credentials is public.
+	// Unless Always is set, policy is only enforced while authentication is enabled,
+	// bearer tokens are however always verified when route accepts them.
+	// Optional policy resolves Principal from accepted credentials but lets anonymous requests in.
+	Policy struct {
+		Credentials    Credential
+		Scope          tokens.Scope
+		Always         bool
+		AllowAnonymous bool
+	}
+
+	// Route is route registered through Guard together with its policy
+	Route struct {
+		Method string
+		Path   string
+		Policy Policy
+	}
+
+	// Guard registers routes with their policy and enforces it
+	Guard struct {
+		l        *slog.Logger
+		enabled  func() bool
+		basic    func() (username, password string)
+		sessions *Sessions
+		tokens   tokens.Store
+		denied   http.HandlerFunc
+		routes   []Route
+	}
+	GuardOptsFn func(*Guard)
+
+	// Principal is caller of request, zero value is anonymous caller
+	Principal struct {
+		// Credential which authenticated request, zero for anonymous
+		Credential Credential
+		// Subject is username, SSO identity or token name
+		Subject string
+		Session *Session
+		Token   *tokens.Token
+	}
+
+	principalContextKey struct{}
+)
+
+const (
+	CredentialSession Credential = 1 << iota
+	CredentialBasic
+	CredentialToken
+)
+
+// Public policy lets anyone in
+var Public = Policy{}
+
+// SessionAuth accepts UI login session
+func SessionAuth() Policy {
+	return Policy{Credentials: CredentialSession}
+}
+
+// BasicAuth accepts configured basic auth credentials
+func BasicAuth() Policy {
+	return Policy{Credentials: CredentialBasic}
+}
+
+// TokenAuth accepts bearer API token holding scope
+func TokenAuth(scope tokens.Scope) Policy {
+	return Policy{Credentials: CredentialToken, Scope: scope}
+}
+
+// Or accepts credentials of both policies
+func (p Policy) Or(o Policy) Policy {
+	p.Credentials |= o.Credentials
+	p.Always = p.Always || o.Always
+	p.AllowAnonymous = p.AllowAnonymous && o.AllowAnonymous
+	if p.Scope == "" {
+		p.Scope = o.Scope
+	}
+	return p
+}
+
+// Required enforces policy even when authentication is disabled
+func (p Policy) Required() Policy {
+	p.Always = true
+	return p
+}
+
+// Optional lets anonymous requests in, Principal is still resolved from credentials
+func (p Policy) Optional() Policy {
+	p.AllowAnonymous = true
+	return p
+}
+
+func (p Policy) IsPublic() bool {
+	return p.Credentials == 0
+}
+
+func (p Policy) Accepts(c Credential) bool {
+	return p.Credentials&c != 0
+}
+
+func (c Credential) String() string {
+	switch c {
+	case CredentialSession:
+		return "session"
+	case CredentialBasic:
+		return "basic"
+	case CredentialToken:
+		return "token"
+	}
+	return ""
+}
+
+func (p Policy) String() string {
+	if p.IsPublic() {
+		return "public"
+	}
+	var parts []string
+	if p.Accepts(CredentialSession) {
+		parts = append(parts, "session")
+	}
+	if p.Accepts(CredentialBasic) {
+		parts = append(parts, "basic")
+	}
+	if p.Accepts(CredentialToken) {
+		parts = append(parts, "token("+string(p.Scope)+")")
+	}
+	s := strings.Join(parts, "|")
+	if p.Always {
+		s += " required"
+	}
+	if p.AllowAnonymous {
+		s += " optional"
+	}
+	return s
+}
+
+// WithAuthEnabled tells whether policies without Always are enforced, it is checked on every request
+func WithAuthEnabled(fn func() bool) GuardOptsFn {
+	return func(g *Guard) {
+		g.enabled = fn
+	}
+}
+
+// WithBasicCredentials sets configured basic auth credentials, empty credentials never match
+func WithBasicCredentials(fn func() (username, password string)) GuardOptsFn {
+	return func(g *Guard) {
+		g.basic = fn
+	}
+}
+
+func WithSessionAuth(s *Sessions) GuardOptsFn {
+	return func(g *Guard) {
+		g.sessions = s
+	}
+}
+
+func WithTokenAuth(store tokens.Store) GuardOptsFn {
+	return func(g *Guard) {
+		g.tokens = store
+	}
+}
+
+// WithDeniedHandler replaces default 401 response for requests without accepted credentials
+func WithDeniedHandler(fn http.HandlerFunc) GuardOptsFn {
+	return func(g *Guard) {
+		g.denied = fn
+	}
+}
+
+func WithGuardLogger(l *slog.Logger) GuardOptsFn {
+	return func(g *Guard) {
+		g.l = l
+	}
+}
+
+func NewGuard(opts ...GuardOptsFn) *Guard {
+	g := &Guard{
+		l:       slog.Default(),
+		enabled: func() bool { return true },
+		basic:   func() (string, string) { return "", "" },
+		denied: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Handle registers route on mux behind policy, middlewares mw run after policy so they see Principal
+func (g *Guard) Handle(e *flow.Mux, method, path string, p Policy, fn http.HandlerFunc, mw ...func(http.Handler) http.Handler) {
+	method = strings.ToUpper(method)
+	var h http.Handler = fn
+	for _, m := range slices.Backward(mw) {
+		h = m(h)
+	}
+	e.Handle(path, TagRoute(path, g.Protect(p, h)), method)
+	g.routes = append(g.routes, Route{Method: method, Path: path, Policy: p})
+}
+
+// Routes lists routes registered through Handle sorted by path and method
+func (g *Guard) Routes() []Route {
+	return slices.SortedFunc(slices.Values(g.routes), func(a, b Route) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+}
+
+// Protect wraps handler with policy enforcement, handler finds caller with PrincipalFromContext
+func (g *Guard) Protect(p Policy, next http.Handler) http.Handler {
+	if p.IsPublic() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.Accepts(CredentialToken) && g.tokens != nil {
+			if value, ok := bearerToken(r); ok {
+				if r, ok = g.authenticateToken(w, r, value, p.Scope); ok {
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+		}
+		if !p.Always && !g.enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if p.Accepts(CredentialBasic) {
+			if username, ok := g.validBasic(r); ok {
+				next.ServeHTTP(w, WithPrincipal(r, &Principal{Credential: CredentialBasic, Subject: username}))
+				return
+			}
+		}
+		if p.Accepts(CredentialSession) && g.sessions != nil {
+			if s, err := g.sessions.Validate(r); err == nil {
+				next.ServeHTTP(w, WithPrincipal(r, &Principal{Credential: CredentialSession, Subject: s.Subject, Session: s}))
+				return
+			}
+		}
+		if p.AllowAnonymous {
+			next.ServeHTTP(w, r)
+			return
+		}
+		g.denied(w, r)
+	})
+}
+
+func (g *Guard) validBasic(r *http.Request) (string, bool) {
+	username, password := g.basic()
+	if username == "" || password == "" {
+		return "", false
+	}
+	return username, AuthValidateHeader(r, username, password) == nil
+}
+
+// authenticateToken resolves bearer token, checks scope and rate limit, on failure response is written
+func (g *Guard) authenticateToken(w http.ResponseWriter, r *http.Request, value string, scope tokens.Scope) (*http.Request, bool) {
+	t, err := g.tokens.Authenticate(r.Context(), value)
+	switch {
+	case errors.Is(err, tokens.ErrInvalidToken):
+		w.WriteHeader(http.StatusUnauthorized)
+		return r, false
+	case err != nil:
+		g.l.Error("failed to authenticate token", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return r, false
+	case !t.Has(scope):
+		http.Error(w, "token lacks "+string(scope)+" scope", http.StatusForbidden)
+		return r, false
+	}
+
+	ok, wait, err := g.tokens.Allow(r.Context(), t)
+	if err != nil {
+		g.l.Error("failed to check token rate limit", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return r, false
+	}
+	if !ok {
+		SetRetryAfter(w, wait)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return r, false
+	}
+	return WithPrincipal(r, &Principal{Credential: CredentialToken, Subject: t.Name, Token: t}), true
+}
+
+// IsAuthenticated tells whether caller presented accepted credentials
+func (p *Principal) IsAuthenticated() bool {
+	return p.Credential != 0
+}
+
+// WithPrincipal returns request carrying caller
+func WithPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p))
+}
+
+// PrincipalFromContext returns caller of request, it is anonymous unless guard authenticated request
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalContextKey{}).(*Principal); ok {
+		return p
+	}
+	return &Principal{}
+}
+
+// TokenFromContext returns API token which authenticated request, if any
+func TokenFromContext(ctx context.Context) (*tokens.Token, bool) {
+	t := PrincipalFromContext(ctx).Token
+	return t, t != nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
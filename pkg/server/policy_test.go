@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func TestPolicyString(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   string
+	}{
+		{Public, "public"},
+		{SessionAuth(), "session"},
+		{BasicAuth().Or(TokenAuth(tokens.ScopeAdmin)).Required(), "basic|token(admin) required"},
+		{SessionAuth().Or(BasicAuth()), "session|basic"},
+		{SessionAuth().Optional(), "session optional"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.policy.String())
+	}
+}
+
+func TestGuardTokenAuth(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	store := tokens.NewValkey(client)
+
+	create, err := store.Create(context.Background(), &tokens.Token{Name: "ci", Scopes: []tokens.Scope{tokens.ScopeSecretsCreate}})
+	require.NoError(t, err)
+	limited, err := store.Create(context.Background(), &tokens.Token{Name: "slow", Scopes: []tokens.Scope{tokens.ScopeSecretsCreate}, RateLimit: 1})
+	require.NoError(t, err)
+
+	g := NewGuard(WithTokenAuth(store), WithAuthEnabled(func() bool { return false }))
+	mux := flow.New()
+	g.Handle(mux, http.MethodPost, "/create", BasicAuth().Or(TokenAuth(tokens.ScopeSecretsCreate)), func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := TokenFromContext(r.Context()); ok {
+			w.Header().Set("X-Token", t.Name)
+		}
+	})
+	g.Handle(mux, http.MethodPost, "/admin", BasicAuth().Or(TokenAuth(tokens.ScopeAdmin)).Required(), func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		actor  string
+	}{
+		{"anonymous with auth disabled", "/create", "", http.StatusOK, ""},
+		{"valid token", "/create", create, http.StatusOK, "ci"},
+		{"invalid token with auth disabled", "/create", "oss_invalid", http.StatusUnauthorized, ""},
+		{"missing scope", "/admin", create, http.StatusForbidden, ""},
+		{"anonymous on required route", "/admin", "", http.StatusUnauthorized, ""},
+		{"rate limit allows first", "/create", limited, http.StatusOK, "slow"},
+		{"rate limit rejects second", "/create", limited, http.StatusTooManyRequests, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.actor, w.Header().Get("X-Token"))
+		})
+	}
+}
+
+func TestGuardBasicAuth(t *testing.T) {
+	tests := []struct {
+		name               string
+		username, password string
+		header             func(r *http.Request)
+		status             int
+	}{
+		{"valid", "admin", "pass", func(r *http.Request) { r.SetBasicAuth("admin", "pass") }, http.StatusOK},
+		{"wrong password", "admin", "pass", func(r *http.Request) { r.SetBasicAuth("admin", "nope") }, http.StatusUnauthorized},
+		{"missing header", "admin", "pass", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"empty credentials never match", "", "", func(r *http.Request) { r.SetBasicAuth("", "") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGuard(WithBasicCredentials(func() (string, string) { return tt.username, tt.password }))
+			h := g.Protect(BasicAuth(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p := PrincipalFromContext(r.Context())
+				assert.Equal(t, CredentialBasic, p.Credential)
+				assert.Equal(t, tt.username, p.Subject)
+			}))
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			tt.header(r)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestGuardSessionPrincipal(t *testing.T) {
+	sessions := NewSessions(NewMemorySessionStore())
+	g := NewGuard(WithSessionAuth(sessions))
+
+	var got *Principal
+	h := g.Protect(SessionAuth().Optional(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = PrincipalFromContext(r.Context())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), requestWith())
+	require.NotNil(t, got)
+	assert.False(t, got.IsAuthenticated())
+
+	session, ck := loginCookie(t, sessions, requestWith(), "alice")
+	h.ServeHTTP(httptest.NewRecorder(), requestWith(ck))
+	assert.True(t, got.IsAuthenticated())
+	assert.Equal(t, CredentialSession, got.Credential)
+	assert.Equal(t, "alice", got.Subject)
+	assert.Equal(t, session.ID, got.Session.ID)
+
+	w := httptest.NewRecorder()
+	g.Protect(SessionAuth(), h).ServeHTTP(w, requestWith())
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// linkPreviewAgents are lowercase fragments of user agents of chat apps unfurling links and of crawlers,
+// they fetch every link posted, so they must never see what is behind secret link
+var linkPreviewAgents = []string{
+	"slackbot", "slack-imgproxy", "skypeuripreview", "microsoftpreview", "discordbot", "telegrambot",
+	"whatsapp", "facebookexternalhit", "facebookcatalog", "meta-externalagent", "twitterbot", "linkedinbot",
+	"mattermost", "rocket.chat", "redditbot", "embedly", "iframely", "vkshare",
+	"googlebot", "google-inspectiontool", "bingbot", "bingpreview", "yandexbot", "duckduckbot", "applebot",
+	"baiduspider", "petalbot", "crawler", "spider",
+}
+
+// IsLinkPreview reports whether request comes from link unfurler or crawler rather than person
+func IsLinkPreview(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return false
+	}
+	for _, agent := range linkPreviewAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkPreview is middleware serving preview to link unfurlers and crawlers instead of next, so they neither
+// reveal secrets nor learn whether they exist
+func LinkPreview(preview http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsLinkPreview(r) {
+				preview.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLinkPreview(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		expected  bool
+	}{
+		{userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", expected: true},
+		{userAgent: "Mozilla/5.0 (Windows NT 6.1; WOW64) SkypeUriPreview Preview/0.5 skype-url-preview@microsoft.com", expected: true},
+		{userAgent: "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", expected: true},
+		{userAgent: "TelegramBot (like TwitterBot)", expected: true},
+		{userAgent: "WhatsApp/2.23.20.0", expected: true},
+		{userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", expected: true},
+		{userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", expected: true},
+		{userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", expected: false},
+		{userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36", expected: false},
+		{userAgent: "oss-client/1", expected: false},
+		{userAgent: "", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/abc-123", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			assert.Equal(t, tt.expected, IsLinkPreview(r))
+		})
+	}
+}
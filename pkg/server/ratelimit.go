@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type (
+	// Budget names group of routes sharing one rate limit per client
+	Budget string
+
+	// RateLimit is token bucket refilled with PerMinute tokens up to Burst, zero PerMinute disables it
+	RateLimit struct {
+		PerMinute int
+		Burst     int
+	}
+
+	// RateLimitStore keeps token buckets, it has to be safe for concurrent use
+	RateLimitStore interface {
+		// Take removes token from bucket, when bucket is empty it returns wait until next token
+		Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+	}
+
+	// RateLimiter limits requests per client and budget, clients are identified by API token or IP
+	RateLimiter struct {
+		l     *slog.Logger
+		store RateLimitStore
+
+		mu     sync.RWMutex
+		limits map[Budget]RateLimit
+	}
+	RateLimiterOptsFn func(*RateLimiter)
+
+	memoryRateLimitStore struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		lastSweep time.Time
+	}
+	bucket struct {
+		tokens float64
+		at     time.Time
+	}
+
+	valkeyRateLimitStore struct {
+		client valkey.Client
+	}
+)
+
+const (
+	BudgetCreate Budget = "create"
+	BudgetReveal Budget = "reveal"
+	BudgetStatic Budget = "static"
+)
+
+// takeScript refills bucket by elapsed time and takes one token, returns {allowed, wait ms}
+var takeScript = valkey.NewLuaScript(`local b = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(b[1]) or burst
+local at = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - at) * rate)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+return {allowed, wait}`)
+
+// WithRateLimit sets limit of budget, budgets without limit are not limited
+func WithRateLimit(budget Budget, limit RateLimit) RateLimiterOptsFn {
+	return func(rl *RateLimiter) {
+		if limit.PerMinute > 0 {
+			limit.Burst = max(limit.Burst, 1)
+			rl.limits[budget] = limit
+		}
+	}
+}
+
+func WithRateLimiterLogger(l *slog.Logger) RateLimiterOptsFn {
+	return func(rl *RateLimiter) {
+		rl.l = l
+	}
+}
+
+func NewRateLimiter(store RateLimitStore, opts ...RateLimiterOptsFn) *RateLimiter {
+	rl := &RateLimiter{l: slog.Default(), store: store, limits: make(map[Budget]RateLimit)}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	return rl
+}
+
+// Reconfigure replaces limits of every budget with those set by opts, e.g. on config reload, buckets of
+// clients are kept. Options other than WithRateLimit are ignored.
+func (rl *RateLimiter) Reconfigure(opts ...RateLimiterOptsFn) {
+	next := &RateLimiter{limits: make(map[Budget]RateLimit)}
+	for _, opt := range opts {
+		opt(next)
+	}
+	rl.mu.Lock()
+	rl.limits = next.limits
+	rl.mu.Unlock()
+}
+
+func (rl *RateLimiter) limit(budget Budget) (RateLimit, bool) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	limit, ok := rl.limits[budget]
+	return limit, ok
+}
+
+// Limit is middleware taking token from client bucket of budget, requests over limit get 429. Routes
+// accepting API tokens pass it to Guard.Handle, so it runs after token is verified.
+// Store failures let requests through so Valkey outage does not take the service down.
+func (rl *RateLimiter) Limit(budget Budget) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, ok := rl.limit(budget)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := string(budget) + ":" + rateLimitClient(r)
+			allowed, wait, err := rl.store.Take(r.Context(), key, limit)
+			if err != nil {
+				rl.l.Warn("rate limit check failed", "budget", budget, "error", err)
+			} else if !allowed {
+				SetRetryAfter(w, wait)
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClient identifies client by API token verified by guard, so clients behind one NAT using tokens
+// do not share budget, or by client IP. Bearer values guard hasn't verified count against IP, otherwise
+// every made up token would get fresh bucket.
+func rateLimitClient(r *http.Request) string {
+	if t, ok := TokenFromContext(r.Context()); ok {
+		return "token:" + string(t.ID)
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// perMillisecond is refill rate of limit in tokens per millisecond
+func (l RateLimit) perMillisecond() float64 {
+	return float64(l.PerMinute) / float64(time.Minute.Milliseconds())
+}
+
+// NewMemoryRateLimitStore keeps buckets in process memory, every replica limits on its own
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *memoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rate := limit.perMillisecond()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), at: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(float64(limit.Burst), b.tokens+float64(now.Sub(b.at).Milliseconds())*rate)
+	b.at = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration(math.Ceil((1-b.tokens)/rate)) * time.Millisecond, nil
+}
+
+// sweep drops buckets idle for a while, they would be full again anyway, it has to be called with lock held
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.at) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// NewValkeyRateLimitStore keeps buckets in Valkey so all replicas share them
+func NewValkeyRateLimitStore(client valkey.Client) RateLimitStore {
+	return &valkeyRateLimitStore{client: client}
+}
+
+func (s *valkeyRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	res, err := takeScript.Exec(ctx, s.client, []string{s.generateKey(key)}, []string{
+		strconv.FormatFloat(limit.perMillisecond(), 'f', -1, 64),
+		strconv.Itoa(limit.Burst),
+		strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).AsIntSlice()
+	if err != nil {
+		return false, 0, fmt.Errorf("server: error taking rate limit token: %w", err)
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("server: unexpected rate limit reply %v", res)
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (_ *valkeyRateLimitStore) generateKey(key string) string {
+	return key + "_ratelimit"
+}
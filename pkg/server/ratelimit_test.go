@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func rateLimitStores(t *testing.T) map[string]RateLimitStore {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return map[string]RateLimitStore{
+		"memory": NewMemoryRateLimitStore(),
+		"valkey": NewValkeyRateLimitStore(client),
+	}
+}
+
+func TestRateLimitStoreTake(t *testing.T) {
+	ctx := context.Background()
+	limit := RateLimit{PerMinute: 60, Burst: 2}
+	for name, store := range rateLimitStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for range limit.Burst {
+				ok, _, err := store.Take(ctx, "k", limit)
+				require.NoError(t, err)
+				assert.True(t, ok)
+			}
+			ok, wait, err := store.Take(ctx, "k", limit)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.InDelta(t, time.Second, wait, float64(time.Millisecond*50))
+
+			ok, _, err = store.Take(ctx, "other", limit)
+			require.NoError(t, err)
+			assert.True(t, ok, "buckets are per key")
+		})
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(),
+		WithRateLimit(BudgetCreate, RateLimit{PerMinute: 1, Burst: 1}),
+		WithRateLimit(BudgetStatic, RateLimit{}),
+	)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	create := rl.Limit(BudgetCreate)(ok)
+	reveal := rl.Limit(BudgetReveal)(ok)
+
+	send := func(h http.Handler, ip, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/api/create", nil)
+		r.RemoteAddr = ip + ":4321"
+		if token != "" {
+			r = WithPrincipal(r, &Principal{Credential: CredentialToken, Token: &tokens.Token{ID: tokens.ID(token)}})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send(create, "10.0.0.1", "").Code)
+	w := send(create, "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, send(create, "10.0.0.2", "").Code, "other IP has own bucket")
+	assert.Equal(t, http.StatusOK, send(create, "10.0.0.1", "oss_a").Code, "token has own bucket")
+	assert.Equal(t, http.StatusTooManyRequests, send(create, "10.0.0.3", "oss_a").Code, "token bucket follows token across IPs")
+	for range 5 {
+		assert.Equal(t, http.StatusOK, send(reveal, "10.0.0.1", "").Code, "budget without limit")
+	}
+
+	// bearer values guard hasn't verified don't get their own bucket
+	r := httptest.NewRequest(http.MethodPut, "/api/create", nil)
+	r.RemoteAddr = "10.0.0.5:4321"
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer oss_random%d", i))
+		w := httptest.NewRecorder()
+		create.ServeHTTP(w, r)
+		assert.Equal(t, want, w.Code, "random bearer values share IP bucket")
+	}
+
+	rl.Reconfigure(WithRateLimit(BudgetReveal, RateLimit{PerMinute: 1, Burst: 1}))
+	assert.Equal(t, http.StatusOK, send(create, "10.0.0.1", "").Code, "reconfigured middleware drops removed limit")
+	assert.Equal(t, http.StatusOK, send(reveal, "10.0.0.4", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(reveal, "10.0.0.4", "").Code, "reconfigured middleware applies new limit")
+}
+
+func TestTrustProxies(t *testing.T) {
+	trusted, err := ParsePrefixes([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		peer   string
+		values []string
+		want   string
+	}{
+		{"untrusted peer is not believed", "X-Forwarded-For", "203.0.113.9", []string{"1.2.3.4"}, "203.0.113.9"},
+		{"trusted peer without header", "X-Forwarded-For", "10.1.1.1", nil, "10.1.1.1"},
+		{"rightmost untrusted address", "X-Forwarded-For", "10.1.1.1", []string{"6.6.6.6, 1.2.3.4, 10.2.2.2"}, "1.2.3.4"},
+		{"multiple header lines", "X-Forwarded-For", "192.168.1.1", []string{"6.6.6.6", "1.2.3.4"}, "1.2.3.4"},
+		{"garbage stops walk", "X-Forwarded-For", "10.1.1.1", []string{"1.2.3.4, junk"}, "10.1.1.1"},
+		{"single address header", "X-Real-IP", "10.1.1.1", []string{"1.2.3.4"}, "1.2.3.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := TrustProxies(tt.header, trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer + ":1234"
+			for _, v := range tt.values {
+				r.Header.Add(tt.header, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = ParsePrefixes([]string{"not an ip"})
+	assert.Error(t, err)
+}
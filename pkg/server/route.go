@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeContextKey struct{}
+
+// RecordRoute lets middleware running before router learn which route served request. Returned function
+// reports pattern tagged by TagRoute once request was served, empty when no tagged route matched.
+// Middlewares recording the same request share the pattern.
+func RecordRoute(r *http.Request) (*http.Request, func() string) {
+	if pattern, ok := r.Context().Value(routeContextKey{}).(*string); ok {
+		return r, func() string { return *pattern }
+	}
+	pattern := new(string)
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey{}, pattern)), func() string { return *pattern }
+}
+
+// TagRoute tells RecordRoute pattern of route serving request, Guard.Handle tags every route it registers
+func TagRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := r.Context().Value(routeContextKey{}).(*string); ok {
+			*p = pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type cspNonceContextKey struct{}
+
+// contentSecurityPolicy allows only scripts and styles carrying request nonce, 'strict-dynamic' lets them load
+// their modules and 'unsafe-eval' is needed by Alpine.js to evaluate x-data and other directive expressions
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'nonce-%[1]s' 'strict-dynamic' 'unsafe-eval'; " +
+	"style-src 'self' 'nonce-%[1]s'; " +
+	"img-src 'self' data:; " +
+	"font-src 'self'; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'none'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// CSPNonce returns nonce inline scripts and styles of request have to carry to pass Content-Security-Policy
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceContextKey{}).(string)
+	return nonce
+}
+
+// SecurityHeaders is middleware setting strict security headers on every response. Responses are not stored
+// by browsers or proxies unless handler sets its own Cache-Control, because they may carry secrets or links
+// to them. HSTS is sent when hstsMaxAge is positive, it should be only when site is served with TLS.
+func SecurityHeaders(hstsMaxAge time.Duration) func(http.Handler) http.Handler {
+	var hsts string
+	if hstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(hstsMaxAge.Seconds()), 10) + "; includeSubDomains"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b := make([]byte, 18)
+			_, _ = rand.Read(b)
+			nonce := base64.RawURLEncoding.EncodeToString(b)
+
+			h := w.Header()
+			h.Set("Content-Security-Policy", fmt.Sprintf(contentSecurityPolicy, nonce))
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("X-Content-Type-Options", "nosniff")
+			// secret links carry decryption key, it must never leak to other sites
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("Cache-Control", "no-store")
+			if hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspNonceContextKey{}, nonce)))
+		})
+	}
+}
+
+// SecretRoute is middleware for routes serving secrets or links to them, responses are never stored and
+// never indexed, regardless of headers set by other middlewares
+func SecretRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Cache-Control", "no-store")
+		h.Set("X-Robots-Tag", "noindex, nofollow, noarchive, nosnippet")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CacheControl is middleware overriding Cache-Control set by SecurityHeaders, e.g. for static assets
+func CacheControl(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
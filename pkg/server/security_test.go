@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	var nonces []string
+	h := SecurityHeaders(time.Hour * 24 * 365)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r.Context()))
+	}))
+
+	var policies []string
+	for range 2 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc-123", nil))
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+		policies = append(policies, rec.Header().Get("Content-Security-Policy"))
+	}
+	require.Len(t, nonces, 2)
+	assert.NotEmpty(t, nonces[0])
+	assert.NotEqual(t, nonces[0], nonces[1], "nonce must be unique per request")
+	assert.Contains(t, policies[0], "script-src 'nonce-"+nonces[0]+"'")
+	assert.Contains(t, policies[0], "frame-ancestors 'none'")
+	assert.NotContains(t, policies[0], "unsafe-inline")
+
+	rec := httptest.NewRecorder()
+	SecurityHeaders(0)(CacheControl("no-cache")(http.NotFoundHandler())).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.css", nil))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+}
+
+func TestServerLimits(t *testing.T) {
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, flow.New(), WithLimits(Limits{ReadHeaderTimeout: time.Millisecond * 100, MaxHeaderBytes: 1 << 10}))
+	s.E().HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, http.MethodGet)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(addr) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, time.Second, time.Millisecond*10)
+	defer conn.Close()
+
+	// slow client never finishing headers is disconnected
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: oss.test\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*2)))
+	_, err = bufio.NewReader(conn).ReadByte()
+	var netErr net.Error
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection must be closed by server")
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Large", strings.Repeat("a", 1<<14))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+}
+
+func TestSecretRoute(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SecurityHeaders(0)(CacheControl("no-cache")(SecretRoute(http.NotFoundHandler()))).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc-123", nil))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "noindex, nofollow, noarchive, nosnippet", rec.Header().Get("X-Robots-Tag"))
+}
@@ -2,36 +2,201 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/alexedwards/flow"
 )
 
-type Server struct {
-	ctx context.Context
-	e   *flow.Mux
-	srv *http.Server
+type (
+	Server struct {
+		ctx        context.Context
+		e          *flow.Mux
+		srv        *http.Server
+		l          *slog.Logger
+		health     *Health
+		drainDelay time.Duration
+		// shutdownTimeout bounds waiting for in-flight requests, connections still open after it are closed
+		shutdownTimeout time.Duration
+		// streamWriteTimeout bounds every write of SSE stream, so stalled client can't hold stream forever
+		streamWriteTimeout time.Duration
+		limits             Limits
+
+		streams       sync.WaitGroup
+		activeStreams atomic.Int64
+	}
+	OptsFn func(*Server)
+
+	// Limits bound time and size of requests, so slow or malicious clients can't hold connections and memory
+	Limits struct {
+		ReadHeaderTimeout time.Duration
+		// ReadTimeout bounds reading whole request including attachments
+		ReadTimeout time.Duration
+		// WriteTimeout bounds writing response, SSE streams extend it with every write
+		WriteTimeout   time.Duration
+		IdleTimeout    time.Duration
+		MaxHeaderBytes int
+	}
+
+	serverContextKey struct{}
+)
+
+// DefaultLimits are used by servers without WithLimits
+var DefaultLimits = Limits{
+	ReadHeaderTimeout: time.Second * 10,
+	ReadTimeout:       time.Minute,
+	WriteTimeout:      time.Minute,
+	IdleTimeout:       time.Minute * 2,
+	MaxHeaderBytes:    1 << 16,
+}
+
+func New(ctx context.Context, e *flow.Mux, opts ...OptsFn) *Server {
+	s := &Server{ctx: ctx, e: e, l: slog.Default(), health: NewHealth(), shutdownTimeout: time.Second * 15, limits: DefaultLimits}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func WithLogger(l *slog.Logger) OptsFn {
+	return func(s *Server) {
+		s.l = l
+	}
+}
+
+func WithHealth(h *Health) OptsFn {
+	return func(s *Server) {
+		s.health = h
+	}
+}
+
+// WithDrainDelay keeps serving with failing readiness for d after context is done, so load balancers notice
+// before listener closes
+func WithDrainDelay(d time.Duration) OptsFn {
+	return func(s *Server) {
+		s.drainDelay = d
+	}
+}
+
+func WithLimits(l Limits) OptsFn {
+	return func(s *Server) {
+		s.limits = l
+	}
 }
 
-func New(ctx context.Context, e *flow.Mux) *Server {
-	return &Server{ctx: ctx, e: e}
+// WithShutdownTimeout bounds how long shutdown waits for in-flight requests and SSE streams
+func WithShutdownTimeout(d time.Duration) OptsFn {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithStreamWriteTimeout bounds every write of SSE streams, zero disables it
+func WithStreamWriteTimeout(d time.Duration) OptsFn {
+	return func(s *Server) {
+		s.streamWriteTimeout = d
+	}
 }
 
 func (s *Server) Ctx() context.Context { return s.ctx }
 
 func (s *Server) E() *flow.Mux { return s.e }
 
-func (s *Server) Run(addr string) (err error) {
-	s.srv = &http.Server{Addr: addr, Handler: s.e}
+func (s *Server) Health() *Health { return s.health }
+
+// ActiveStreams returns number of SSE streams in flight
+func (s *Server) ActiveStreams() int64 { return s.activeStreams.Load() }
+
+// Run serves until context is done, then drains, waits up to shutdown timeout for in-flight requests and
+// returns once server is stopped
+func (s *Server) Run(addr string) error {
+	return s.run(addr, nil)
+}
+
+// RunTLS is Run serving HTTPS with certificates of c, e.g. from CertReloader or ACME manager
+func (s *Server) RunTLS(addr string, c *tls.Config) error {
+	return s.run(addr, c)
+}
+
+func (s *Server) run(addr string, c *tls.Config) (err error) {
+	s.srv = &http.Server{
+		Addr:      addr,
+		Handler:   s.e,
+		TLSConfig: c,
+
+		ReadHeaderTimeout: s.limits.ReadHeaderTimeout,
+		ReadTimeout:       s.limits.ReadTimeout,
+		WriteTimeout:      s.limits.WriteTimeout,
+		IdleTimeout:       s.limits.IdleTimeout,
+		MaxHeaderBytes:    s.limits.MaxHeaderBytes,
+		// requests outlive server context, they are cancelled only when their connection is closed
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), serverContextKey{}, s)
+		},
+	}
 
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-s.ctx.Done()
-		_ = s.srv.Shutdown(context.Background())
+		s.shutdown()
 	}()
 
-	if err = s.srv.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
-		return nil
+	if c != nil {
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-stopped
+	return nil
+}
+
+func (s *Server) shutdown() {
+	s.health.Drain()
+	time.Sleep(s.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.l.Warn("shutdown timed out, closing connections", "error", err, "streams", s.ActiveStreams())
+		_ = s.srv.Close()
+	}
+
+	// closed connections fail writes of remaining streams, give their handlers the chance to return
+	done := make(chan struct{})
+	go func() {
+		s.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.l.Error("streams still running after shutdown", "streams", s.ActiveStreams())
+	}
+}
+
+// trackStream registers SSE stream of request served by Server, returned function ends it
+func trackStream(r *http.Request) (*Server, func()) {
+	s, ok := r.Context().Value(serverContextKey{}).(*Server)
+	if !ok {
+		return nil, func() {}
+	}
+	s.streams.Add(1)
+	s.activeStreams.Add(1)
+	var once sync.Once
+	return s, func() {
+		once.Do(func() {
+			s.activeStreams.Add(-1)
+			s.streams.Done()
+		})
 	}
-	return err
 }
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+type (
+	// Session is authenticated UI login, Subject is username or SSO identity
+	Session struct {
+		ID         string
+		Subject    string
+		CreatedAt  time.Time
+		LastSeenAt time.Time
+	}
+
+	// SessionStore persists sessions, ttl tells store when it may forget session on its own
+	SessionStore interface {
+		Save(ctx context.Context, s *Session, ttl time.Duration) error
+		Get(ctx context.Context, id string) (*Session, error)
+		Delete(ctx context.Context, id string) error
+	}
+
+	// SessionPruner is implemented by stores which need periodic removal of expired sessions
+	SessionPruner interface {
+		Prune(ctx context.Context)
+	}
+
+	Sessions struct {
+		store       SessionStore
+		cookieName  string
+		idle        time.Duration
+		absolute    time.Duration
+		touchPeriod time.Duration
+	}
+	SessionOptsFn func(*Sessions)
+)
+
+const (
+	defaultIdleTimeout     = time.Hour
+	defaultAbsoluteTimeout = time.Hour * 12
+	sessionCookieName      = "oss_auth"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func NewSessions(store SessionStore, opts ...SessionOptsFn) *Sessions {
+	s := &Sessions{
+		store:      store,
+		cookieName: sessionCookieName,
+		idle:       defaultIdleTimeout,
+		absolute:   defaultAbsoluteTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// refreshing last seen on every request is wasteful, a fraction of idle timeout is precise enough
+	s.touchPeriod = min(s.idle/10, time.Minute)
+	return s
+}
+
+// WithTimeouts sets how long session survives without requests and how long it lives at most
+func WithTimeouts(idle, absolute time.Duration) SessionOptsFn {
+	return func(s *Sessions) {
+		if idle > 0 {
+			s.idle = idle
+		}
+		if absolute > 0 {
+			s.absolute = absolute
+		}
+	}
+}
+
+func WithSessionCookieName(name string) SessionOptsFn {
+	return func(s *Sessions) {
+		s.cookieName = name
+	}
+}
+
+func (s *Sessions) Store() SessionStore {
+	return s.store
+}
+
+// Login starts new session for subject, any session presented by request is destroyed
+// so a session ID planted before login is never promoted
+func (s *Sessions) Login(w http.ResponseWriter, r *http.Request, subject string) (*Session, error) {
+	if ck, err := r.Cookie(s.cookieName); err == nil && ck.Value != "" {
+		if err = s.store.Delete(r.Context(), ck.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	session := &Session{ID: hex.EncodeToString(b), Subject: subject, CreatedAt: now, LastSeenAt: now}
+	if err := s.store.Save(r.Context(), session, s.ttl(session, now)); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    session.ID,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   int(s.absolute.Seconds()),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session, nil
+}
+
+// Logout destroys session presented by request and clears cookie
+func (s *Sessions) Logout(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{Name: s.cookieName, Path: "/", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	ck, err := r.Cookie(s.cookieName)
+	if err != nil || ck.Value == "" {
+		return nil
+	}
+	return s.store.Delete(r.Context(), ck.Value)
+}
+
+// Validate returns live session presented by request and extends its idle timeout
+func (s *Sessions) Validate(r *http.Request) (*Session, error) {
+	ck, err := r.Cookie(s.cookieName)
+	if err != nil || ck.Value == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	session, err := s.store.Get(r.Context(), ck.Value)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) >= s.idle || now.Sub(session.CreatedAt) >= s.absolute {
+		_ = s.store.Delete(r.Context(), session.ID)
+		return nil, ErrSessionNotFound
+	}
+
+	if now.Sub(session.LastSeenAt) >= s.touchPeriod {
+		session.LastSeenAt = now
+		if err = s.store.Save(r.Context(), session, s.ttl(session, now)); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+func (s *Sessions) ttl(session *Session, now time.Time) time.Duration {
+	return min(s.idle, session.CreatedAt.Add(s.absolute).Sub(now))
+}
+
+type memorySessionStore struct {
+	m sync.Map
+}
+
+type memorySession struct {
+	session   Session
+	expiresAt time.Time
+}
+
+// NewMemorySessionStore keeps sessions in process memory, they are lost on restart and not shared between replicas
+func NewMemorySessionStore() SessionStore {
+	return new(memorySessionStore)
+}
+
+func (s *memorySessionStore) Save(_ context.Context, session *Session, ttl time.Duration) error {
+	s.m.Store(session.ID, memorySession{session: *session, expiresAt: time.Now().Add(ttl)})
+	return nil
+}
+
+func (s *memorySessionStore) Get(_ context.Context, id string) (*Session, error) {
+	v, ok := s.m.Load(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	ms := v.(memorySession)
+	if !time.Now().Before(ms.expiresAt) {
+		s.m.Delete(id)
+		return nil, ErrSessionNotFound
+	}
+	return &ms.session, nil
+}
+
+func (s *memorySessionStore) Delete(_ context.Context, id string) error {
+	s.m.Delete(id)
+	return nil
+}
+
+func (s *memorySessionStore) Prune(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.m.Range(func(k, v any) bool {
+				if !now.Before(v.(memorySession).expiresAt) {
+					s.m.Delete(k)
+				}
+				return true
+			})
+		}
+	}
+}
+
+const (
+	sessionFieldSubject    = "subject"
+	sessionFieldCreatedAt  = "created_at"
+	sessionFieldLastSeenAt = "last_seen_at"
+)
+
+type valkeySessionStore struct {
+	client valkey.Client
+}
+
+// NewValkeySessionStore shares sessions between replicas, Valkey expires them on its own
+func NewValkeySessionStore(client valkey.Client) SessionStore {
+	return &valkeySessionStore{client: client}
+}
+
+func (s *valkeySessionStore) Save(ctx context.Context, session *Session, ttl time.Duration) error {
+	key := s.generateKey(session.ID)
+	for _, res := range s.client.DoMulti(ctx,
+		s.client.B().Hset().Key(key).FieldValue().
+			FieldValue(sessionFieldSubject, session.Subject).
+			FieldValue(sessionFieldCreatedAt, strconv.FormatInt(session.CreatedAt.UnixMilli(), 10)).
+			FieldValue(sessionFieldLastSeenAt, strconv.FormatInt(session.LastSeenAt.UnixMilli(), 10)).
+			Build(),
+		s.client.B().Pexpire().Key(key).Milliseconds(max(ttl.Milliseconds(), 1)).Build(),
+	) {
+		if err := res.Error(); err != nil {
+			return fmt.Errorf("server: error storing session: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *valkeySessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	m, err := s.client.Do(ctx, s.client.B().Hgetall().Key(s.generateKey(id)).Build()).AsStrMap()
+	if err != nil {
+		return nil, fmt.Errorf("server: error getting session: %w", err)
+	}
+	if len(m) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	session := &Session{ID: id, Subject: m[sessionFieldSubject]}
+	if v, err := strconv.ParseInt(m[sessionFieldCreatedAt], 10, 64); err == nil {
+		session.CreatedAt = time.UnixMilli(v).UTC()
+	}
+	if v, err := strconv.ParseInt(m[sessionFieldLastSeenAt], 10, 64); err == nil {
+		session.LastSeenAt = time.UnixMilli(v).UTC()
+	}
+	return session, nil
+}
+
+func (s *valkeySessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Do(ctx, s.client.B().Del().Key(s.generateKey(id)).Build()).Error(); err != nil {
+		return fmt.Errorf("server: error deleting session: %w", err)
+	}
+	return nil
+}
+
+func (_ *valkeySessionStore) generateKey(id string) string {
+	return id + "_session"
+}
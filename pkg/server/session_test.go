@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func sessionStores(t *testing.T) map[string]SessionStore {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return map[string]SessionStore{
+		"memory": NewMemorySessionStore(),
+		"valkey": NewValkeySessionStore(client),
+	}
+}
+
+func requestWith(cookies ...*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, ck := range cookies {
+		r.AddCookie(ck)
+	}
+	return r
+}
+
+func loginCookie(t *testing.T, s *Sessions, r *http.Request, subject string) (*Session, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	session, err := s.Login(w, r, subject)
+	require.NoError(t, err)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return session, cookies[0]
+}
+
+func TestSessions(t *testing.T) {
+	for name, store := range sessionStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := NewSessions(store)
+
+			_, err := s.Validate(requestWith())
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+
+			session, ck := loginCookie(t, s, requestWith(), "alice")
+			assert.True(t, ck.HttpOnly)
+			assert.Equal(t, http.SameSiteStrictMode, ck.SameSite)
+
+			got, err := s.Validate(requestWith(ck))
+			require.NoError(t, err)
+			assert.Equal(t, session.ID, got.ID)
+			assert.Equal(t, "alice", got.Subject)
+
+			// logging in again rotates session ID and destroys the old one
+			rotated, rotatedCk := loginCookie(t, s, requestWith(ck), "alice")
+			assert.NotEqual(t, session.ID, rotated.ID)
+			_, err = s.Validate(requestWith(ck))
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+
+			w := httptest.NewRecorder()
+			require.NoError(t, s.Logout(w, requestWith(rotatedCk)))
+			assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+			_, err = s.Validate(requestWith(rotatedCk))
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+		})
+	}
+}
+
+func TestSessionsTimeouts(t *testing.T) {
+	for name, store := range sessionStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := NewSessions(store, WithTimeouts(time.Hour, time.Hour*2))
+			r := requestWith()
+
+			idle, idleCk := loginCookie(t, s, r, "idle")
+			idle.LastSeenAt = idle.LastSeenAt.Add(-time.Hour)
+			require.NoError(t, store.Save(r.Context(), idle, time.Minute))
+			_, err := s.Validate(requestWith(idleCk))
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+
+			// active session still ends once absolute timeout passes
+			old, oldCk := loginCookie(t, s, r, "old")
+			old.CreatedAt = old.CreatedAt.Add(-time.Hour * 2)
+			require.NoError(t, store.Save(r.Context(), old, time.Minute))
+			_, err = s.Validate(requestWith(oldCk))
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+
+			// validation refreshes last seen so active sessions survive idle timeout
+			active, activeCk := loginCookie(t, s, r, "active")
+			active.LastSeenAt = active.LastSeenAt.Add(-time.Minute * 30)
+			require.NoError(t, store.Save(r.Context(), active, time.Minute))
+			got, err := s.Validate(requestWith(activeCk))
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now(), got.LastSeenAt, time.Second)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+type (
+	// CertReloader serves certificate from files and reloads it once they are rotated, e.g. by cert-manager
+	CertReloader struct {
+		certFile string
+		keyFile  string
+		l        *slog.Logger
+		// checkInterval limits how often files are checked for changes during handshakes
+		checkInterval time.Duration
+
+		mu        sync.Mutex
+		cert      *tls.Certificate
+		certMod   time.Time
+		keyMod    time.Time
+		checkedAt time.Time
+	}
+
+	// ACMEConfig configures certificates issued by ACME CA, e.g. Let's Encrypt
+	ACMEConfig struct {
+		Domains []string
+		// HostPolicy decides hosts instead of Domains, e.g. when they follow reloaded config
+		HostPolicy autocert.HostPolicy
+		Email      string
+		// DirectoryURL of CA, Let's Encrypt when empty
+		DirectoryURL string
+		// Cache keeps account key and certificates, shared cache lets replicas reuse them
+		Cache autocert.Cache
+	}
+
+	valkeyCertCache struct {
+		client valkey.Client
+	}
+)
+
+func NewCertReloader(certFile, keyFile string, l *slog.Logger) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile, l: l, checkInterval: time.Second}
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.load(certMod, keyMod); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate is tls.Config GetCertificate serving the latest valid certificate, half rotated files
+// keep previous certificate until both are replaced
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checkedAt) >= c.checkInterval {
+		c.checkedAt = time.Now()
+		certMod, keyMod, err := c.modTimes()
+		switch {
+		case err != nil:
+			c.l.Warn("failed to check certificate files", "error", err)
+		case !certMod.Equal(c.certMod) || !keyMod.Equal(c.keyMod):
+			if err = c.load(certMod, keyMod); err != nil {
+				c.l.Warn("failed to reload certificate, serving previous one", "error", err)
+			} else {
+				c.l.Info("certificate reloaded", "file", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+func (c *CertReloader) modTimes() (time.Time, time.Time, error) {
+	cs, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("server: error reading certificate: %w", err)
+	}
+	ks, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("server: error reading certificate key: %w", err)
+	}
+	return cs.ModTime(), ks.ModTime(), nil
+}
+
+func (c *CertReloader) load(certMod, keyMod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("server: error loading certificate: %w", err)
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// NewACMEManager obtains and renews certificates of configured domains on first handshake, its HTTPHandler
+// has to serve port 80 when CA validates with HTTP challenges
+func NewACMEManager(c ACMEConfig) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: c.HostPolicy,
+		Cache:      c.Cache,
+		Email:      c.Email,
+	}
+	if m.HostPolicy == nil {
+		m.HostPolicy = autocert.HostWhitelist(c.Domains...)
+	}
+	if c.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: c.DirectoryURL}
+	}
+	return m
+}
+
+// NewValkeyCertCache keeps ACME account key and certificates in Valkey, so every replica serves the same ones
+func NewValkeyCertCache(client valkey.Client) autocert.Cache {
+	return &valkeyCertCache{client: client}
+}
+
+func (c *valkeyCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := c.client.Do(ctx, c.client.B().Get().Key(c.generateKey(key)).Build()).AsBytes()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, fmt.Errorf("server: error getting certificate: %w", err)
+	}
+	return b, nil
+}
+
+func (c *valkeyCertCache) Put(ctx context.Context, key string, data []byte) error {
+	if err := c.client.Do(ctx, c.client.B().Set().Key(c.generateKey(key)).Value(valkey.BinaryString(data)).Build()).Error(); err != nil {
+		return fmt.Errorf("server: error storing certificate: %w", err)
+	}
+	return nil
+}
+
+func (c *valkeyCertCache) Delete(ctx context.Context, key string) error {
+	if err := c.client.Do(ctx, c.client.B().Del().Key(c.generateKey(key)).Build()).Error(); err != nil {
+		return fmt.Errorf("server: error deleting certificate: %w", err)
+	}
+	return nil
+}
+
+func (_ *valkeyCertCache) generateKey(key string) string {
+	return key + "_acme"
+}
+
+// RedirectHTTPS redirects plain HTTP requests to the same host served with TLS on addr
+func RedirectHTTPS(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
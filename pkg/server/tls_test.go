@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSigned writes certificate of name and its key, modification time is moved to mod
+func writeSelfSigned(t *testing.T, certFile, keyFile, name string, mod time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	kb, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+}
+
+func servedName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	now := time.Now()
+	writeSelfSigned(t, certFile, keyFile, "first.test", now.Add(-time.Minute))
+
+	r, err := NewCertReloader(certFile, keyFile, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	r.checkInterval = 0
+	assert.Equal(t, "first.test", servedName(t, r))
+
+	writeSelfSigned(t, certFile, keyFile, "second.test", now)
+	assert.Equal(t, "second.test", servedName(t, r), "rotated certificate must be served")
+
+	// half rotated: new certificate doesn't match old key yet
+	keyPEM, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	writeSelfSigned(t, certFile, keyFile, "third.test", now.Add(time.Minute))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	assert.Equal(t, "second.test", servedName(t, r), "previous certificate must be kept until key is rotated too")
+
+	_, err = NewCertReloader(filepath.Join(dir, "missing.crt"), keyFile, slog.Default())
+	assert.Error(t, err)
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		addr     string
+		target   string
+		expected string
+	}{
+		{addr: ":443", target: "http://oss.test/abc-123?x=1", expected: "https://oss.test/abc-123?x=1"},
+		{addr: "0.0.0.0:8443", target: "http://oss.test:8080/", expected: "https://oss.test:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RedirectHTTPS(tt.addr).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package sso implements OpenID Connect login using authorization code flow with PKCE.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+type (
+	Config struct {
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		RedirectURL  string
+		Scopes       []string
+		// AllowedGroups admits users in at least one of groups, empty admits everyone
+		AllowedGroups []string
+		// AllowedDomains admits users whose verified e-mail belongs to one of domains, empty admits everyone
+		AllowedDomains []string
+		GroupsClaim    string
+	}
+
+	Identity struct {
+		Subject string
+		Email   string
+		Name    string
+		Groups  []string
+	}
+
+	// Flow is per-login state kept between redirect to provider and callback
+	Flow struct {
+		State    string
+		Nonce    string
+		Verifier string
+	}
+
+	Provider struct {
+		cfg Config
+
+		mu       sync.Mutex
+		oauth    *oauth2.Config
+		verifier *oidc.IDTokenVerifier
+	}
+)
+
+var (
+	ErrInvalidState = errors.New("sso: invalid login state")
+	ErrAccessDenied = errors.New("sso: access denied")
+)
+
+func New(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	if !slices.Contains(cfg.Scopes, oidc.ScopeOpenID) {
+		cfg.Scopes = append([]string{oidc.ScopeOpenID}, cfg.Scopes...)
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &Provider{cfg: cfg}
+}
+
+// init runs discovery once it succeeds, so an unreachable issuer does not block server start. It returns
+// OAuth2 config of the current redirect URL.
+func (p *Provider) init(ctx context.Context) (*oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("sso: discovery failed: %w", err)
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	return p.oauth, nil
+}
+
+// SetRedirectURL replaces callback URL, e.g. when server domain is reloaded, logins already begun
+// keep the previous one
+func (p *Provider) SetRedirectURL(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg.RedirectURL = url
+	if p.oauth != nil {
+		oauth := *p.oauth
+		oauth.RedirectURL = url
+		p.oauth = &oauth
+	}
+}
+
+// Begin starts login and returns URL user has to be redirected to
+func (p *Provider) Begin(ctx context.Context) (*Flow, string, error) {
+	oauth, err := p.init(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	flow := &Flow{State: randomString(), Nonce: randomString(), Verifier: oauth2.GenerateVerifier()}
+	url := oauth.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	return flow, url, nil
+}
+
+// Finish exchanges code for tokens, verifies ID token and applies group and domain filters
+func (p *Provider) Finish(ctx context.Context, flow *Flow, state, code string) (*Identity, error) {
+	if flow == nil || state == "" || state != flow.State {
+		return nil, ErrInvalidState
+	}
+	oauth, err := p.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("sso: code exchange failed: %w", err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("sso: token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("sso: invalid id_token: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, ErrInvalidState
+	}
+
+	var claims map[string]any
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("sso: invalid id_token claims: %w", err)
+	}
+	identity := &Identity{Subject: idToken.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	if groups, ok := claims[p.cfg.GroupsClaim].([]any); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+
+	if !p.admits(identity, claims) {
+		return nil, ErrAccessDenied
+	}
+	return identity, nil
+}
+
+func (p *Provider) admits(identity *Identity, claims map[string]any) bool {
+	if len(p.cfg.AllowedDomains) > 0 {
+		// domain of an unverified address proves nothing, providers omitting the claim are refused too
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return false
+		}
+		_, domain, ok := strings.Cut(identity.Email, "@")
+		if !ok || !slices.ContainsFunc(p.cfg.AllowedDomains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+			return false
+		}
+	}
+	if len(p.cfg.AllowedGroups) > 0 {
+		return slices.ContainsFunc(identity.Groups, func(g string) bool { return slices.Contains(p.cfg.AllowedGroups, g) })
+	}
+	return true
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
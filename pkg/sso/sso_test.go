@@ -0,0 +1,221 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+// mockProvider is minimal OIDC provider issuing RS256 ID tokens for codes handed out by authorize
+type mockProvider struct {
+	*httptest.Server
+	t      *testing.T
+	key    *rsa.PrivateKey
+	claims map[string]any
+
+	mu    sync.Mutex
+	codes map[string]authRequest
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T, claims map[string]any) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockProvider{t: t, key: key, claims: claims, codes: make(map[string]authRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		req, ok := m.codes[r.FormValue("code")]
+		delete(m.codes, r.FormValue("code"))
+		m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.idToken(req.nonce),
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize simulates user consenting at authorization URL and returns callback state and code
+func (m *mockProvider) authorize(authURL string) (state, code string) {
+	u, err := url.Parse(authURL)
+	require.NoError(m.t, err)
+	q := u.Query()
+	require.Equal(m.t, "S256", q.Get("code_challenge_method"))
+	require.Equal(m.t, "code", q.Get("response_type"))
+
+	code = randomString()
+	m.mu.Lock()
+	m.codes[code] = authRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (m *mockProvider) idToken(nonce string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: m.key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	require.NoError(m.t, err)
+
+	claims := map[string]any{
+		"iss":   m.URL,
+		"sub":   "user-1",
+		"aud":   "oss",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	payload, err := json.Marshal(claims)
+	require.NoError(m.t, err)
+	jws, err := signer.Sign(payload)
+	require.NoError(m.t, err)
+	raw, err := jws.CompactSerialize()
+	require.NoError(m.t, err)
+	return raw
+}
+
+func TestProviderLogin(t *testing.T) {
+	claims := map[string]any{"email": "alice@example.com", "email_verified": true, "name": "Alice", "groups": []string{"ops"}}
+	unverified := map[string]any{"email": "alice@example.com", "email_verified": false, "groups": []string{"ops"}}
+	unknown := map[string]any{"email": "alice@example.com", "groups": []string{"ops"}}
+	tests := []struct {
+		name    string
+		cfg     Config
+		claims  map[string]any
+		wantErr error
+	}{
+		{name: "no filters"},
+		{name: "allowed domain", cfg: Config{AllowedDomains: []string{"EXAMPLE.com"}}},
+		{name: "denied domain", cfg: Config{AllowedDomains: []string{"corp.example"}}, wantErr: ErrAccessDenied},
+		{name: "unverified email", cfg: Config{AllowedDomains: []string{"example.com"}}, claims: unverified, wantErr: ErrAccessDenied},
+		{name: "email_verified missing", cfg: Config{AllowedDomains: []string{"example.com"}}, claims: unknown, wantErr: ErrAccessDenied},
+		{name: "email_verified ignored without domains", claims: unknown},
+		{name: "allowed group", cfg: Config{AllowedGroups: []string{"dev", "ops"}}},
+		{name: "denied group", cfg: Config{AllowedGroups: []string{"dev"}}, wantErr: ErrAccessDenied},
+	}
+
+	idp := newMockProvider(t, claims)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			idp.claims = claims
+			if tt.claims != nil {
+				idp.claims = tt.claims
+			}
+			tt.cfg.Issuer, tt.cfg.ClientID, tt.cfg.RedirectURL = idp.URL, "oss", "http://localhost/auth/oidc/callback"
+			p := New(tt.cfg)
+
+			flow, authURL, err := p.Begin(ctx)
+			require.NoError(t, err)
+			state, code := idp.authorize(authURL)
+
+			identity, err := p.Finish(ctx, flow, state, code)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", identity.Subject)
+			assert.Equal(t, "alice@example.com", identity.Email)
+			assert.Equal(t, []string{"ops"}, identity.Groups)
+		})
+	}
+}
+
+func TestProviderRejectsTampering(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockProvider(t, nil)
+	p := New(Config{Issuer: idp.URL, ClientID: "oss", RedirectURL: "http://localhost/auth/oidc/callback"})
+
+	flow, authURL, err := p.Begin(ctx)
+	require.NoError(t, err)
+	_, code := idp.authorize(authURL)
+	_, err = p.Finish(ctx, flow, "forged", code)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// code bound to different PKCE challenge is refused by provider
+	other, _, err := p.Begin(ctx)
+	require.NoError(t, err)
+	_, err = p.Finish(ctx, &Flow{State: other.State, Nonce: flow.Nonce, Verifier: other.Verifier}, other.State, code)
+	assert.Error(t, err)
+}
+
+func TestProviderSetRedirectURL(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockProvider(t, nil)
+	p := New(Config{Issuer: idp.URL, ClientID: "oss", RedirectURL: "http://old.test/auth/oidc/callback"})
+	redirect := func() string {
+		t.Helper()
+		_, authURL, err := p.Begin(ctx)
+		require.NoError(t, err)
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		return u.Query().Get("redirect_uri")
+	}
+
+	assert.Equal(t, "http://old.test/auth/oidc/callback", redirect())
+	p.SetRedirectURL("https://new.test/auth/oidc/callback")
+	assert.Equal(t, "https://new.test/auth/oidc/callback", redirect(), "discovered provider follows new URL")
+}
+
+func TestValkeyFlowStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	ctx := context.Background()
+	s := NewValkeyFlowStore(client)
+	flow := &Flow{State: "state", Nonce: "nonce", Verifier: "verifier"}
+	require.NoError(t, s.Save(ctx, flow, time.Minute))
+
+	got, err := s.Take(ctx, "state")
+	require.NoError(t, err)
+	assert.Equal(t, flow, got)
+	_, err = s.Take(ctx, "state")
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
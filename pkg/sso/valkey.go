@@ -0,0 +1,67 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	fieldNonce    = "nonce"
+	fieldVerifier = "verifier"
+)
+
+// FlowStore keeps login flows between redirect and callback, shared by all replicas
+type FlowStore interface {
+	Save(context.Context, *Flow, time.Duration) error
+	// Take returns flow once, later calls with same state yield ErrInvalidState
+	Take(context.Context, string) (*Flow, error)
+}
+
+var takeScript = valkey.NewLuaScript(`local v = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return v`)
+
+type valkeyFlowStore struct {
+	client valkey.Client
+}
+
+func NewValkeyFlowStore(client valkey.Client) FlowStore {
+	return &valkeyFlowStore{client: client}
+}
+
+func (s *valkeyFlowStore) Save(ctx context.Context, flow *Flow, ttl time.Duration) error {
+	key := s.generateKey(flow.State)
+	for _, res := range s.client.DoMulti(ctx,
+		s.client.B().Hset().Key(key).FieldValue().
+			FieldValue(fieldNonce, flow.Nonce).
+			FieldValue(fieldVerifier, flow.Verifier).
+			Build(),
+		s.client.B().Expire().Key(key).Seconds(int64(ttl.Seconds())).Build(),
+	) {
+		if err := res.Error(); err != nil {
+			return fmt.Errorf("sso: error storing login flow: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *valkeyFlowStore) Take(ctx context.Context, state string) (*Flow, error) {
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+	m, err := takeScript.Exec(ctx, s.client, []string{s.generateKey(state)}, nil).AsStrMap()
+	if err != nil {
+		return nil, fmt.Errorf("sso: error getting login flow: %w", err)
+	}
+	if len(m) == 0 {
+		return nil, ErrInvalidState
+	}
+	return &Flow{State: state, Nonce: m[fieldNonce], Verifier: m[fieldVerifier]}, nil
+}
+
+func (_ *valkeyFlowStore) generateKey(state string) string {
+	return state + "_oidc"
+}
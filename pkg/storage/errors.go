@@ -5,5 +5,8 @@ import (
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
+	ErrRecordNotFound     = errors.New("record not found")
+	ErrRecordBurned       = errors.New("record burned")
+	ErrInvalidManageToken = errors.New("invalid manage token")
+	ErrLifetimeExceeded   = errors.New("record lifetime exceeded")
 )
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	RecordEventType string
+
+	// RecordEvent describes a change in lifecycle of a record, it never carries record content
+	RecordEvent struct {
+		Type      RecordEventType
+		ID        ID
+		Views     uint64
+		ViewsLeft uint64
+		ExpiresAt time.Time
+	}
+
+	// Observer is notified by Storage implementations after a record changed
+	Observer interface {
+		Observe(context.Context, RecordEvent)
+	}
+
+	ObserverFunc func(context.Context, RecordEvent)
+)
+
+const (
+	RecordEventStored   RecordEventType = "stored"
+	RecordEventViewed   RecordEventType = "viewed"
+	RecordEventBurned   RecordEventType = "burned"
+	RecordEventExtended RecordEventType = "extended"
+	RecordEventExpired  RecordEventType = "expired"
+)
+
+func (fn ObserverFunc) Observe(ctx context.Context, e RecordEvent) {
+	fn(ctx, e)
+}
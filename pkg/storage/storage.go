@@ -24,6 +24,10 @@ type (
 
 		ViewsLeft(context.Context, ID) (uint64, error)
 		Viewed(ctx context.Context, id ID) error
+
+		Status(context.Context, ID) (*RecordStatus, error)
+		Extend(context.Context, ID, time.Duration) (time.Time, error)
+		VerifyManageToken(context.Context, ID, string) error
 	}
 
 	Record[I ~string, K ~[]byte] interface {
@@ -48,12 +52,34 @@ type (
 	}
 
 	InsertResult[I ~string, K ~[]byte] struct {
-		ID        I
-		Key       K
+		ID          I
+		Key         K
+		ExpiresAt   time.Time
+		ManageToken string
+	}
+
+	// RecordState describes lifecycle of a record as seen by its creator
+	RecordState string
+
+	// RecordStatus is a content-free view of a record, safe to show to the creator
+	RecordStatus struct {
+		ID        ID
+		State     RecordState
+		Views     uint64
+		ViewsLeft uint64
+		MaxViews  uint64
+		CreatedAt time.Time
 		ExpiresAt time.Time
 	}
 )
 
-func newInsertResult[I ~string, K ~[]byte](id I, key K, expiresAt time.Time) *InsertResult[I, K] {
-	return &InsertResult[I, K]{ID: id, Key: key, ExpiresAt: expiresAt}
+const (
+	RecordStatePending RecordState = "pending"
+	RecordStateViewed  RecordState = "viewed"
+	RecordStateBurned  RecordState = "burned"
+	RecordStateExpired RecordState = "expired"
+)
+
+func newInsertResult[I ~string, K ~[]byte](id I, key K, expiresAt time.Time, manageToken string) *InsertResult[I, K] {
+	return &InsertResult[I, K]{ID: id, Key: key, ExpiresAt: expiresAt, ManageToken: manageToken}
 }
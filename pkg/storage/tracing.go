@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/pudottapommin/onetime-secrets-service/pkg/storage")
+
+func startSpan(ctx context.Context, name string, id ID) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+name, trace.WithAttributes(attribute.String("oss.record.id", string(id))))
+}
+
+// endSpan ends span, missing or burned records, wrong manage tokens and refused extensions are outcomes, not failures
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrRecordNotFound) && !errors.Is(err, ErrRecordBurned) &&
+		!errors.Is(err, ErrInvalidManageToken) && !errors.Is(err, ErrLifetimeExceeded) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
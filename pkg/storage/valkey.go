@@ -2,32 +2,64 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/valkey-io/valkey-go"
 	"github.com/valyala/bytebufferpool"
 )
 
+const (
+	metaFieldMaxViews  = "max_views"
+	metaFieldViews     = "views"
+	metaFieldCreatedAt = "created_at"
+	metaFieldExpiresAt = "expires_at"
+	metaFieldManage    = "manage"
+	metaFieldState     = "state"
+
+	// metaRetention keeps content-free metadata around after the record expired,
+	// so the creator can still see the outcome and expiry can be reported
+	metaRetention = time.Hour * 24
+
+	// MaxLifetime caps how long record can live since it was stored, no matter how often it is extended,
+	// it is the longest of secrets.ExpirationRanges
+	MaxLifetime = time.Hour * 24 * 7
+)
+
+var (
+	// metadata outlives the record itself, so it must never be recreated without TTL
+	metaSetScript  = valkey.NewLuaScript(`if redis.call('EXISTS', KEYS[1]) == 1 then return redis.call('HSET', KEYS[1], ARGV[1], ARGV[2]) end return 0`)
+	metaIncrScript = valkey.NewLuaScript(`if redis.call('EXISTS', KEYS[1]) == 1 then return redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2]) end return 0`)
+)
+
 type valkeyStorage struct {
 	client    valkey.Client
 	generator func(ID, Key) Record[ID, Key]
 	encoder   Encoder
 	encryptor Encryptor
+	observers []Observer
 }
 
-func NewValkey(client valkey.Client, encryptor Encryptor, generator func(ID, Key) Record[ID, Key]) Storage[ID, Key] {
+func NewValkey(client valkey.Client, encryptor Encryptor, generator func(ID, Key) Record[ID, Key], observers ...Observer) Storage[ID, Key] {
 	return &valkeyStorage{
 		encoder:   &GobEncoder{},
 		encryptor: encryptor,
 		client:    client,
 		generator: generator,
+		observers: observers,
 	}
 }
 
-func (s *valkeyStorage) Store(ctx context.Context, record Record[ID, Key]) (*InsertResult[ID, Key], error) {
-	var err error
+func (s *valkeyStorage) Store(ctx context.Context, record Record[ID, Key]) (_ *InsertResult[ID, Key], err error) {
+	ctx, span := startSpan(ctx, "Store", record.ID())
+	defer func() { endSpan(span, err) }()
+
 	record.Seal()
 	sr := storageRecord{
 		ID:         record.ID(),
@@ -49,30 +81,40 @@ func (s *valkeyStorage) Store(ctx context.Context, record Record[ID, Key]) (*Ins
 
 	buf := bytebufferpool.Get()
 	defer bytebufferpool.Put(buf)
-
-	ew, err := encryptor.EncryptStream(buf)
-	if err != nil {
-		return nil, fmt.Errorf("valkeya: error creating encrypt stream: %w", err)
-	}
-
-	if err = s.encoder.EncodeStream(ew, sr); err != nil {
-		return nil, fmt.Errorf("valkeya: error encoding record: %w", err)
+	if err = s.encode(ctx, buf, encryptor, sr); err != nil {
+		return nil, err
 	}
 
-	expiresAt := time.Now().Add(record.Expiration()).UTC()
+	now := time.Now()
+	expiresAt := now.Add(record.Expiration()).UTC()
+	manageToken := hex.EncodeToString(GenerateRandomKey(32))
 	rk, rck := s.generateStorageKeys(record.ID())
+	mk := s.generateMetaKey(record.ID())
 	c1 := s.client.B().Set().Key(rck).Value(fmt.Sprintf("%d", record.MaxViews())).Nx().Ex(record.Expiration()).Build()
 	c2 := s.client.B().Set().Key(rk).Value(valkey.BinaryString(buf.Bytes())).Nx().Ex(record.Expiration()).Build()
+	c3 := s.client.B().Hset().Key(mk).FieldValue().
+		FieldValue(metaFieldMaxViews, strconv.FormatUint(record.MaxViews(), 10)).
+		FieldValue(metaFieldViews, "0").
+		FieldValue(metaFieldCreatedAt, strconv.FormatInt(now.Unix(), 10)).
+		FieldValue(metaFieldExpiresAt, strconv.FormatInt(expiresAt.Unix(), 10)).
+		FieldValue(metaFieldManage, hashManageToken(manageToken)).
+		FieldValue(metaFieldState, string(RecordStatePending)).
+		Build()
+	c4 := s.client.B().Expireat().Key(mk).Timestamp(expiresAt.Add(metaRetention).Unix()).Build()
 
-	for _, result := range s.client.DoMulti(ctx, c1, c2) {
+	for _, result := range s.client.DoMulti(ctx, c1, c2, c3, c4) {
 		if result.Error() != nil {
 			return nil, fmt.Errorf("valkeya: error storing record: %w", result.Error())
 		}
 	}
-	return newInsertResult(record.ID(), record.Key(), expiresAt), nil
+	s.notify(ctx, RecordEvent{Type: RecordEventStored, ID: record.ID(), ViewsLeft: record.MaxViews(), ExpiresAt: expiresAt})
+	return newInsertResult(record.ID(), record.Key(), expiresAt, manageToken), nil
 }
 
-func (s *valkeyStorage) Get(ctx context.Context, id ID, k Key) (Record[ID, Key], error) {
+func (s *valkeyStorage) Get(ctx context.Context, id ID, k Key) (_ Record[ID, Key], err error) {
+	ctx, span := startSpan(ctx, "Get", id)
+	defer func() { endSpan(span, err) }()
+
 	rk, rck := s.generateStorageKeys(id)
 	counter, err := s.client.Do(ctx, s.client.B().Get().Key(rck).Build()).AsUint64()
 	if err != nil {
@@ -104,21 +146,19 @@ func (s *valkeyStorage) Get(ctx context.Context, id ID, k Key) (Record[ID, Key],
 		}
 	}
 
-	dr, err := encryptor.DecryptStream(pr)
+	sr, err := s.decode(ctx, pr, encryptor)
 	if err != nil {
-		return nil, fmt.Errorf("valkeya: error decrypting message: %w", err)
-	}
-
-	var sr storageRecord
-	if err = s.encoder.DecodeStream(dr, &sr); err != nil {
-		return nil, fmt.Errorf("valkeya: error decoding message: %w", err)
+		return nil, err
 	}
 	record := s.generator(sr.ID, k)
 	record.Reinit(sr.Value, sr.Passphrase, sr.ExpiresAt, sr.Files)
 	return record, nil
 }
 
-func (s *valkeyStorage) ViewsLeft(ctx context.Context, id ID) (uint64, error) {
+func (s *valkeyStorage) ViewsLeft(ctx context.Context, id ID) (_ uint64, err error) {
+	ctx, span := startSpan(ctx, "ViewsLeft", id)
+	defer func() { endSpan(span, err) }()
+
 	_, recordCounterKey := s.generateStorageKeys(id)
 	views, err := s.client.Do(ctx, s.client.B().Get().Key(recordCounterKey).Build()).AsUint64()
 	if err != nil {
@@ -130,7 +170,10 @@ func (s *valkeyStorage) ViewsLeft(ctx context.Context, id ID) (uint64, error) {
 	return views, nil
 }
 
-func (s *valkeyStorage) Viewed(ctx context.Context, id ID) error {
+func (s *valkeyStorage) Viewed(ctx context.Context, id ID) (err error) {
+	ctx, span := startSpan(ctx, "Viewed", id)
+	defer func() { endSpan(span, err) }()
+
 	_, recordCounterKey := s.generateStorageKeys(id)
 	viewsLeft, err := s.ViewsLeft(ctx, id)
 	if err != nil {
@@ -139,22 +182,188 @@ func (s *valkeyStorage) Viewed(ctx context.Context, id ID) error {
 	if viewsLeft == 0 {
 		return s.Burn(ctx, id)
 	}
-	return s.client.Do(ctx, s.client.B().Decr().Key(recordCounterKey).Build()).Error()
+	left, err := s.client.Do(ctx, s.client.B().Decr().Key(recordCounterKey).Build()).AsInt64()
+	if err != nil {
+		return fmt.Errorf("valkeya: error decrementing views: %w", err)
+	}
+
+	metaKey := s.generateMetaKey(id)
+	views, err := metaIncrScript.Exec(ctx, s.client, []string{metaKey}, []string{metaFieldViews, "1"}).AsInt64()
+	if err != nil {
+		return fmt.Errorf("valkeya: error counting view: %w", err)
+	}
+	if err = metaSetScript.Exec(ctx, s.client, []string{metaKey}, []string{metaFieldState, string(RecordStateViewed)}).Error(); err != nil {
+		return fmt.Errorf("valkeya: error updating state: %w", err)
+	}
+	s.notify(ctx, RecordEvent{Type: RecordEventViewed, ID: id, Views: uint64(max(views, 0)), ViewsLeft: uint64(max(left, 0))})
+	if left <= 0 {
+		return s.Burn(ctx, id)
+	}
+	return nil
 }
 
-func (s *valkeyStorage) Burn(ctx context.Context, id ID) error {
+func (s *valkeyStorage) Burn(ctx context.Context, id ID) (err error) {
+	ctx, span := startSpan(ctx, "Burn", id)
+	defer func() { endSpan(span, err) }()
+
 	recordKey, recordCounterKey := s.generateStorageKeys(id)
+	var deleted int64
 	for _, r := range s.client.DoMulti(ctx,
 		s.client.B().Del().Key(recordCounterKey).Build(),
 		s.client.B().Del().Key(recordKey).Build(),
+	) {
+		n, err := r.AsInt64()
+		if err != nil {
+			return err
+		}
+		deleted += n
+	}
+	if err := metaSetScript.Exec(ctx, s.client, []string{s.generateMetaKey(id)}, []string{metaFieldState, string(RecordStateBurned)}).Error(); err != nil {
+		return err
+	}
+	if deleted > 0 {
+		s.notify(ctx, RecordEvent{Type: RecordEventBurned, ID: id})
+	}
+	return nil
+}
+
+func (s *valkeyStorage) Status(ctx context.Context, id ID) (_ *RecordStatus, err error) {
+	ctx, span := startSpan(ctx, "Status", id)
+	defer func() { endSpan(span, err) }()
+
+	meta, err := s.client.Do(ctx, s.client.B().Hgetall().Key(s.generateMetaKey(id)).Build()).AsStrMap()
+	if err != nil {
+		return nil, fmt.Errorf("valkeya: error getting metadata: %w", err)
+	}
+	if len(meta) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	status := &RecordStatus{ID: id, State: RecordState(meta[metaFieldState])}
+	status.Views, _ = strconv.ParseUint(meta[metaFieldViews], 10, 64)
+	status.MaxViews, _ = strconv.ParseUint(meta[metaFieldMaxViews], 10, 64)
+	if ts, err := strconv.ParseInt(meta[metaFieldCreatedAt], 10, 64); err == nil {
+		status.CreatedAt = time.Unix(ts, 0).UTC()
+	}
+	if ts, err := strconv.ParseInt(meta[metaFieldExpiresAt], 10, 64); err == nil {
+		status.ExpiresAt = time.Unix(ts, 0).UTC()
+	}
+
+	if status.State == RecordStateBurned || status.State == RecordStateExpired {
+		return status, nil
+	}
+	status.ViewsLeft, err = s.ViewsLeft(ctx, id)
+	switch {
+	case errors.Is(err, ErrRecordNotFound) && !time.Now().Before(status.ExpiresAt):
+		status.State = RecordStateExpired
+	case errors.Is(err, ErrRecordNotFound):
+		status.State = RecordStateBurned
+	case err != nil:
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *valkeyStorage) Extend(ctx context.Context, id ID, d time.Duration) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "Extend", id)
+	defer func() { endSpan(span, err) }()
+
+	status, err := s.Status(ctx, id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch status.State {
+	case RecordStateBurned:
+		return time.Time{}, ErrRecordBurned
+	case RecordStateExpired:
+		return time.Time{}, ErrRecordNotFound
+	}
+
+	expiresAt := status.ExpiresAt.Add(d)
+	if expiresAt.After(status.CreatedAt.Add(MaxLifetime)) {
+		return time.Time{}, ErrLifetimeExceeded
+	}
+	rk, rck := s.generateStorageKeys(id)
+	mk := s.generateMetaKey(id)
+	for _, r := range s.client.DoMulti(ctx,
+		s.client.B().Expireat().Key(rk).Timestamp(expiresAt.Unix()).Build(),
+		s.client.B().Expireat().Key(rck).Timestamp(expiresAt.Unix()).Build(),
+		s.client.B().Expireat().Key(mk).Timestamp(expiresAt.Add(metaRetention).Unix()).Build(),
 	) {
 		if r.Error() != nil {
-			return r.Error()
+			return time.Time{}, fmt.Errorf("valkeya: error extending record: %w", r.Error())
 		}
 	}
+	if err = metaSetScript.Exec(ctx, s.client, []string{mk}, []string{metaFieldExpiresAt, strconv.FormatInt(expiresAt.Unix(), 10)}).Error(); err != nil {
+		return time.Time{}, fmt.Errorf("valkeya: error updating expiration: %w", err)
+	}
+	s.notify(ctx, RecordEvent{Type: RecordEventExtended, ID: id, Views: status.Views, ViewsLeft: status.ViewsLeft, ExpiresAt: expiresAt})
+	return expiresAt, nil
+}
+
+func (s *valkeyStorage) VerifyManageToken(ctx context.Context, id ID, token string) (err error) {
+	ctx, span := startSpan(ctx, "VerifyManageToken", id)
+	defer func() { endSpan(span, err) }()
+
+	hash, err := s.client.Do(ctx, s.client.B().Hget().Key(s.generateMetaKey(id)).Field(metaFieldManage).Build()).ToString()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("valkeya: error getting manage token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashManageToken(token))) != 1 {
+		return ErrInvalidManageToken
+	}
 	return nil
 }
 
+// encode encrypts and encodes record into w
+func (s *valkeyStorage) encode(ctx context.Context, w io.Writer, encryptor Encryptor, sr storageRecord) (err error) {
+	_, span := startSpan(ctx, "encode", sr.ID)
+	defer func() { endSpan(span, err) }()
+
+	ew, err := encryptor.EncryptStream(w)
+	if err != nil {
+		return fmt.Errorf("valkeya: error creating encrypt stream: %w", err)
+	}
+	if err = s.encoder.EncodeStream(ew, sr); err != nil {
+		return fmt.Errorf("valkeya: error encoding record: %w", err)
+	}
+	return nil
+}
+
+// decode decrypts and decodes record streamed from r, span includes waiting for Valkey to stream the record
+func (s *valkeyStorage) decode(ctx context.Context, r io.Reader, encryptor Encryptor) (_ storageRecord, err error) {
+	_, span := tracer.Start(ctx, "storage.decode")
+	defer func() { endSpan(span, err) }()
+
+	var sr storageRecord
+	dr, err := encryptor.DecryptStream(r)
+	if err != nil {
+		return sr, fmt.Errorf("valkeya: error decrypting message: %w", err)
+	}
+	if err = s.encoder.DecodeStream(dr, &sr); err != nil {
+		return sr, fmt.Errorf("valkeya: error decoding message: %w", err)
+	}
+	return sr, nil
+}
+
+func (s *valkeyStorage) notify(ctx context.Context, e RecordEvent) {
+	for _, o := range s.observers {
+		o.Observe(ctx, e)
+	}
+}
+
 func (_ *valkeyStorage) generateStorageKeys(id ID) (recordKey string, recordCounterKey string) {
 	return string(id), string(id + "_counter")
 }
+
+func (_ *valkeyStorage) generateMetaKey(id ID) string {
+	return string(id + "_meta")
+}
+
+func hashManageToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	expiredEventsPattern    = "__keyevent@*__:expired"
+	keyspaceEventsParameter = "notify-keyspace-events"
+)
+
+// ExpiryWatcher is implemented by storages able to report records expiring on their own
+type ExpiryWatcher interface {
+	WatchExpired(ctx context.Context, l *slog.Logger) error
+}
+
+var (
+	_ ExpiryWatcher = (*valkeyStorage)(nil)
+
+	// expireScript flips state exactly once, so only one replica reports the expiry, it returns views used
+	// so partly viewed secrets are not reported as unread
+	expireScript = valkey.NewLuaScript(`local st = redis.call('HGET', KEYS[1], 'state')
+if not st or st == 'burned' or st == 'expired' then return false end
+redis.call('HSET', KEYS[1], 'state', 'expired')
+return redis.call('HGET', KEYS[1], 'views')`)
+)
+
+// WatchExpired subscribes to Valkey keyspace notifications and reports expired records to observers.
+// It blocks until ctx is done, reconnecting with backoff whenever the subscription drops.
+func (s *valkeyStorage) WatchExpired(ctx context.Context, l *slog.Logger) error {
+	// keyspace events are disabled by default, enabling them is best effort as managed instances may forbid CONFIG
+	if err := s.enableExpiredEvents(ctx); err != nil && ctx.Err() == nil {
+		l.Warn("failed to enable keyspace notifications, make sure notify-keyspace-events contains Ex", slog.Any("err", err))
+	}
+
+	backoff := time.Second
+	for {
+		started := time.Now()
+		// subscription holds connection of its own, so flipping state of expired records never shares it
+		err := s.client.Dedicated(func(c valkey.DedicatedClient) error {
+			return c.Receive(ctx, c.B().Psubscribe().Pattern(expiredEventsPattern).Build(), func(msg valkey.PubSubMessage) {
+				s.expired(ctx, l, msg.Message)
+			})
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		l.Warn("expiry subscription dropped, reconnecting", slog.Any("err", err), slog.Duration("backoff", backoff))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// enableExpiredEvents adds flags of expired key events to notify-keyspace-events when they are missing,
+// flags the operator set for other consumers are kept
+func (s *valkeyStorage) enableExpiredEvents(ctx context.Context) error {
+	cfg, err := s.client.Do(ctx, s.client.B().ConfigGet().Parameter(keyspaceEventsParameter).Build()).AsStrMap()
+	if err != nil {
+		return err
+	}
+	flags, changed := expiredEventFlags(cfg[keyspaceEventsParameter])
+	if !changed {
+		return nil
+	}
+	return s.client.Do(ctx, s.client.B().ConfigSet().ParameterValue().ParameterValue(keyspaceEventsParameter, flags).Build()).Error()
+}
+
+// expiredEventFlags returns current flags with keyevent notifications (E) of expired keys (x, or A for all) added
+func expiredEventFlags(current string) (string, bool) {
+	flags := current
+	if !strings.ContainsRune(flags, 'E') {
+		flags += "E"
+	}
+	if !strings.ContainsAny(flags, "xA") {
+		flags += "x"
+	}
+	return flags, flags != current
+}
+
+func (s *valkeyStorage) expired(ctx context.Context, l *slog.Logger, key string) {
+	// only the record key itself marks the end of a secret, helper keys carry a suffix
+	if key == "" || strings.ContainsRune(key, '_') {
+		return
+	}
+	id := ID(key)
+	views, err := expireScript.Exec(ctx, s.client, []string{s.generateMetaKey(id)}, nil).ToString()
+	if err != nil {
+		if !valkey.IsValkeyNil(err) && !errors.Is(err, context.Canceled) {
+			l.Error("failed to mark record as expired", slog.Any("err", err))
+		}
+		return
+	}
+	n, _ := strconv.ParseUint(views, 10, 64)
+	s.notify(ctx, RecordEvent{Type: RecordEventExpired, ID: id, Views: n})
+}
@@ -0,0 +1,86 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	ID    string
+	Scope string
+
+	// Token grants API access, only hash of its secret part is kept at rest
+	Token struct {
+		ID     ID
+		Name   string
+		Scopes []Scope
+		// RateLimit is max requests per minute, zero means unlimited
+		RateLimit int
+		CreatedAt time.Time
+		// ExpiresAt is zero for tokens that never expire
+		ExpiresAt time.Time
+	}
+
+	Store interface {
+		// Create stores new token and returns its plaintext value which is never retrievable again
+		Create(context.Context, *Token) (string, error)
+		// Authenticate resolves plaintext value, expired, revoked and unknown tokens yield ErrInvalidToken
+		Authenticate(context.Context, string) (*Token, error)
+		// Allow counts request against token rate limit and reports wait time once it is exceeded
+		Allow(context.Context, *Token) (bool, time.Duration, error)
+		List(context.Context) ([]*Token, error)
+		Revoke(context.Context, ID) error
+	}
+)
+
+const (
+	ScopeSecretsCreate Scope = "secrets:create"
+	ScopeSecretsBurn   Scope = "secrets:burn"
+	ScopeAdmin         Scope = "admin"
+
+	// Prefix marks API tokens so they are easy to spot in logs and secret scanners
+	Prefix = "oss_"
+)
+
+var (
+	Scopes = []Scope{ScopeSecretsCreate, ScopeSecretsBurn, ScopeAdmin}
+
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenNotFound = errors.New("token not found")
+	ErrInvalidScope  = errors.New("invalid token scope")
+)
+
+// Has reports whether token grants scope, admin grants every scope
+func (t *Token) Has(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeAdmin)
+}
+
+func (t *Token) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// ParseScopes validates comma separated or repeated scope values
+func ParseScopes(values ...string) ([]Scope, error) {
+	var scopes []Scope
+	for _, v := range values {
+		for s := range strings.SplitSeq(v, ",") {
+			scope := Scope(strings.TrimSpace(s))
+			if scope == "" {
+				continue
+			}
+			if !slices.Contains(Scopes, scope) {
+				return nil, ErrInvalidScope
+			}
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	return scopes, nil
+}
@@ -0,0 +1,196 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pudottapommin/golib/pkg/id"
+	"github.com/valkey-io/valkey-go"
+)
+
+const (
+	fieldName      = "name"
+	fieldScopes    = "scopes"
+	fieldHash      = "hash"
+	fieldRateLimit = "rate_limit"
+	fieldCreatedAt = "created_at"
+	fieldExpiresAt = "expires_at"
+
+	indexKey    = "oss_tokens"
+	rateWindow  = time.Minute
+	secretBytes = 32
+)
+
+type valkeyStore struct {
+	client valkey.Client
+}
+
+func NewValkey(client valkey.Client) Store {
+	return &valkeyStore{client: client}
+}
+
+func (s *valkeyStore) Create(ctx context.Context, t *Token) (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	t.ID = ID(id.New().String())
+	t.CreatedAt = time.Now().UTC()
+
+	scopes := make([]string, 0, len(t.Scopes))
+	for _, scope := range t.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+	var expiresAt int64
+	if !t.ExpiresAt.IsZero() {
+		expiresAt = t.ExpiresAt.Unix()
+	}
+
+	key := s.generateKey(t.ID)
+	cmds := valkey.Commands{
+		s.client.B().Hset().Key(key).FieldValue().
+			FieldValue(fieldName, t.Name).
+			FieldValue(fieldScopes, strings.Join(scopes, ",")).
+			FieldValue(fieldHash, hashSecret(secret)).
+			FieldValue(fieldRateLimit, strconv.Itoa(t.RateLimit)).
+			FieldValue(fieldCreatedAt, strconv.FormatInt(t.CreatedAt.Unix(), 10)).
+			FieldValue(fieldExpiresAt, strconv.FormatInt(expiresAt, 10)).
+			Build(),
+		s.client.B().Sadd().Key(indexKey).Member(string(t.ID)).Build(),
+	}
+	if expiresAt > 0 {
+		cmds = append(cmds, s.client.B().Expireat().Key(key).Timestamp(expiresAt).Build())
+	}
+	for _, res := range s.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return "", fmt.Errorf("tokens: error storing token: %w", err)
+		}
+	}
+	return Prefix + string(t.ID) + "_" + secret, nil
+}
+
+func (s *valkeyStore) Authenticate(ctx context.Context, value string) (*Token, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	tid, secret, ok := strings.Cut(rest, "_")
+	if !ok || tid == "" || secret == "" {
+		return nil, ErrInvalidToken
+	}
+
+	m, err := s.client.Do(ctx, s.client.B().Hgetall().Key(s.generateKey(ID(tid))).Build()).AsStrMap()
+	if err != nil {
+		return nil, fmt.Errorf("tokens: error getting token: %w", err)
+	}
+	if len(m) == 0 || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(m[fieldHash])) != 1 {
+		return nil, ErrInvalidToken
+	}
+	t := parseToken(ID(tid), m)
+	if t.IsExpired(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+func (s *valkeyStore) Allow(ctx context.Context, t *Token) (bool, time.Duration, error) {
+	if t.RateLimit <= 0 {
+		return true, 0, nil
+	}
+
+	now := time.Now()
+	window := now.Truncate(rateWindow)
+	key := s.generateKey(t.ID) + "_rate_" + strconv.FormatInt(window.Unix(), 10)
+	res := s.client.DoMulti(ctx,
+		s.client.B().Incr().Key(key).Build(),
+		s.client.B().Expire().Key(key).Seconds(int64(rateWindow.Seconds())).Build(),
+	)
+	count, err := res[0].AsInt64()
+	if err != nil {
+		return false, 0, fmt.Errorf("tokens: error counting request: %w", err)
+	}
+	if count > int64(t.RateLimit) {
+		return false, window.Add(rateWindow).Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+func (s *valkeyStore) List(ctx context.Context) ([]*Token, error) {
+	ids, err := s.client.Do(ctx, s.client.B().Smembers().Key(indexKey).Build()).AsStrSlice()
+	if err != nil {
+		return nil, fmt.Errorf("tokens: error listing tokens: %w", err)
+	}
+
+	cmds := make(valkey.Commands, 0, len(ids))
+	for _, tid := range ids {
+		cmds = append(cmds, s.client.B().Hgetall().Key(s.generateKey(ID(tid))).Build())
+	}
+	list := make([]*Token, 0, len(ids))
+	var stale []string
+	for i, res := range s.client.DoMulti(ctx, cmds...) {
+		m, err := res.AsStrMap()
+		if err != nil {
+			return nil, fmt.Errorf("tokens: error getting token: %w", err)
+		}
+		if len(m) == 0 {
+			stale = append(stale, ids[i])
+			continue
+		}
+		list = append(list, parseToken(ID(ids[i]), m))
+	}
+	if len(stale) > 0 {
+		// expired tokens vanish on their own, prune their index entries
+		_ = s.client.Do(ctx, s.client.B().Srem().Key(indexKey).Member(stale...).Build()).Error()
+	}
+	return list, nil
+}
+
+func (s *valkeyStore) Revoke(ctx context.Context, tid ID) error {
+	res := s.client.DoMulti(ctx,
+		s.client.B().Del().Key(s.generateKey(tid)).Build(),
+		s.client.B().Srem().Key(indexKey).Member(string(tid)).Build(),
+	)
+	n, err := res[0].AsInt64()
+	if err != nil {
+		return fmt.Errorf("tokens: error revoking token: %w", err)
+	}
+	if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (_ *valkeyStore) generateKey(tid ID) string {
+	return string(tid + "_token")
+}
+
+func parseToken(tid ID, m map[string]string) *Token {
+	t := &Token{ID: tid, Name: m[fieldName]}
+	for scope := range strings.SplitSeq(m[fieldScopes], ",") {
+		if scope != "" {
+			t.Scopes = append(t.Scopes, Scope(scope))
+		}
+	}
+	t.RateLimit, _ = strconv.Atoi(m[fieldRateLimit])
+	if v, err := strconv.ParseInt(m[fieldCreatedAt], 10, 64); err == nil {
+		t.CreatedAt = time.Unix(v, 0).UTC()
+	}
+	if v, err := strconv.ParseInt(m[fieldExpiresAt], 10, 64); err == nil && v > 0 {
+		t.ExpiresAt = time.Unix(v, 0).UTC()
+	}
+	return t
+}
+
+// hashSecret uses plain SHA-256, tokens carry 256 bits of entropy so slow hashing adds nothing
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
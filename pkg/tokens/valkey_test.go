@@ -0,0 +1,102 @@
+package tokens
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+)
+
+func newTestStore(t *testing.T) (Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return NewValkey(client), mr
+}
+
+func TestValkeyStore(t *testing.T) {
+	s, mr := newTestStore(t)
+	ctx := context.Background()
+
+	token := &Token{Name: "ci", Scopes: []Scope{ScopeSecretsCreate}, ExpiresAt: time.Now().Add(time.Hour)}
+	value, err := s.Create(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, Prefix))
+	assert.NotContains(t, mr.HGet(string(token.ID)+"_token", fieldHash), strings.TrimPrefix(value, Prefix+string(token.ID)+"_"))
+
+	got, err := s.Authenticate(ctx, value)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", got.Name)
+	assert.True(t, got.Has(ScopeSecretsCreate))
+	assert.False(t, got.Has(ScopeSecretsBurn))
+
+	_, err = s.Authenticate(ctx, value+"0")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = s.Authenticate(ctx, "garbage")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, token.ID, list[0].ID)
+
+	require.NoError(t, s.Revoke(ctx, token.ID))
+	assert.ErrorIs(t, s.Revoke(ctx, token.ID), ErrTokenNotFound)
+	_, err = s.Authenticate(ctx, value)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestValkeyStoreExpiry(t *testing.T) {
+	s, mr := newTestStore(t)
+	ctx := context.Background()
+
+	token := &Token{Name: "short", Scopes: []Scope{ScopeAdmin}, ExpiresAt: time.Now().Add(time.Minute)}
+	value, err := s.Create(ctx, token)
+	require.NoError(t, err)
+
+	mr.FastForward(2 * time.Minute)
+	_, err = s.Authenticate(ctx, value)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestValkeyStoreAllow(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	token := &Token{Name: "limited", Scopes: []Scope{ScopeSecretsCreate}, RateLimit: 2}
+	_, err := s.Create(ctx, token)
+	require.NoError(t, err)
+
+	for range 2 {
+		ok, _, err := s.Allow(ctx, token)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, wait, err := s.Allow(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Positive(t, wait)
+	assert.LessOrEqual(t, wait, time.Minute)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("secrets:create, secrets:burn", "secrets:create")
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeSecretsCreate, ScopeSecretsBurn}, scopes)
+
+	_, err = ParseScopes("secrets:read")
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	_, err = ParseScopes()
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
@@ -0,0 +1,114 @@
+// Package tracing configures OpenTelemetry tracing and traces HTTP requests. Packages start their spans
+// with tracers from global provider, which stays no-op until Setup is called.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Config struct {
+	// Exporter is "otlp" (OTLP over HTTP) or "stdout"
+	Exporter string
+	// Endpoint is host:port of OTLP collector, OTEL_EXPORTER_OTLP_* variables are used when empty
+	Endpoint string
+	// Insecure sends spans to collector without TLS, e.g. to local collector
+	Insecure bool
+	// SampleRatio of traces started here, traces started by caller follow its decision
+	SampleRatio float64
+	ServiceName string
+	Version     string
+}
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var (
+	ErrUnknownExporter = errors.New("tracing: unknown exporter")
+
+	tracer = otel.Tracer("github.com/pudottapommin/onetime-secrets-service/pkg/tracing")
+)
+
+// Setup installs global tracer provider and W3C trace context propagator, returned function flushes
+// and stops exporter
+func Setup(ctx context.Context, c Config) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch c.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownExporter, c.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: error creating exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.ServiceVersion(c.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: error creating resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// Middleware starts server span of every request, continuing trace of caller from traceparent header.
+// Span is named by route pattern tagged with server.TagRoute; URL path is never recorded, because
+// reveal links carry decryption key in it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)))
+		defer span.End()
+
+		r, route := server.RecordRoute(r.WithContext(ctx))
+		sw := server.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		if pattern := route(); pattern != "" {
+			span.SetName(r.Method + " " + pattern)
+			span.SetAttributes(semconv.HTTPRoute(pattern))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+	})
+}
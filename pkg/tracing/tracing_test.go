@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valkey-io/valkey-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+		_ = tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupRecorder(t)
+
+	mr := miniredis.RunT(t)
+	client, err := valkey.NewClient(valkey.ClientOption{InitAddress: []string{mr.Addr()}, DisableCache: true})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	s := storage.NewValkey(client, nil, func(id storage.ID, key storage.Key) storage.Record[storage.ID, storage.Key] {
+		return secrets.NewSecret(id, key)
+	})
+
+	key := encryption.GenerateNewKey(32)
+	secret := secrets.NewSecret("s1", key)
+	secret.SetValue("hunter2")
+	_, err = s.Store(context.Background(), secret)
+	require.NoError(t, err)
+	exporter.Reset()
+
+	mux := flow.New()
+	mux.Use(Middleware)
+	server.NewGuard().Handle(mux, http.MethodGet, "/api/:value", server.Public, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.Get(r.Context(), "s1", key); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/s1-secret-key", nil)
+	req.Header.Set("traceparent", "00-01000000000000000000000000000000-0200000000000000-01")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := exporter.GetSpans()
+	var root *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			root = &spans[i]
+		}
+	}
+	require.NotNil(t, root, "server span must be recorded")
+	assert.Equal(t, "GET /api/:value", root.Name)
+	assert.Equal(t, parent.TraceID(), root.SpanContext.TraceID())
+	assert.Equal(t, parent.SpanID(), root.Parent.SpanID())
+
+	names := map[string]trace.SpanID{}
+	for _, span := range spans {
+		names[span.Name] = span.Parent.SpanID()
+		assert.Equal(t, parent.TraceID(), span.SpanContext.TraceID(), span.Name)
+		for _, attr := range span.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "secret-key", "URL path must not be recorded")
+		}
+	}
+	assert.Equal(t, root.SpanContext.SpanID(), names["storage.Get"], "storage span must be child of request span")
+	assert.Contains(t, names, "storage.decode")
+}
+
+func TestSetup(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterStdout, SampleRatio: 1, ServiceName: "test", Version: "dev"})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -3,34 +3,75 @@ package ui
 import (
 	"time"
 
+	"github.com/pudottapommin/onetime-secrets-service/pkg/encryption"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/storage"
 )
 
 type (
 	FormModel struct {
-		CsrfField string
-		CsrfToken string
+		CsrfField     string
+		CsrfToken     string
+		NotifyEnabled bool
+		// CSPNonce is set from request when page is executed, scripts and styles of layout carry it
+		CSPNonce string
 	}
 	PageIndex struct {
 		*FormModel
 		IsAuthenticated bool
+		CanLogout       bool
+		Subject         string
+		PasswordEnabled bool
+		SSOEnabled      bool
+		AuthError       string
 	}
 	PageSecret struct {
 		*FormModel
-		NotFound   bool
+		NotFound bool
+		// Preview is served to link unfurlers and crawlers, it tells nothing about the secret
+		Preview    bool
 		Url        string
 		Secret     string
 		Passphrase *string
 		ViewsLeft  uint64
 	}
+	PageManage struct {
+		*FormModel
+		NotFound bool
+		Url      string
+		Status   *storage.RecordStatus
+	}
+	PageRequest struct {
+		*FormModel
+		NotFound bool
+		Pending  bool
+	}
+	PageUpload struct {
+		*FormModel
+		NotFound bool
+		Url      string
+		Note     string
+	}
+	CardRequestCreated struct {
+		UploadUrl string
+		RevealUrl string
+		ExpiresAt time.Time
+	}
 	CardSecretCreated struct {
 		Url       string
+		ManageUrl string
 		ExpiresAt time.Time
 	}
 	CardSecretDecrypted struct {
 		Url       string
 		Secret    string
+		Sealed    encryption.SealedFormat
 		ExpiresAt time.Time
 		Files     []*storage.FileRecord
 	}
 )
+
+func (m *FormModel) setCSPNonce(nonce string) {
+	if m != nil {
+		m.CSPNonce = nonce
+	}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
@@ -11,6 +12,11 @@ import (
 
 	"github.com/pudottapommin/onetime-secrets-service/assets"
 	"github.com/pudottapommin/onetime-secrets-service/pkg/secrets"
+	"github.com/pudottapommin/onetime-secrets-service/pkg/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:embed templates/*.gohtml
@@ -38,13 +44,33 @@ var templateFn = template.FuncMap{
 	},
 }
 
+var tracer = otel.Tracer("github.com/pudottapommin/onetime-secrets-service/pkg/ui")
+
+// execute renders template name within span, so slow rendering shows in traces
+func execute(ctx context.Context, t *template.Template, w io.Writer, name string, data any) error {
+	_, span := tracer.Start(ctx, "template "+name, trace.WithAttributes(attribute.String("template", name)))
+	defer span.End()
+	if page, ok := data.(interface{ setCSPNonce(string) }); ok {
+		page.setCSPNonce(server.CSPNonce(ctx))
+	}
+	if err := t.ExecuteTemplate(w, name, data); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
 type templateBase struct {
 	template *template.Template
 }
 
 type (
-	indexTemplates  templateBase
-	secretTemplates templateBase
+	indexTemplates   templateBase
+	secretTemplates  templateBase
+	manageTemplates  templateBase
+	requestTemplates templateBase
+	uploadTemplates  templateBase
 )
 
 var (
@@ -57,21 +83,21 @@ var (
 	}
 )
 
-func (t indexTemplates) ExecutePage(w io.Writer, data PageIndex) error {
-	return t.template.ExecuteTemplate(w, "index/page.html", data)
+func (t indexTemplates) ExecutePage(ctx context.Context, w io.Writer, data PageIndex) error {
+	return execute(ctx, t.template, w, "index/page.html", data)
 }
 
-func (t indexTemplates) ExecuteHTMXSecretCreatedCard(w io.Writer, data CardSecretCreated) error {
-	return t.template.ExecuteTemplate(w, "index/htmx/secret_created_card.html", data)
+func (t indexTemplates) ExecuteHTMXSecretCreatedCard(ctx context.Context, w io.Writer, data CardSecretCreated) error {
+	return execute(ctx, t.template, w, "index/htmx/secret_created_card.html", data)
 }
-func (t indexTemplates) ExecuteHTMXSecretForm(w io.Writer, csrf FormModel) error {
-	return t.template.ExecuteTemplate(w, "index/htmx/secret_form.html", csrf)
+func (t indexTemplates) ExecuteHTMXSecretForm(ctx context.Context, w io.Writer, csrf FormModel) error {
+	return execute(ctx, t.template, w, "index/htmx/secret_form.html", csrf)
 }
-func (t indexTemplates) ExecuteHTMXSecretError(w io.Writer, err string) error {
-	return t.template.ExecuteTemplate(w, "index/htmx/secret_error.html", err)
+func (t indexTemplates) ExecuteHTMXSecretError(ctx context.Context, w io.Writer, err string) error {
+	return execute(ctx, t.template, w, "index/htmx/secret_error.html", err)
 }
-func (t indexTemplates) ExecuteHTMXAuthError(w io.Writer) error {
-	return t.template.ExecuteTemplate(w, "index/htmx/auth_error.html", nil)
+func (t indexTemplates) ExecuteHTMXAuthError(ctx context.Context, w io.Writer, err string) error {
+	return execute(ctx, t.template, w, "index/htmx/auth_error.html", err)
 }
 
 var (
@@ -84,18 +110,80 @@ var (
 	}
 )
 
-func (t secretTemplates) ExecutePage(w io.Writer, data PageSecret) error {
-	return t.template.ExecuteTemplate(w, "secret/page.html", data)
+func (t secretTemplates) ExecutePage(ctx context.Context, w io.Writer, data PageSecret) error {
+	return execute(ctx, t.template, w, "secret/page.html", data)
+}
+
+func (t secretTemplates) ExecuteHTMXSecretDecrypted(ctx context.Context, w io.Writer, data CardSecretDecrypted) error {
+	return execute(ctx, t.template, w, "secret/htmx/secret_decrypted.html", data)
+}
+func (t secretTemplates) ExecuteHTMXSecretDecryptedFiles(ctx context.Context, w io.Writer, data CardSecretDecrypted) error {
+	return execute(ctx, t.template, w, "secret/htmx/decrypt_files.html", data)
+}
+func (t secretTemplates) ExecuteHTMXDecryptError(ctx context.Context, w io.Writer, err string) error {
+	return execute(ctx, t.template, w, "secret/htmx/decrypt_error.html", err)
+}
+
+var (
+	managePaths = []string{"templates/layout.gohtml", "templates/manage*.gohtml"}
+	Manage      = manageTemplates{
+		template: template.Must(
+			template.New("manage").
+				Funcs(templateFn).
+				ParseFS(templateFS, managePaths...)),
+	}
+)
+
+func (t manageTemplates) ExecutePage(ctx context.Context, w io.Writer, data PageManage) error {
+	return execute(ctx, t.template, w, "manage/page.html", data)
+}
+
+func (t manageTemplates) ExecuteHTMXStatusCard(ctx context.Context, w io.Writer, data PageManage) error {
+	return execute(ctx, t.template, w, "manage/htmx/status_card.html", data)
+}
+
+var (
+	requestPaths = []string{"templates/layout.gohtml", "templates/request*.gohtml"}
+	Request      = requestTemplates{
+		template: template.Must(
+			template.New("request").
+				Funcs(templateFn).
+				ParseFS(templateFS, requestPaths...)),
+	}
+)
+
+func (t requestTemplates) ExecutePage(ctx context.Context, w io.Writer, data PageRequest) error {
+	return execute(ctx, t.template, w, "request/page.html", data)
+}
+
+func (t requestTemplates) ExecuteHTMXCreatedCard(ctx context.Context, w io.Writer, data CardRequestCreated) error {
+	return execute(ctx, t.template, w, "request/htmx/created_card.html", data)
+}
+
+func (t requestTemplates) ExecuteHTMXError(ctx context.Context, w io.Writer, err string) error {
+	return execute(ctx, t.template, w, "request/htmx/error.html", err)
 }
 
-func (t secretTemplates) ExecuteHTMXSecretDecrypted(w io.Writer, data CardSecretDecrypted) error {
-	return t.template.ExecuteTemplate(w, "secret/htmx/secret_decrypted.html", data)
+var (
+	uploadPaths = []string{"templates/layout.gohtml", "templates/upload*.gohtml"}
+	Upload      = uploadTemplates{
+		template: template.Must(
+			template.New("upload").
+				Funcs(templateFn).
+				ParseFS(templateFS, uploadPaths...)),
+	}
+)
+
+func (t uploadTemplates) ExecutePage(ctx context.Context, w io.Writer, data PageUpload) error {
+	return execute(ctx, t.template, w, "upload/page.html", data)
 }
-func (t secretTemplates) ExecuteHTMXSecretDecryptedFiles(w io.Writer, data CardSecretDecrypted) error {
-	return t.template.ExecuteTemplate(w, "secret/htmx/decrypt_files.html", data)
+
+func (t uploadTemplates) ExecuteHTMXDoneCard(ctx context.Context, w io.Writer) error {
+	return execute(ctx, t.template, w, "upload/htmx/done_card.html", nil)
 }
-func (t secretTemplates) ExecuteHTMXDecryptError(w io.Writer) error {
-	return t.template.ExecuteTemplate(w, "secret/htmx/decrypt_error.html", nil)
+
+func (t uploadTemplates) ExecuteHTMXError(ctx context.Context, w io.Writer, err string) error {
+	return execute(ctx, t.template, w, "upload/htmx/error.html", err)
 }
 
 func Recompile() {
@@ -111,4 +199,35 @@ func Recompile() {
 			Funcs(templateFn).
 			ParseFS(fs, secretPaths...))
 
+	Manage.template = template.Must(
+		template.New("manage").
+			Funcs(templateFn).
+			ParseFS(fs, managePaths...))
+
+	Request.template = template.Must(
+		template.New("request").
+			Funcs(templateFn).
+			ParseFS(fs, requestPaths...))
+
+	Upload.template = template.Must(
+		template.New("upload").
+			Funcs(templateFn).
+			ParseFS(fs, uploadPaths...))
+}
+
+// Compiled reports template sets missing their page template, e.g. after failed hot reload
+func Compiled() error {
+	pages := map[string]*template.Template{
+		"index/page.html":   Index.template,
+		"secret/page.html":  Secret.template,
+		"manage/page.html":  Manage.template,
+		"request/page.html": Request.template,
+		"upload/page.html":  Upload.template,
+	}
+	for name, t := range pages {
+		if t == nil || t.Lookup(name) == nil {
+			return fmt.Errorf("ui: template %q is not compiled", name)
+		}
+	}
+	return nil
 }